@@ -0,0 +1,503 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// Anki packages (.apkg for decks, .colpkg for whole collections) are zip files
+// holding a SQLite collection plus numbered media files. Collections in the
+// newer zstd-compressed format (collection.anki21b) are not supported; Anki
+// writes a compatible collection when "Support older Anki versions" is ticked
+// during export.
+var ankiCollectionNames = []string{"collection.anki21", "collection.anki2"}
+
+// Anki model type for cloze note types
+const ankiModelTypeCloze = 1
+
+type AnkiImportOptions struct {
+	// ImportReviewHistory replays the Anki revlog through FSRS so imported
+	// cards keep their intervals instead of starting as new cards
+	ImportReviewHistory bool
+}
+
+type AnkiImportResult struct {
+	NotesRead    int
+	CardsCreated int
+	Duplicates   int
+	ReviewStates int
+	Errors       []string
+}
+
+type AnkiImporter struct {
+	cardRepo   CardRepository
+	noteRepo   NoteRepository
+	reviewRepo ReviewStateRepository
+	fsrs       *fsrs.FSRS
+}
+
+type ankiModel struct {
+	Name string `json:"name"`
+	Type int    `json:"type"`
+	Flds []struct {
+		Name string `json:"name"`
+	} `json:"flds"`
+	Tmpls []struct {
+		Name string `json:"name"`
+		Qfmt string `json:"qfmt"`
+		Afmt string `json:"afmt"`
+	} `json:"tmpls"`
+}
+
+type ankiDeck struct {
+	Name string `json:"name"`
+}
+
+type ankiCard struct {
+	ID     int64
+	NoteID int64
+	DeckID int64
+	Ord    int
+}
+
+func NewAnkiImporter(cardRepo CardRepository, noteRepo NoteRepository, reviewRepo ReviewStateRepository) *AnkiImporter {
+	return &AnkiImporter{
+		cardRepo:   cardRepo,
+		noteRepo:   noteRepo,
+		reviewRepo: reviewRepo,
+		fsrs:       fsrs.NewFSRS(fsrs.DefaultParam()),
+	}
+}
+
+func (ai *AnkiImporter) ImportFile(filePath string, options AnkiImportOptions) (*AnkiImportResult, error) {
+	collectionPath, err := extractAnkiCollection(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(collectionPath)
+
+	collection, err := sql.Open("sqlite3", collectionPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Anki collection: %w", err)
+	}
+	defer collection.Close()
+
+	models, decks, err := readAnkiCollectionInfo(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	cardsByNote, err := readAnkiCards(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := collection.Query(`SELECT id, mid, tags, flds FROM notes ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Anki notes: %w", err)
+	}
+	defer rows.Close()
+
+	result := &AnkiImportResult{}
+
+	// Anki card ID -> ID of the card created for it
+	imported := make(map[int64]int64)
+
+	for rows.Next() {
+		var noteID, modelID int64
+		var tags, fields string
+		if err := rows.Scan(&noteID, &modelID, &tags, &fields); err != nil {
+			return nil, fmt.Errorf("failed to scan Anki note: %w", err)
+		}
+		result.NotesRead++
+
+		model, ok := models[fmt.Sprintf("%d", modelID)]
+		if !ok {
+			result.Errors = append(result.Errors, fmt.Sprintf("note %d: unknown note type %d", noteID, modelID))
+			continue
+		}
+
+		cards := cardsByNote[noteID]
+		if len(cards) == 0 {
+			continue
+		}
+
+		deckName := ""
+		if deck, ok := decks[fmt.Sprintf("%d", cards[0].DeckID)]; ok {
+			deckName = deck.Name
+		}
+
+		values := strings.Split(fields, "\x1f")
+		named := make(map[string]string)
+		for i, field := range model.Flds {
+			if i < len(values) {
+				named[field.Name] = values[i]
+			}
+		}
+
+		if model.Type == ankiModelTypeCloze {
+			ai.importClozeNote(noteID, values, cards, deckName, tags, result, imported)
+			continue
+		}
+
+		for _, card := range cards {
+			ai.importStandardCard(filePath, model, named, card, decks, tags, result, imported)
+		}
+	}
+
+	if options.ImportReviewHistory {
+		if err := ai.importReviewHistory(collection, imported, result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (ai *AnkiImporter) importClozeNote(noteID int64, values []string, cards []ankiCard, deckName, tags string, result *AnkiImportResult, imported map[int64]int64) {
+	content := ankiFieldToText(values[0])
+
+	exists, err := ai.noteRepo.NoteExists(NoteTypeCloze, content)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("note %d: %v", noteID, err))
+		return
+	}
+	if exists {
+		result.Duplicates++
+		return
+	}
+
+	generated, err := buildNoteCards(NoteTypeCloze, content)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("note %d: %v", noteID, err))
+		return
+	}
+	for i := range generated {
+		generated[i].SourceContext = deckName
+		generated[i].PromptType = "factual"
+		generated[i].Tags = normalizeTags(tags)
+	}
+
+	if _, err := createNoteCards(ai.cardRepo, ai.noteRepo, NoteTypeCloze, content, generated); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("note %d: %v", noteID, err))
+		return
+	}
+	result.CardsCreated += len(generated)
+
+	// Anki numbers cloze cards from 0, cloze notes here from 1
+	byOrdinal := make(map[int]int64)
+	for _, card := range generated {
+		byOrdinal[card.Ordinal] = card.ID
+	}
+	for _, card := range cards {
+		if id, ok := byOrdinal[card.Ord+1]; ok {
+			imported[card.ID] = id
+		}
+	}
+}
+
+func (ai *AnkiImporter) importStandardCard(filePath string, model ankiModel, fields map[string]string, card ankiCard, decks map[string]ankiDeck, tags string, result *AnkiImportResult, imported map[int64]int64) {
+	if card.Ord >= len(model.Tmpls) {
+		result.Errors = append(result.Errors, fmt.Sprintf("card %d: note type %q has no template %d", card.ID, model.Name, card.Ord))
+		return
+	}
+	template := model.Tmpls[card.Ord]
+
+	question := ankiFieldToText(renderAnkiTemplate(template.Qfmt, fields, ""))
+	answer := renderAnkiTemplate(template.Afmt, fields, "")
+	if loc := ankiAnswerDivider.FindStringIndex(answer); loc != nil {
+		answer = answer[loc[1]:]
+	}
+	answer = ankiFieldToText(answer)
+
+	if question == "" || answer == "" {
+		result.Errors = append(result.Errors, fmt.Sprintf("card %d: empty question or answer after rendering template %q", card.ID, template.Name))
+		return
+	}
+
+	exists, err := ai.cardRepo.CardExists(question, answer)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("card %d: %v", card.ID, err))
+		return
+	}
+	if exists {
+		result.Duplicates++
+		return
+	}
+
+	deckName := ""
+	if deck, ok := decks[fmt.Sprintf("%d", card.DeckID)]; ok {
+		deckName = deck.Name
+	}
+
+	dbCard := &DBCard{
+		Question:      question,
+		Answer:        answer,
+		SourceFile:    filePath,
+		SourceContext: sql.NullString{String: deckName, Valid: deckName != ""},
+		PromptType:    "factual",
+		Tags:          normalizeTags(tags),
+	}
+	if err := ai.cardRepo.Create(dbCard); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("card %d: %v", card.ID, err))
+		return
+	}
+
+	result.CardsCreated++
+	imported[card.ID] = dbCard.ID
+}
+
+// importReviewHistory replays each imported card's Anki reviews through FSRS
+// in chronological order, giving the card the state it would have had if it
+// had been studied here all along
+func (ai *AnkiImporter) importReviewHistory(collection *sql.DB, imported map[int64]int64, result *AnkiImportResult) error {
+	// Manual reschedules are logged with ease 0 and are not reviews
+	rows, err := collection.Query(`SELECT id, cid, ease FROM revlog WHERE ease BETWEEN 1 AND 4 ORDER BY cid ASC, id ASC`)
+	if err != nil {
+		return fmt.Errorf("failed to query Anki review log: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[int64]*ReviewState)
+	var order []int64
+
+	for rows.Next() {
+		var reviewID, ankiCardID int64
+		var ease int
+		if err := rows.Scan(&reviewID, &ankiCardID, &ease); err != nil {
+			return fmt.Errorf("failed to scan Anki review: %w", err)
+		}
+
+		cardID, ok := imported[ankiCardID]
+		if !ok {
+			continue
+		}
+
+		state, ok := states[cardID]
+		if !ok {
+			state = &ReviewState{FSRSCard: fsrs.NewCard()}
+			states[cardID] = state
+			order = append(order, cardID)
+		}
+
+		// Revlog IDs are the review time in milliseconds
+		reviewedAt := time.UnixMilli(reviewID)
+		state.FSRSCard = ai.fsrs.Next(state.FSRSCard, reviewedAt, fsrs.Rating(ease)).Card
+		state.LastReview = reviewedAt
+		state.ReviewCount++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read Anki review log: %w", err)
+	}
+
+	for _, cardID := range order {
+		state := states[cardID]
+		fsrsCardJSON, err := FSRSCardToJSON(state.FSRSCard)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("review state for card %d: %v", cardID, err))
+			continue
+		}
+
+		dbState := &DBReviewState{
+			CardID:       cardID,
+			FSRSCardData: fsrsCardJSON,
+			LastReview:   state.LastReview,
+			ReviewCount:  state.ReviewCount,
+			DueDate:      state.FSRSCard.Due,
+		}
+		if err := ai.reviewRepo.Create(dbState); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("review state for card %d: %v", cardID, err))
+			continue
+		}
+		result.ReviewStates++
+	}
+
+	return nil
+}
+
+// extractAnkiCollection copies the SQLite collection out of an Anki package
+// into a temporary file and returns its path
+func extractAnkiCollection(filePath string) (string, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open Anki package %s: %w", filePath, err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var collectionFile *zip.File
+	for _, name := range ankiCollectionNames {
+		if file, ok := files[name]; ok {
+			collectionFile = file
+			break
+		}
+	}
+	if collectionFile == nil {
+		if _, ok := files["collection.anki21b"]; ok {
+			return "", fmt.Errorf("this package uses the newer Anki collection format; re-export it with \"Support older Anki versions\" enabled")
+		}
+		return "", fmt.Errorf("no Anki collection found in %s", filePath)
+	}
+
+	src, err := collectionFile.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read Anki collection: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "spaced-anki-*.db")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		os.Remove(dst.Name())
+		return "", fmt.Errorf("failed to extract Anki collection: %w", err)
+	}
+
+	return dst.Name(), nil
+}
+
+func readAnkiCollectionInfo(collection *sql.DB) (map[string]ankiModel, map[string]ankiDeck, error) {
+	var modelsJSON, decksJSON string
+	err := collection.QueryRow(`SELECT models, decks FROM col`).Scan(&modelsJSON, &decksJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Anki collection info: %w", err)
+	}
+
+	models := make(map[string]ankiModel)
+	if err := json.Unmarshal([]byte(modelsJSON), &models); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse Anki note types: %w", err)
+	}
+
+	decks := make(map[string]ankiDeck)
+	if err := json.Unmarshal([]byte(decksJSON), &decks); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse Anki decks: %w", err)
+	}
+
+	if len(models) == 0 {
+		return nil, nil, fmt.Errorf("Anki collection has no note types; re-export it with \"Support older Anki versions\" enabled")
+	}
+
+	return models, decks, nil
+}
+
+func readAnkiCards(collection *sql.DB) (map[int64][]ankiCard, error) {
+	rows, err := collection.Query(`SELECT id, nid, did, ord FROM cards`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Anki cards: %w", err)
+	}
+	defer rows.Close()
+
+	cardsByNote := make(map[int64][]ankiCard)
+	for rows.Next() {
+		var card ankiCard
+		if err := rows.Scan(&card.ID, &card.NoteID, &card.DeckID, &card.Ord); err != nil {
+			return nil, fmt.Errorf("failed to scan Anki card: %w", err)
+		}
+		cardsByNote[card.NoteID] = append(cardsByNote[card.NoteID], card)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Anki cards: %w", err)
+	}
+
+	for _, cards := range cardsByNote {
+		sort.Slice(cards, func(i, j int) bool { return cards[i].Ord < cards[j].Ord })
+	}
+
+	return cardsByNote, nil
+}
+
+// renderAnkiTemplate fills in an Anki card template. It supports field
+// references (including filters such as {{text:Field}}), {{#Field}} and
+// {{^Field}} sections and {{FrontSide}}. Type-in-the-answer fields are dropped.
+func renderAnkiTemplate(template string, fields map[string]string, frontSide string) string {
+	var out strings.Builder
+
+	for {
+		start := strings.Index(template, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(template[start:], "}}")
+		if end < 0 {
+			break
+		}
+
+		out.WriteString(template[:start])
+		tag := strings.TrimSpace(template[start+2 : start+end])
+		template = template[start+end+2:]
+
+		switch {
+		case strings.HasPrefix(tag, "#") || strings.HasPrefix(tag, "^"):
+			name := strings.TrimSpace(tag[1:])
+			closing := "{{/" + name + "}}"
+			idx := strings.Index(template, closing)
+			if idx < 0 {
+				continue
+			}
+			inner := template[:idx]
+			template = template[idx+len(closing):]
+
+			hasValue := ankiFieldToText(fields[name]) != ""
+			if hasValue == strings.HasPrefix(tag, "#") {
+				out.WriteString(renderAnkiTemplate(inner, fields, frontSide))
+			}
+		case strings.HasPrefix(tag, "/"):
+			// Unmatched closing tag
+		case tag == "FrontSide":
+			out.WriteString(frontSide)
+		default:
+			name := tag
+			filters := ""
+			if idx := strings.LastIndex(tag, ":"); idx >= 0 {
+				filters = tag[:idx]
+				name = tag[idx+1:]
+			}
+			if strings.Contains(filters, "type") {
+				continue
+			}
+			out.WriteString(fields[name])
+		}
+	}
+
+	out.WriteString(template)
+	return out.String()
+}
+
+var (
+	ankiAnswerDivider = regexp.MustCompile(`(?i)<hr[^>]*id=["']?answer["']?[^>]*>`)
+	ankiLineBreaks    = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>|</li>`)
+	ankiImageTag      = regexp.MustCompile(`(?i)<img[^>]*src=["']?([^"'>\s]+)["']?[^>]*>`)
+	ankiSoundTag      = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	ankiHTMLTag       = regexp.MustCompile(`<[^>]*>`)
+	ankiBlankLines    = regexp.MustCompile(`\n{3,}`)
+)
+
+// ankiFieldToText converts Anki's HTML field content to plain card text
+func ankiFieldToText(field string) string {
+	text := ankiLineBreaks.ReplaceAllString(field, "\n")
+	text = ankiImageTag.ReplaceAllString(text, "[image: $1]")
+	text = ankiSoundTag.ReplaceAllString(text, "")
+	text = ankiHTMLTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+	text = ankiBlankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
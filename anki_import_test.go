@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	ankiFirstReview  = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	ankiSecondReview = time.Date(2024, 1, 4, 10, 0, 0, 0, time.UTC)
+)
+
+// writeAnkiPackage writes an Anki package with a basic note that has a reverse
+// card, a cloze note, a note of an unknown type and a few reviews
+func writeAnkiPackage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	collectionPath := filepath.Join(dir, "collection.anki2")
+
+	collection, err := sql.Open("sqlite3", collectionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collection.Close()
+
+	statements := []string{
+		`CREATE TABLE col (models TEXT, decks TEXT)`,
+		`CREATE TABLE notes (id INTEGER, mid INTEGER, tags TEXT, flds TEXT)`,
+		`CREATE TABLE cards (id INTEGER, nid INTEGER, did INTEGER, ord INTEGER)`,
+		`CREATE TABLE revlog (id INTEGER, cid INTEGER, ease INTEGER)`,
+		`INSERT INTO col VALUES ('{
+			"1": {"name": "Basic (and reversed card)", "type": 0,
+				"flds": [{"name": "Front"}, {"name": "Back"}],
+				"tmpls": [
+					{"name": "Card 1", "qfmt": "{{Front}}", "afmt": "{{FrontSide}}<hr id=answer>{{Back}}"},
+					{"name": "Card 2", "qfmt": "{{Back}}", "afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Front}}"}
+				]},
+			"2": {"name": "Cloze", "type": 1,
+				"flds": [{"name": "Text"}, {"name": "Extra"}],
+				"tmpls": [{"name": "Cloze", "qfmt": "{{cloze:Text}}", "afmt": "{{cloze:Text}}"}]}
+		}', '{"1": {"name": "Default"}, "10": {"name": "Languages::Go"}}')`,
+		`INSERT INTO notes VALUES
+			(100, 1, ' go lang ', 'What is <b>Go</b>?' || char(31) || 'a&nbsp;language<br>by Google'),
+			(101, 2, '', '{{c1::Paris}} is the capital of {{c2::France}}' || char(31) || ''),
+			(102, 99, '', 'Lost' || char(31) || 'type')`,
+		`INSERT INTO cards VALUES (1000, 100, 10, 0), (1001, 100, 10, 1), (1002, 101, 1, 0), (1003, 101, 1, 1), (1004, 102, 1, 0)`,
+		`INSERT INTO revlog VALUES (?, 1000, 3), (?, 1000, 3), (?, 1002, 1), (?, 1003, 0)`,
+	}
+	for _, statement := range statements {
+		var args []interface{}
+		if strings.Contains(statement, "?") {
+			args = []interface{}{ankiFirstReview.UnixMilli(), ankiSecondReview.UnixMilli(), ankiFirstReview.UnixMilli(), ankiSecondReview.UnixMilli()}
+		}
+		if _, err := collection.Exec(statement, args...); err != nil {
+			t.Fatalf("failed to write Anki collection: %v", err)
+		}
+	}
+	collection.Close()
+
+	return writeZip(t, filepath.Join(dir, "deck.apkg"), map[string]string{"collection.anki2": collectionPath, "media": ""})
+}
+
+// writeZip writes a zip file holding the given files, by name in the zip and
+// path on disk. An empty path adds an empty file.
+func writeZip(t *testing.T, path string, files map[string]string) string {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	for name, source := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if source == "" {
+			continue
+		}
+		content, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAnkiImporter(db *Database) *AnkiImporter {
+	return NewAnkiImporter(NewSQLiteCardRepository(db), NewSQLiteNoteRepository(db), NewSQLiteReviewStateRepository(db))
+}
+
+func TestAnkiImport(t *testing.T) {
+	db := newTestDatabase(t)
+	path := writeAnkiPackage(t)
+
+	result, err := newTestAnkiImporter(db).ImportFile(path, AnkiImportOptions{ImportReviewHistory: true})
+	if err != nil {
+		t.Fatalf("ImportFile: %v", err)
+	}
+	if result.NotesRead != 3 || result.CardsCreated != 4 || result.Duplicates != 0 || result.ReviewStates != 2 {
+		t.Errorf("result = %+v, want 3 notes read, 4 cards created and 2 review states", result)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "unknown note type 99") {
+		t.Errorf("errors = %q, want the note of an unknown type reported", result.Errors)
+	}
+
+	type imported struct{ Q, A, Deck, Tags string }
+	cards, err := NewSQLiteCardRepository(db).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]imported)
+	ids := make(map[string]int64)
+	for _, card := range cards {
+		got[card.Question] = imported{card.Question, card.Answer, card.SourceContext.String, card.Tags}
+		ids[card.Question] = card.ID
+	}
+	want := map[string]imported{
+		"What is Go?":                    {"What is Go?", "a language\nby Google", "Languages::Go", "go, lang"},
+		"a language\nby Google":          {"a language\nby Google", "What is Go?", "Languages::Go", "go, lang"},
+		"[...] is the capital of France": {"[...] is the capital of France", "Paris", "Default", ""},
+		"Paris is the capital of [...]":  {"Paris is the capital of [...]", "France", "Default", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported cards = %q, want %q", got, want)
+	}
+
+	// Reviews are replayed in order, reschedules and unreviewed cards are skipped
+	reviewRepo := NewSQLiteReviewStateRepository(db)
+	reviews := map[string]struct {
+		count int
+		last  time.Time
+	}{
+		"What is Go?":                    {2, ankiSecondReview},
+		"[...] is the capital of France": {1, ankiFirstReview},
+	}
+	for question, review := range reviews {
+		state, err := reviewRepo.GetByCardID(ids[question])
+		if err != nil {
+			t.Fatalf("review state of %q: %v", question, err)
+		}
+		if state.ReviewCount != review.count || !state.LastReview.Equal(review.last) || !state.DueDate.After(review.last) {
+			t.Errorf("review state of %q = %d reviews, last %v, due %v; want %d reviews, last %v",
+				question, state.ReviewCount, state.LastReview, state.DueDate, review.count, review.last)
+		}
+	}
+	if n := countRows(t, db, "review_states"); n != 2 {
+		t.Errorf("%d review states, want 2", n)
+	}
+
+	// Importing again adds nothing; a cloze note counts as one duplicate
+	result, err = newTestAnkiImporter(db).ImportFile(path, AnkiImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportFile again: %v", err)
+	}
+	if result.CardsCreated != 0 || result.Duplicates != 3 || result.ReviewStates != 0 {
+		t.Errorf("result of importing again = %+v, want 3 duplicates", result)
+	}
+	if n := countRows(t, db, "cards"); n != 4 {
+		t.Errorf("%d cards after importing again, want 4", n)
+	}
+}
+
+func TestAnkiImportWithoutReviewHistory(t *testing.T) {
+	db := newTestDatabase(t)
+	result, err := newTestAnkiImporter(db).ImportFile(writeAnkiPackage(t), AnkiImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportFile: %v", err)
+	}
+	if result.CardsCreated != 4 || result.ReviewStates != 0 {
+		t.Errorf("result = %+v, want 4 cards created and no review states", result)
+	}
+	if n := countRows(t, db, "review_states"); n != 0 {
+		t.Errorf("%d review states, want none", n)
+	}
+}
+
+func TestAnkiImportUnsupportedPackages(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"no collection", map[string]string{"media": ""}, "no Anki collection found"},
+		{"newer format", map[string]string{"collection.anki21b": "", "media": ""}, "Support older Anki versions"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeZip(t, filepath.Join(dir, test.name+".apkg"), test.files)
+			_, err := newTestAnkiImporter(newTestDatabase(t)).ImportFile(path, AnkiImportOptions{})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ImportFile error = %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestRenderAnkiTemplate(t *testing.T) {
+	fields := map[string]string{"Front": "Hund", "Back": "dog", "Hint": "", "Note": "<br>"}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{{Front}} / {{ Back }}", "Hund / dog"},
+		{"{{text:Front}}", "Hund"},
+		{"{{Front}}{{type:Back}}", "Hund"},
+		{"{{#Back}}has {{Back}}{{/Back}}", "has dog"},
+		{"{{#Hint}}hint: {{Hint}}{{/Hint}}", ""},
+		{"{{^Hint}}no hint{{/Hint}}", "no hint"},
+		{"{{#Note}}empty HTML{{/Note}}", ""},
+		{"{{FrontSide}}<hr id=answer>{{Back}}", "front<hr id=answer>dog"},
+		{"{{Missing}}!", "!"},
+		{"{{#Back}}unclosed", "unclosed"},
+		{"{{/Back}}stray", "stray"},
+		{"open {{Front", "open {{Front"},
+	}
+
+	for _, test := range tests {
+		if got := renderAnkiTemplate(test.template, fields, "front"); got != test.want {
+			t.Errorf("renderAnkiTemplate(%q) = %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestAnkiFieldToText(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"<b>bold</b> and <i>italic</i>", "bold and italic"},
+		{"one<br>two<br/>three<BR />four", "one\ntwo\nthree\nfour"},
+		{"<div>one</div><div>two</div>", "one\ntwo"},
+		{`<img src="cat.jpg"> a cat`, "[image: cat.jpg] a cat"},
+		{"[sound:hund.mp3]Hund", "Hund"},
+		{"a &lt; b&nbsp;&amp;&nbsp;c", "a < b & c"},
+		{"one<br><br><br><br>two", "one\n\ntwo"},
+		{"  <br> padded <br> ", "padded"},
+	}
+
+	for _, test := range tests {
+		if got := ankiFieldToText(test.field); got != test.want {
+			t.Errorf("ankiFieldToText(%q) = %q, want %q", test.field, got, test.want)
+		}
+	}
+}
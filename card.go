@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"database/sql"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -19,13 +22,63 @@ type Card struct {
 	SourceContext string    // Book, article, project name
 	PromptType    string    // factual, conceptual, application, comparison
 	Tags          string    // Comma-separated tags
+	NoteID        int64     // Note this card was generated from (0 for standalone cards)
+	Ordinal       int       // Position among the note's sibling cards
+	DeckID        int64     // Deck declared by the card file (0 for none)
+	Status        string    // active, archived or draft
+	PlainText     bool      // Shown as written rather than as Markdown, set by the deck
 	CreatedAt     time.Time // When the card was created
 }
 
+// Card statuses. Archived cards keep their review history but are no longer
+// studied. Drafts are cards that are not finished yet; they wait in the inbox
+// and are not studied until they are promoted.
+const (
+	CardStatusActive   = "active"
+	CardStatusArchived = "archived"
+	CardStatusDraft    = "draft"
+)
+
+// Prompt types a card can have
+var promptTypes = []string{"factual", "conceptual", "application", "comparison"}
+
+// NormalizePromptType maps user input such as "Conceptual" or "Factual Recall"
+// to one of the stored prompt types
+func NormalizePromptType(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "factual recall" {
+		return "factual", true
+	}
+	for _, promptType := range promptTypes {
+		if value == promptType {
+			return promptType, true
+		}
+	}
+	return "", false
+}
+
+// normalizeTags converts a tag list separated by commas and/or whitespace,
+// with or without leading "#", to the comma-separated form stored on cards
+func normalizeTags(tags string) string {
+	fields := strings.FieldsFunc(tags, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+
+	var normalized []string
+	for _, tag := range fields {
+		tag = strings.TrimLeft(tag, "#")
+		if tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	return strings.Join(normalized, ", ")
+}
+
 type ParseError struct {
-	LineNum int
-	Line    string
-	Reason  string
+	FilePath string // Set when the result covers more than one file
+	LineNum  int
+	Line     string
+	Reason   string
 }
 
 type ParseResult struct {
@@ -34,59 +87,173 @@ type ParseResult struct {
 	TotalLines  int
 	ValidCards  int
 	SkippedLines int
+	Deck         string       // Deck named by the file's front matter
+	Settings     DeckSettings // Scheduling options from the front matter
+	Updated      int          // Previously imported cards changed in place
+	Removed      []Card       // Previously imported cards no longer in the file
+	Files        []string     // Files parsed when a directory was loaded
+	Encoding     string       // Encoding the file was read in, or a list for a directory
 }
 
 type CardParser struct {
 	cards       []Card
 	parseResult *ParseResult
 	currentFile string
+	currentDir  string // Directory whose files were loaded, if any
 	cardRepo    CardRepository
+	noteRepo    NoteRepository
+	deckRepo    DeckRepository
+	defaults    cardMetadata   // From the current file's front matter or path
+	separator   string         // Separator declared by the current file, if any
+	pending     []*parsedEntry // Parsed but not yet written to the database
+	writeBack   bool           // Write cards added or edited in the app to their file
+	importRun   *importRun     // Set while ImportFile or ImportDirectory runs
+	media       *MediaStore    // Where images that cards refer to are copied
+}
+
+// NewCardParser returns a parser that only reads card files, without storing
+// their cards
+func NewCardParser() *CardParser {
+	return &CardParser{
+		cards: make([]Card, 0),
+	}
 }
 
-func NewCardParserWithDatabase(cardRepo CardRepository) *CardParser {
+func NewCardParserWithDatabase(cardRepo CardRepository, noteRepo NoteRepository, deckRepo DeckRepository) *CardParser {
 	return &CardParser{
 		cards:    make([]Card, 0),
 		cardRepo: cardRepo,
+		noteRepo: noteRepo,
+		deckRepo: deckRepo,
+	}
+}
+
+// Limits on card content. Single-line cards are kept short because an
+// overly long side usually means a separator was missed; block cards are
+// meant for lists, code and paragraphs, so they get more room.
+const (
+	maxLineCardLength  = 1000
+	maxBlockCardLength = 10000
+)
+
+// Markers for the multi-line block syntax:
+//
+//	Q: What does this print?
+//	fmt.Println(1 << 3)
+//	A: 8
+//	---
+//
+// A block runs from its Q: line to its closing "---" line, or until a blank
+// line, the next Q: line or the end of the file. Blank lines inside fenced
+// code do not end it, and "#" lines inside a block are kept as content.
+// A block without an A: section is a cloze note if it has cloze markup.
+const (
+	blockQuestionPrefix = "Q:"
+	blockAnswerPrefix   = "A:"
+	blockDelimiter      = "---"
+)
+
+// isCardFile reports whether path has an extension card files are read from
+func isCardFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".txt")
+}
+
+// cardBlock collects the lines of a multi-line card while it is being parsed
+type cardBlock struct {
+	startLine int
+	firstLine string
+	lineCount int
+	question  []string
+	answer    []string
+	hasAnswer bool
+}
+
+func (b *cardBlock) addLine(text string) {
+	if b.hasAnswer {
+		b.answer = append(b.answer, text)
+	} else {
+		b.question = append(b.question, text)
 	}
 }
 
 func (cp *CardParser) LoadFromFile(filePath string) error {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
-	defer file.Close()
+
+	// Files from other tools may be UTF-16 or Windows-1252
+	content, encodingName, err := decodeCardFile(data)
+	if err != nil {
+		return fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
 
 	// Store current file path
 	cp.currentFile = filePath
+	cp.defaults = cardMetadata{}
+	cp.separator = ""
+	cp.pending = nil
 
 	// Initialize parse result
 	cp.parseResult = &ParseResult{
-		Cards:  make([]Card, 0),
-		Errors: make([]ParseError, 0),
+		Cards:    make([]Card, 0),
+		Errors:   make([]ParseError, 0),
+		Encoding: encodingName,
 	}
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
+	if err := cp.parse(strings.NewReader(content)); err != nil {
+		return fmt.Errorf("error reading file %s: %w", filePath, err)
+	}
 
+	return cp.syncPending()
+}
+
+// parse reads card file content into the parse result and the pending
+// entries
+func (cp *CardParser) parse(r io.Reader) error {
+	// Blocks are found on the whole content, as card files are written back
+	var lines []string
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		lineNum++
-		cp.parseResult.TotalLines++
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
 
-		line := scanner.Text()
+	var frontMatterFence string
+	var frontMatterLines []string
+
+	cp.parseResult.TotalLines += len(lines)
+	for i := 0; i < len(lines); i++ {
+		rawLine := lines[i]
+		lineNum := i + 1
 
 		// Check for valid UTF-8
-		if !utf8.ValidString(line) {
-			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-				LineNum: lineNum,
-				Line:    line,
-				Reason:  "Invalid UTF-8 encoding",
-			})
-			cp.parseResult.SkippedLines++
+		if !utf8.ValidString(rawLine) {
+			cp.skipLine(lineNum, rawLine, "Invalid UTF-8 encoding")
 			continue
 		}
 
-		line = strings.TrimSpace(line)
+		line := strings.TrimSpace(rawLine)
+
+		// Front matter is only recognised on the first line of the file
+		if lineNum == 1 {
+			if isFrontMatterFence(line) {
+				frontMatterFence = line
+				continue
+			}
+			cp.usePathDeck()
+		}
+		if frontMatterFence != "" {
+			if line == frontMatterFence {
+				cp.finishFrontMatter(frontMatterFence, frontMatterLines)
+				frontMatterFence = ""
+				continue
+			}
+			frontMatterLines = append(frontMatterLines, rawLine)
+			continue
+		}
 
 		// Skip empty lines and comments
 		if line == "" {
@@ -96,104 +263,266 @@ func (cp *CardParser) LoadFromFile(filePath string) error {
 			continue
 		}
 
-		// Try multiple separators
-		separators := []string{">>", "::", "|"}
-		var parts []string
+		if startsBlock(line, cp.lineSeparators()) {
+			end := blockEnd(lines, i)
+			cp.parseBlock(lines[i:end], lineNum)
+			i = end - 1
+			continue
+		}
 
-		for _, sep := range separators {
-			if strings.Contains(line, sep) {
-				parts = strings.Split(line, sep)
-				break
+		// A stray delimiter between single-line cards is harmless
+		if _, ok := parseBlockDelimiter(line); ok {
+			continue
+		}
+
+		cp.parseLine(line, lineNum)
+	}
+
+	if frontMatterFence != "" {
+		cp.skipLine(1, frontMatterFence, fmt.Sprintf("Front matter is never closed with %s", frontMatterFence))
+		cp.parseResult.SkippedLines += len(frontMatterLines)
+	}
+
+	return nil
+}
+
+// finishFrontMatter parses the front matter once its closing fence is read.
+// Invalid front matter is reported on the first line and the file is parsed
+// without defaults.
+func (cp *CardParser) finishFrontMatter(fence string, lines []string) {
+	fm, err := parseFrontMatter(fence, lines)
+	if err == nil {
+		err = cp.applyFrontMatter(fm)
+	}
+	if err != nil {
+		cp.defaults = cardMetadata{}
+		cp.skipLine(1, fence, fmt.Sprintf("Invalid front matter: %v", err))
+		cp.parseResult.SkippedLines += len(lines) + 1
+		cp.usePathDeck()
+	}
+}
+
+// parseLine handles the single-line question>>answer, question::answer,
+// question|answer and front<>back forms. A separator that belongs to the text
+// is escaped with a backslash or put in "quotes" or `code`.
+func (cp *CardParser) parseLine(line string, lineNum int) {
+	// Cloze markup contains "::", so it has to be recognised before separators
+	if HasCloze(line) {
+		text, meta := extractInlineMetadata(line)
+		if len(text) > maxLineCardLength {
+			cp.skipLine(lineNum, line, fmt.Sprintf("Cloze note exceeds %d characters - possible parsing error", maxLineCardLength))
+			return
+		}
+		cp.storeNote(NoteTypeCloze, text, meta, lineNum, line)
+		return
+	}
+
+	// "<>" creates a card in each direction
+	separators := cp.lineSeparators()
+	questionPart, answerPart, separator, reason := splitCardLine(line, separators)
+	if reason != "" {
+		// Nested tags such as #lang::go contain "::", which only separates
+		// the question from the answer before the metadata at the end
+		if text, _ := extractInlineMetadata(line); len(text) < len(line) {
+			if q, a, sep, r := splitCardLine(text, separators); r == "" {
+				questionPart, answerPart, separator, reason = q, a+line[len(text):], sep, ""
 			}
 		}
+	}
+	if reason != "" {
+		cp.skipLine(lineNum, line, reason)
+		return
+	}
 
-		if len(parts) != 2 {
-			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-				LineNum: lineNum,
-				Line:    line,
-				Reason:  fmt.Sprintf("No valid separator found. Expected one of: %s", strings.Join(separators, ", ")),
-			})
-			cp.parseResult.SkippedLines++
-			continue
+	// Metadata follows the answer
+	answer, meta := extractInlineMetadata(answerPart)
+
+	question := unescapeCardText(questionPart, separators)
+	answer = unescapeCardText(answer, separators)
+
+	// Validate question and answer
+	if question == "" {
+		cp.skipLine(lineNum, line, fmt.Sprintf("Empty question part before separator \"%s\"", separator))
+		return
+	}
+
+	if answer == "" {
+		cp.skipLine(lineNum, line, fmt.Sprintf("Empty answer part after separator \"%s\"", separator))
+		return
+	}
+
+	// Check for extremely long content (might indicate parsing error)
+	if len(question) > maxLineCardLength || len(answer) > maxLineCardLength {
+		cp.skipLine(lineNum, line, fmt.Sprintf("Question or answer exceeds %d characters when split on \"%s\" - possible parsing error", maxLineCardLength, separator))
+		return
+	}
+
+	if separator == reverseSeparator {
+		cp.storeNote(NoteTypeReverse, ReverseNoteContent(question, answer), meta, lineNum, line)
+		return
+	}
+
+	cp.storeCard(question, answer, meta, lineNum, line)
+}
+
+// lineSeparators returns the separators single-line cards in the current file
+// may use: the one its front matter declares, or the default ones
+func (cp *CardParser) lineSeparators() []string {
+	if cp.separator != "" {
+		return []string{cp.separator}
+	}
+	return defaultSeparators
+}
+
+// startsBlock reports whether line opens a card block. A "Q:" line that
+// already holds a whole single-line card, "Q: What is 2+2? >> 4", does not.
+func startsBlock(line string, separators []string) bool {
+	if !strings.HasPrefix(line, blockQuestionPrefix) {
+		return false
+	}
+	if HasCloze(line) {
+		return true
+	}
+	_, _, _, reason := splitCardLine(line, separators)
+	return reason != ""
+}
+
+// blockEnd returns the index just past the block whose Q: line is
+// lines[start]: past its closing "---", or at the blank line outside fenced
+// code or the "Q:" line that ends it. Card files are parsed and written back
+// with the same blocks.
+func blockEnd(lines []string, start int) int {
+	opensFence := func(line string) bool {
+		line = strings.TrimPrefix(strings.TrimPrefix(line, blockQuestionPrefix), blockAnswerPrefix)
+		return strings.HasPrefix(strings.TrimSpace(line), "```")
+	}
+
+	inCode := opensFence(strings.TrimSpace(lines[start]))
+	for i := start + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if _, ok := parseBlockDelimiter(line); ok {
+			return i + 1
+		}
+		switch {
+		case strings.HasPrefix(line, blockQuestionPrefix):
+			return i
+		case opensFence(line):
+			inCode = !inCode
+		case line == "" && !inCode:
+			return i
 		}
+	}
+	return len(lines)
+}
+
+// parseBlock stores the card or note of a block, given its lines up to where
+// blockEnd ends it
+func (cp *CardParser) parseBlock(lines []string, startLine int) {
+	firstLine := strings.TrimSpace(lines[0])
+	block := &cardBlock{
+		startLine: startLine,
+		firstLine: firstLine,
+		lineCount: 1,
+	}
+	block.addLine(strings.TrimSpace(strings.TrimPrefix(firstLine, blockQuestionPrefix)))
 
-		question := strings.TrimSpace(parts[0])
-		answer := strings.TrimSpace(parts[1])
-
-		// Validate question and answer
-		if question == "" {
-			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-				LineNum: lineNum,
-				Line:    line,
-				Reason:  "Empty question part",
-			})
-			cp.parseResult.SkippedLines++
+	var meta cardMetadata
+	for i, rawLine := range lines[1:] {
+		if !utf8.ValidString(rawLine) {
+			cp.skipLine(startLine+1+i, rawLine, "Invalid UTF-8 encoding")
 			continue
 		}
+		line := strings.TrimSpace(rawLine)
+		block.lineCount++
 
-		if answer == "" {
-			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-				LineNum: lineNum,
-				Line:    line,
-				Reason:  "Empty answer part",
-			})
-			cp.parseResult.SkippedLines++
+		if delimiterMeta, ok := parseBlockDelimiter(line); ok {
+			meta = delimiterMeta
 			continue
 		}
-
-		// Check for extremely long content (might indicate parsing error)
-		if len(question) > 1000 || len(answer) > 1000 {
-			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-				LineNum: lineNum,
-				Line:    line,
-				Reason:  "Question or answer exceeds 1000 characters - possible parsing error",
-			})
-			cp.parseResult.SkippedLines++
+		if !block.hasAnswer && strings.HasPrefix(line, blockAnswerPrefix) {
+			block.hasAnswer = true
+			block.addLine(strings.TrimSpace(strings.TrimPrefix(line, blockAnswerPrefix)))
 			continue
 		}
 
-		card := Card{
-			Question: question,
-			Answer:   answer,
-			FilePath: filePath,
-			LineNum:  lineNum,
-		}
+		// Keep indentation for code, but drop trailing whitespace
+		block.addLine(strings.TrimRight(rawLine, " \t\r"))
+	}
 
-		// Store in memory for immediate access
-		cp.cards = append(cp.cards, card)
-		cp.parseResult.Cards = append(cp.parseResult.Cards, card)
-		cp.parseResult.ValidCards++
-
-		// Store in database
-		if cp.cardRepo != nil {
-			// Check if card already exists to avoid duplicates
-			exists, err := cp.cardRepo.CardExists(question, answer)
-			if err != nil {
-				cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-					LineNum: lineNum,
-					Line:    line,
-					Reason:  fmt.Sprintf("Failed to check card existence: %v", err),
-				})
-			} else if !exists {
-				// Only import if card doesn't exist
-				_, err := cp.cardRepo.ImportFromText(question, answer, filePath, lineNum)
-				if err != nil {
-					// Log error but continue processing other cards
-					cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
-						LineNum: lineNum,
-						Line:    line,
-						Reason:  fmt.Sprintf("Database import failed: %v", err),
-					})
-				}
-			}
-		}
+	cp.finishBlock(block, meta)
+}
+
+// finishBlock validates a completed block and stores it as a card. Errors are
+// reported against the line the block started on.
+func (cp *CardParser) finishBlock(block *cardBlock, meta cardMetadata) {
+	question := strings.TrimSpace(strings.Join(block.question, "\n"))
+	answer := strings.TrimSpace(strings.Join(block.answer, "\n"))
+
+	isCloze := !block.hasAnswer && HasCloze(question)
+
+	var reason string
+	switch {
+	case isCloze && len(question) > maxBlockCardLength:
+		reason = fmt.Sprintf("Cloze note in card block exceeds %d characters", maxBlockCardLength)
+	case isCloze:
+		cp.storeNote(NoteTypeCloze, question, meta, block.startLine, block.firstLine)
+		return
+	case !block.hasAnswer:
+		reason = "Card block has no A: section"
+	case question == "":
+		reason = "Empty question part in card block"
+	case answer == "":
+		reason = "Empty answer part in card block"
+	case len(question) > maxBlockCardLength || len(answer) > maxBlockCardLength:
+		reason = fmt.Sprintf("Question or answer in card block exceeds %d characters", maxBlockCardLength)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file %s: %w", filePath, err)
+	if reason != "" {
+		cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+			LineNum: block.startLine,
+			Line:    block.firstLine,
+			Reason:  reason,
+		})
+		cp.parseResult.SkippedLines += block.lineCount
+		return
 	}
 
-	return nil
+	cp.storeCard(question, answer, meta, block.startLine, block.firstLine)
+}
+
+func (cp *CardParser) skipLine(lineNum int, line, reason string) {
+	cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+		LineNum: lineNum,
+		Line:    line,
+		Reason:  reason,
+	})
+	cp.parseResult.SkippedLines++
+}
+
+// storeCard records a parsed card in memory. It is written to the database
+// once the whole file has been read.
+func (cp *CardParser) storeCard(question, answer string, meta cardMetadata, lineNum int, line string) {
+	meta = meta.withDefaults(cp.defaults)
+
+	card := Card{
+		Question: question,
+		Answer:   answer,
+		FilePath: cp.currentFile,
+		LineNum:  lineNum,
+	}
+	meta.apply(&card)
+
+	// Store in memory for immediate access
+	cp.cards = append(cp.cards, card)
+	cp.parseResult.Cards = append(cp.parseResult.Cards, card)
+	cp.parseResult.ValidCards++
+
+	cp.pending = append(cp.pending, &parsedEntry{
+		lineNum: lineNum,
+		line:    line,
+		cards:   []Card{card},
+		meta:    meta,
+	})
 }
 
 func (cp *CardParser) GetCards() []Card {
@@ -205,25 +534,12 @@ func (cp *CardParser) GetCards() []Card {
 			return cp.cards
 		}
 
+		plainDecks := cp.plainTextDecks()
+
 		// Convert DB cards to Card structs
 		var cards []Card
 		for _, dbCard := range dbCards {
-			sourceContext := ""
-			if dbCard.SourceContext.Valid {
-				sourceContext = dbCard.SourceContext.String
-			}
-			card := Card{
-				ID:            dbCard.ID,
-				Question:      dbCard.Question,
-				Answer:        dbCard.Answer,
-				FilePath:      dbCard.SourceFile,
-				LineNum:       dbCard.SourceLine,
-				SourceContext: sourceContext,
-				PromptType:    dbCard.PromptType,
-				Tags:          dbCard.Tags,
-				CreatedAt:     dbCard.CreatedAt,
-			}
-			cards = append(cards, card)
+			cards = append(cards, cardFromDB(dbCard, plainDecks))
 		}
 		return cards
 	}
@@ -231,6 +547,83 @@ func (cp *CardParser) GetCards() []Card {
 	return cp.cards
 }
 
+// GetDeckCards returns the cards of a deck and of the decks nested in it
+func (cp *CardParser) GetDeckCards(deckID int64) []Card {
+	if cp.cardRepo != nil {
+		dbCards, err := cp.cardRepo.GetByDeckID(deckID)
+		if err != nil {
+			return nil
+		}
+
+		plainDecks := cp.plainTextDecks()
+
+		var cards []Card
+		for _, dbCard := range dbCards {
+			cards = append(cards, cardFromDB(dbCard, plainDecks))
+		}
+		return cards
+	}
+
+	// Without a database decks are not nested
+	var cards []Card
+	for _, card := range cp.cards {
+		if card.DeckID == deckID {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// GetDecks returns all decks, ordered by name
+func (cp *CardParser) GetDecks() ([]*DBDeck, error) {
+	if cp.deckRepo == nil {
+		return nil, nil
+	}
+	return cp.deckRepo.GetAll()
+}
+
+func cardFromDB(dbCard *DBCard, plainDecks map[int64]bool) Card {
+	sourceContext := ""
+	if dbCard.SourceContext.Valid {
+		sourceContext = dbCard.SourceContext.String
+	}
+	return Card{
+		ID:            dbCard.ID,
+		Question:      dbCard.Question,
+		Answer:        dbCard.Answer,
+		FilePath:      dbCard.SourceFile,
+		LineNum:       dbCard.SourceLine,
+		SourceContext: sourceContext,
+		PromptType:    dbCard.PromptType,
+		Tags:          dbCard.Tags,
+		NoteID:        dbCard.NoteID.Int64,
+		Ordinal:       dbCard.Ordinal,
+		DeckID:        dbCard.DeckID.Int64,
+		Status:        dbCard.Status,
+		PlainText:     plainDecks[dbCard.DeckID.Int64],
+		CreatedAt:     dbCard.CreatedAt,
+	}
+}
+
+// plainTextDecks returns the IDs of the decks whose cards are shown as plain
+// text
+func (cp *CardParser) plainTextDecks() map[int64]bool {
+	plain := make(map[int64]bool)
+	if cp.deckRepo == nil {
+		return plain
+	}
+	decks, err := cp.deckRepo.GetAll()
+	if err != nil {
+		return plain
+	}
+	for _, deck := range decks {
+		if deck.TextFormat == TextFormatPlain {
+			plain[deck.ID] = true
+		}
+	}
+	return plain
+}
+
 func (cp *CardParser) GetCardCount() int {
 	// Get count from database
 	if cp.cardRepo != nil {
@@ -250,9 +643,39 @@ func (cp *CardParser) GetParseReport() string {
 	}
 
 	report := fmt.Sprintf("Parse Summary:\n")
+	if len(cp.parseResult.Files) > 0 {
+		report += fmt.Sprintf("- Files parsed: %d\n", len(cp.parseResult.Files))
+	}
 	report += fmt.Sprintf("- Total lines processed: %d\n", cp.parseResult.TotalLines)
 	report += fmt.Sprintf("- Valid cards created: %d\n", cp.parseResult.ValidCards)
 	report += fmt.Sprintf("- Lines skipped: %d\n", cp.parseResult.SkippedLines)
+	if cp.parseResult.Encoding != "" {
+		report += fmt.Sprintf("- Encoding: %s\n", cp.parseResult.Encoding)
+	}
+	if cp.parseResult.Deck != "" {
+		report += fmt.Sprintf("- Deck: %s\n", cp.parseResult.Deck)
+	}
+	if settings := describeSettings(cp.parseResult.Settings); settings != "" {
+		report += fmt.Sprintf("- Scheduling: %s\n", settings)
+	}
+	if cp.parseResult.Updated > 0 {
+		report += fmt.Sprintf("- Cards updated in place: %d\n", cp.parseResult.Updated)
+	}
+
+	if len(cp.parseResult.Removed) > 0 {
+		report += fmt.Sprintf("\nNo Longer in File (%d):\n", len(cp.parseResult.Removed))
+		for i, card := range cp.parseResult.Removed {
+			if i >= 10 {
+				report += fmt.Sprintf("... and %d more cards\n", len(cp.parseResult.Removed)-10)
+				break
+			}
+			question := card.Question
+			if len(question) > 50 {
+				question = question[:47] + "..."
+			}
+			report += fmt.Sprintf("  %s: %s\n", cp.describeLocation(card.FilePath, card.LineNum), question)
+		}
+	}
 
 	if len(cp.parseResult.Errors) > 0 {
 		report += fmt.Sprintf("\nParsing Issues (%d):\n", len(cp.parseResult.Errors))
@@ -265,13 +688,30 @@ func (cp *CardParser) GetParseReport() string {
 			if len(line) > 50 {
 				line = line[:47] + "..."
 			}
-			report += fmt.Sprintf("  Line %d: %s - %s\n", err.LineNum, line, err.Reason)
+			report += fmt.Sprintf("  %s: %s - %s\n", cp.describeLocation(err.FilePath, err.LineNum), line, err.Reason)
 		}
 	}
 
 	return report
 }
 
+// describeLocation names a line for the parse report, including the file
+// when a directory was loaded
+func (cp *CardParser) describeLocation(filePath string, lineNum int) string {
+	if cp.parseResult == nil || len(cp.parseResult.Files) == 0 || filePath == "" {
+		return fmt.Sprintf("Line %d", lineNum)
+	}
+
+	name := filePath
+	if rel, err := filepath.Rel(cp.currentDir, filePath); err == nil {
+		name = rel
+	}
+	if lineNum == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s line %d", name, lineNum)
+}
+
 func (cp *CardParser) HasParseErrors() bool {
 	return cp.parseResult != nil && len(cp.parseResult.Errors) > 0
 }
@@ -285,6 +725,9 @@ func (cp *CardParser) AddCardWithMetadata(question, answer, source, promptType,
 		return fmt.Errorf("question and answer cannot be empty")
 	}
 
+	// Store tags the same way card files do, whether typed as "#go #cli" or "go, cli"
+	tags = normalizeTags(tags)
+
 	// Check if card already exists
 	if cp.cardRepo != nil {
 		exists, err := cp.cardRepo.CardExists(question, answer)
@@ -294,19 +737,32 @@ func (cp *CardParser) AddCardWithMetadata(question, answer, source, promptType,
 		if exists {
 			return fmt.Errorf("card with this question and answer already exists")
 		}
+	}
+
+	meta := cardMetadata{SourceContext: source, PromptType: promptType, Tags: tags}
+	sourceLine := len(cp.cards) + 1
+	if cp.writeBackFile() != "" {
+		var err error
+		if sourceLine, err = cp.appendCardText(cardText{first: question, second: answer, meta: meta}); err != nil {
+			return fmt.Errorf("failed to write card to %s: %w", cp.currentFile, err)
+		}
+		// The card gets the file's defaults, as it will when the file is reloaded
+		meta = meta.withDefaults(cp.defaults)
+	}
 
+	if cp.cardRepo != nil {
 		// Add to database with metadata
-		sourceContext := sql.NullString{String: source, Valid: source != ""}
 		dbCard := &DBCard{
 			Question:      question,
 			Answer:        answer,
 			SourceFile:    cp.currentFile,
-			SourceLine:    len(cp.cards) + 1,
-			SourceContext: sourceContext,
-			PromptType:    promptType,
-			Tags:          tags,
+			SourceLine:    sourceLine,
+			SourceContext: sql.NullString{String: meta.SourceContext, Valid: meta.SourceContext != ""},
+			PromptType:    meta.PromptType,
+			Tags:          meta.Tags,
+			DeckID:        sql.NullInt64{Int64: meta.DeckID, Valid: meta.DeckID != 0},
 		}
-		err = cp.cardRepo.Create(dbCard)
+		err := cp.cardRepo.Create(dbCard)
 		if err != nil {
 			return fmt.Errorf("failed to add card to database: %w", err)
 		}
@@ -314,15 +770,13 @@ func (cp *CardParser) AddCardWithMetadata(question, answer, source, promptType,
 
 	// Create new card for memory cache
 	newCard := Card{
-		Question:      question,
-		Answer:        answer,
-		FilePath:      cp.currentFile,
-		LineNum:       len(cp.cards) + 1,
-		SourceContext: source,
-		PromptType:    promptType,
-		Tags:          tags,
-		CreatedAt:     time.Now(),
+		Question:  question,
+		Answer:    answer,
+		FilePath:  cp.currentFile,
+		LineNum:   sourceLine,
+		CreatedAt: time.Now(),
 	}
+	meta.apply(&newCard)
 
 	// Add to memory
 	cp.cards = append(cp.cards, newCard)
@@ -345,6 +799,14 @@ func (cp *CardParser) UpdateCard(cardID int64, question, answer string) error {
 		return fmt.Errorf("failed to get card: %w", err)
 	}
 
+	if cp.canWriteBack(existingCard.SourceFile) && !existingCard.NoteID.Valid {
+		before := cardText{first: existingCard.Question, second: existingCard.Answer}
+		after := cardText{first: question, second: answer}
+		if err := cp.rewriteCardText(existingCard.SourceFile, existingCard.SourceLine, before, after); err != nil {
+			return fmt.Errorf("failed to write card to %s: %w", existingCard.SourceFile, err)
+		}
+	}
+
 	// Update the card
 	existingCard.Question = question
 	existingCard.Answer = answer
@@ -376,11 +838,12 @@ func (cp *CardParser) GetCurrentFile() string {
 }
 
 func (cp *CardParser) HasFile() bool {
-	return cp.currentFile != ""
+	return cp.currentFile != "" || cp.currentDir != ""
 }
 
 func (cp *CardParser) Clear() {
 	cp.cards = cp.cards[:0]
 	cp.parseResult = nil
 	cp.currentFile = ""
+	cp.currentDir = ""
 }
\ No newline at end of file
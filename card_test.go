@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFromFileBlocks(t *testing.T) {
+	type qa struct{ Q, A string }
+
+	tests := []struct {
+		name    string
+		content string
+		cards   []qa
+		errors  []int // Lines parse errors are reported on
+	}{
+		{
+			name:    "single-line cards",
+			content: "What is Go?>>a language\nWhat is Rust? :: another language\n",
+			cards:   []qa{{"What is Go?", "a language"}, {"What is Rust?", "another language"}},
+		},
+		{
+			name:    "closed block",
+			content: "Q: What does this print?\nfmt.Println(1 << 3)\nA: 8\n---\n",
+			cards:   []qa{{"What does this print?\nfmt.Println(1 << 3)", "8"}},
+		},
+		{
+			name:    "blank line ends a block",
+			content: "Q: Block question\nA: Block answer\n\nWhat is Rust? >> another language\n",
+			cards:   []qa{{"Block question", "Block answer"}, {"What is Rust?", "another language"}},
+		},
+		{
+			name:    "blank line ends a block closed further down",
+			content: "Q: Block question\nA: First paragraph\n\nSecond paragraph\n---\nWhat is Rust? >> another language\n",
+			cards:   []qa{{"Block question", "First paragraph"}, {"What is Rust?", "another language"}},
+			errors:  []int{4},
+		},
+		{
+			name:    "closing delimiter with metadata",
+			content: "Q: Block question\nA: Block answer\n--- #go\nWhat is Rust? >> another language\n",
+			cards:   []qa{{"Block question", "Block answer"}, {"What is Rust?", "another language"}},
+		},
+		{
+			name:    "blank line in fenced code",
+			content: "Q: What does this print?\nA:\n```go\nx := 1\n\nfmt.Println(x)\n```\n\nnext>>card\n",
+			cards:   []qa{{"What does this print?", "```go\nx := 1\n\nfmt.Println(x)\n```"}, {"next", "card"}},
+		},
+		{
+			name:    "next Q: ends a block",
+			content: "Q: First\nA: one\nQ: Second\nA: two\n",
+			cards:   []qa{{"First", "one"}, {"Second", "two"}},
+		},
+		{
+			name:    "single-line card after Q:",
+			content: "Q: What is 2+2? >> 4\nWhat is Go?>>a language\n",
+			cards:   []qa{{"Q: What is 2+2?", "4"}, {"What is Go?", "a language"}},
+		},
+		{
+			name:    "single-line card after Q: ends a block",
+			content: "Q: Block question\nA: Block answer\nQ: What is 2+2? >> 4\n",
+			cards:   []qa{{"Block question", "Block answer"}, {"Q: What is 2+2?", "4"}},
+		},
+		{
+			name:    "block without answer is reported on its first line",
+			content: "What is Go?>>a language\nQ: No answer here\nstill the question\n\nWhat is Rust? >> another language\n",
+			cards:   []qa{{"What is Go?", "a language"}, {"What is Rust?", "another language"}},
+			errors:  []int{2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cards.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cp := NewCardParser()
+			if err := cp.LoadFromFile(path); err != nil {
+				t.Fatalf("LoadFromFile: %v", err)
+			}
+
+			var cards []qa
+			for _, card := range cp.GetCards() {
+				cards = append(cards, qa{card.Question, card.Answer})
+			}
+			if !reflect.DeepEqual(cards, test.cards) {
+				t.Errorf("cards = %q, want %q", cards, test.cards)
+			}
+
+			var errors []int
+			for _, parseErr := range cp.parseResult.Errors {
+				errors = append(errors, parseErr.LineNum)
+			}
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("errors on lines %v, want %v (%+v)", errors, test.errors, cp.parseResult.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Names of the encodings card files are read in, as shown in the parse report
+const (
+	EncodingUTF8        = "UTF-8"
+	EncodingUTF8BOM     = "UTF-8 with BOM"
+	EncodingUTF16LE     = "UTF-16LE"
+	EncodingUTF16BE     = "UTF-16BE"
+	EncodingWindows1252 = "Windows-1252"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectEncoding works out the encoding of a card file from its byte order
+// mark, or failing that from its content. Files without a BOM are UTF-16 when
+// every other byte is zero, UTF-8 when they are valid UTF-8, and otherwise
+// taken to be Windows-1252, which older Windows tools write and which can
+// decode any byte.
+func detectEncoding(data []byte) (string, encoding.Encoding) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return EncodingUTF8BOM, unicode.UTF8BOM
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+
+	if name := sniffUTF16(data); name == EncodingUTF16LE {
+		return name, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	} else if name == EncodingUTF16BE {
+		return name, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+
+	if utf8.Valid(data) {
+		return EncodingUTF8, nil
+	}
+	return EncodingWindows1252, charmap.Windows1252
+}
+
+// sniffUTF16 recognises UTF-16 without a BOM by the zero high bytes of ASCII
+// characters, which mostly make up a card file
+func sniffUTF16(data []byte) string {
+	const sample = 1024
+	if len(data) > sample {
+		data = data[:sample]
+	}
+	if len(data) < 4 {
+		return ""
+	}
+
+	var evenZeros, oddZeros int
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+
+	pairs := len(data) / 2
+	switch {
+	case oddZeros > pairs*3/5 && evenZeros < pairs/10:
+		return EncodingUTF16LE
+	case evenZeros > pairs*3/5 && oddZeros < pairs/10:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// decodeCardFile converts the content of a card file to UTF-8 and returns it
+// together with the name of the encoding it was in
+func decodeCardFile(data []byte) (string, string, error) {
+	name, enc := detectEncoding(data)
+	if enc == nil {
+		return string(data), name, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", name, fmt.Errorf("failed to convert from %s: %w", name, err)
+	}
+	return string(decoded), name, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeCardFile(t *testing.T) {
+	const text = "Café? >> “Coffee” – in French\n"
+
+	encode := func(enc encoding.Encoding) []byte {
+		data, err := enc.NewEncoder().Bytes([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+	}{
+		{"UTF-8", []byte(text), EncodingUTF8},
+		{"UTF-8 with BOM", append(append([]byte{}, utf8BOM...), text...), EncodingUTF8BOM},
+		{"UTF-16LE with BOM", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)), EncodingUTF16LE},
+		{"UTF-16BE with BOM", encode(unicode.UTF16(unicode.BigEndian, unicode.UseBOM)), EncodingUTF16BE},
+		{"UTF-16LE without BOM", encode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)), EncodingUTF16LE},
+		{"UTF-16BE without BOM", encode(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)), EncodingUTF16BE},
+		{"Windows-1252", encode(charmap.Windows1252), EncodingWindows1252},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, name, err := decodeCardFile(test.data)
+			if err != nil {
+				t.Fatalf("decodeCardFile: %v", err)
+			}
+			if name != test.encoding {
+				t.Errorf("encoding = %q, want %q", name, test.encoding)
+			}
+			if content != text {
+				t.Errorf("content = %q, want %q", content, text)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const defaultDatabasePath = "./spaced_repetition.db"
+
+const cliUsage = `Usage:
+  spaced                                  start the app
+  spaced lint [-json] PATH...             check card files for errors
+  spaced import [-db FILE] [-archive-removed] PATH...
+                                          load card files into the database
+
+PATH is a card file or a directory of card files.
+`
+
+// Exit codes of the commands
+const (
+	exitOK     = 0
+	exitErrors = 1 // Card files have errors
+	exitFailed = 2 // Bad usage, or a file or the database could not be used
+)
+
+// lintIssue is a parse error as printed by lint -json
+type lintIssue struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type lintReport struct {
+	Files  int         `json:"files"`
+	Cards  int         `json:"cards"`
+	Errors []lintIssue `json:"errors"`
+}
+
+// runCommand runs a command given on the command line and returns the exit
+// code. It reports false when args hold no command, so the app should start.
+func runCommand(args []string, stdout, stderr io.Writer) (int, bool) {
+	if len(args) == 0 {
+		return exitOK, false
+	}
+
+	switch args[0] {
+	case "lint":
+		return runLint(args[1:], stdout, stderr), true
+	case "import":
+		return runImport(args[1:], stdout, stderr), true
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+		return exitOK, true
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+	return exitFailed, true
+}
+
+func newCommandFlags(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, cliUsage)
+	}
+	return flags
+}
+
+// runLint parses card files without touching the database and prints their
+// errors, one per line as FILE:LINE: REASON, or as JSON
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := newCommandFlags("lint", stderr)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	if err := flags.Parse(args); err != nil {
+		return exitFailed
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "lint needs at least one card file\n\n%s", cliUsage)
+		return exitFailed
+	}
+
+	report := lintReport{Errors: make([]lintIssue, 0)}
+	for _, path := range flags.Args() {
+		cp := NewCardParser()
+		result, err := loadCardPath(cp, path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailed
+		}
+
+		report.Files += max(len(result.Files), 1)
+		report.Cards += result.ValidCards
+		for _, parseErr := range result.Errors {
+			file := parseErr.FilePath
+			if file == "" {
+				file = path
+			}
+			report.Errors = append(report.Errors, lintIssue{
+				File:   file,
+				Line:   parseErr.LineNum,
+				Text:   parseErr.Line,
+				Reason: parseErr.Reason,
+			})
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailed
+		}
+	} else {
+		for _, issue := range report.Errors {
+			fmt.Fprintln(stdout, formatLintIssue(issue))
+		}
+		fmt.Fprintf(stderr, "%d cards, %d errors in %d files\n", report.Cards, len(report.Errors), report.Files)
+	}
+
+	if len(report.Errors) > 0 {
+		return exitErrors
+	}
+	return exitOK
+}
+
+func formatLintIssue(issue lintIssue) string {
+	if issue.Line == 0 {
+		return fmt.Sprintf("%s: %s", issue.File, issue.Reason)
+	}
+	return fmt.Sprintf("%s:%d: %s", issue.File, issue.Line, issue.Reason)
+}
+
+// loadCardPath parses a card file or a directory of card files and returns
+// the parse result, with the file of every error set
+func loadCardPath(cp *CardParser, path string) (*ParseResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		err = cp.LoadDirectory(path)
+	} else {
+		err = cp.LoadFromFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := cp.GetParseResult()
+	for i := range result.Errors {
+		if result.Errors[i].FilePath == "" {
+			result.Errors[i].FilePath = path
+		}
+	}
+	return result, nil
+}
+
+// runImport loads card files into the database, each file or directory in
+// its own transaction. Interrupting the command rolls back the one running.
+func runImport(args []string, stdout, stderr io.Writer) int {
+	flags := newCommandFlags("import", stderr)
+	dbPath := flags.String("db", defaultDatabasePath, "database file")
+	archiveRemoved := flags.Bool("archive-removed", false, "archive cards that were removed from their file")
+	if err := flags.Parse(args); err != nil {
+		return exitFailed
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, "import needs at least one card file\n\n%s", cliUsage)
+		return exitFailed
+	}
+
+	database, err := NewDatabase(*dbPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailed
+	}
+	defer database.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cp := NewCardParserWithDatabase(
+		NewSQLiteCardRepository(database),
+		NewSQLiteNoteRepository(database),
+		NewSQLiteDeckRepository(database),
+	)
+	cp.SetMediaStore(NewMediaStore(mediaDir(*dbPath), NewSQLiteMediaRepository(database)))
+
+	exitCode := exitOK
+	for _, path := range flags.Args() {
+		info, err := os.Stat(path)
+		if err == nil {
+			if info.IsDir() {
+				err = cp.ImportDirectory(ctx, database, path, nil)
+			} else {
+				err = cp.ImportFile(ctx, database, path, nil)
+			}
+		}
+		if errors.Is(err, ErrImportCancelled) {
+			fmt.Fprintf(stderr, "%s: import cancelled, nothing was imported from it\n", path)
+			return exitFailed
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailed
+		}
+
+		result := cp.GetParseResult()
+		fmt.Fprintf(stdout, "%s: %d cards, %d updated, %d lines skipped\n", path, result.ValidCards, result.Updated, result.SkippedLines)
+		for _, parseErr := range result.Errors {
+			file := parseErr.FilePath
+			if file == "" {
+				file = path
+			}
+			fmt.Fprintln(stdout, "  "+formatLintIssue(lintIssue{File: file, Line: parseErr.LineNum, Reason: parseErr.Reason}))
+			exitCode = exitErrors
+		}
+
+		if len(result.Removed) == 0 {
+			continue
+		}
+		if !*archiveRemoved {
+			fmt.Fprintf(stdout, "  %d cards are no longer in the file, use -archive-removed to archive them\n", len(result.Removed))
+			continue
+		}
+		cardIDs := make([]int64, len(result.Removed))
+		for i, card := range result.Removed {
+			cardIDs[i] = card.ID
+		}
+		if err := cp.ArchiveCards(cardIDs); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitFailed
+		}
+		fmt.Fprintf(stdout, "  %d cards that are no longer in the file were archived\n", len(cardIDs))
+	}
+
+	return exitCode
+}
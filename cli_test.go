@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeCLIFiles writes card files for the command tests into a new directory
+func writeCLIFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"good.txt":      "What is Go?>>a language\nQ: Block\nA: card\n---\n",
+		"bad.txt":       "What is Go?>>a language\nno separator here\n",
+		"deck/more.txt": "What is Rust?>>another language\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string // DIR is replaced with the directory of card files
+		code    int
+		command bool
+		stdout  string // Part of what is printed
+		stderr  string
+	}{
+		{name: "no command starts the app", args: nil, code: exitOK},
+		{name: "help", args: []string{"help"}, code: exitOK, command: true, stdout: "Usage:"},
+		{name: "unknown command", args: []string{"serve"}, code: exitFailed, command: true, stderr: `unknown command "serve"`},
+
+		{name: "lint clean file", args: []string{"lint", "DIR/good.txt"}, code: exitOK, command: true, stderr: "2 cards, 0 errors in 1 files"},
+		{name: "lint clean directory", args: []string{"lint", "DIR/deck"}, code: exitOK, command: true, stderr: "1 cards, 0 errors in 1 files"},
+		{name: "lint file with errors", args: []string{"lint", "DIR/good.txt", "DIR/bad.txt"}, code: exitErrors, command: true, stdout: "bad.txt:2: "},
+		{name: "lint directory with errors", args: []string{"lint", "DIR"}, code: exitErrors, command: true, stderr: "4 cards, 1 errors in 3 files"},
+		{name: "lint missing file", args: []string{"lint", "DIR/missing.txt"}, code: exitFailed, command: true, stderr: "missing.txt"},
+		{name: "lint without files", args: []string{"lint"}, code: exitFailed, command: true, stderr: "lint needs at least one card file"},
+		{name: "lint unknown flag", args: []string{"lint", "-yaml", "DIR/good.txt"}, code: exitFailed, command: true, stderr: "-yaml"},
+
+		{name: "import clean file", args: []string{"import", "-db", "DIR/cards.db", "DIR/good.txt"}, code: exitOK, command: true, stdout: "good.txt: 2 cards, 0 updated, 0 lines skipped"},
+		{name: "import file with errors", args: []string{"import", "-db", "DIR/cards.db", "DIR/bad.txt"}, code: exitErrors, command: true, stdout: "bad.txt:2: "},
+		{name: "import missing file", args: []string{"import", "-db", "DIR/cards.db", "DIR/missing.txt"}, code: exitFailed, command: true, stderr: "missing.txt"},
+		{name: "import into unusable database", args: []string{"import", "-db", "DIR/missing/cards.db", "DIR/good.txt"}, code: exitFailed, command: true},
+		{name: "import without files", args: []string{"import", "-db", "DIR/cards.db"}, code: exitFailed, command: true, stderr: "import needs at least one card file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeCLIFiles(t)
+			var args []string
+			for _, arg := range test.args {
+				args = append(args, strings.ReplaceAll(arg, "DIR", dir))
+			}
+
+			var stdout, stderr bytes.Buffer
+			code, command := runCommand(args, &stdout, &stderr)
+			if code != test.code || command != test.command {
+				t.Errorf("runCommand(%q) = %d, %t; want %d, %t\nstdout: %s\nstderr: %s",
+					test.args, code, command, test.code, test.command, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), test.stdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout.String(), test.stdout)
+			}
+			if !strings.Contains(stderr.String(), test.stderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), test.stderr)
+			}
+		})
+	}
+}
+
+func TestLintJSON(t *testing.T) {
+	dir := writeCLIFiles(t)
+	var stdout, stderr bytes.Buffer
+	code, _ := runCommand([]string{"lint", "-json", filepath.Join(dir, "good.txt"), filepath.Join(dir, "bad.txt")}, &stdout, &stderr)
+	if code != exitErrors {
+		t.Errorf("exit code = %d, want %d", code, exitErrors)
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("printed %q to stderr, want only JSON on stdout", stderr.String())
+	}
+
+	var report lintReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout.String())
+	}
+	if report.Files != 2 || report.Cards != 3 || len(report.Errors) != 1 {
+		t.Fatalf("report = %+v, want 2 files, 3 cards and 1 error", report)
+	}
+	issue := report.Errors[0]
+	want := lintIssue{File: filepath.Join(dir, "bad.txt"), Line: 2, Text: "no separator here", Reason: issue.Reason}
+	if issue != want || issue.Reason == "" {
+		t.Errorf("error = %+v, want %+v with a reason", issue, want)
+	}
+
+	// A clean file still lists its errors, as an empty list
+	stdout.Reset()
+	runCommand([]string{"lint", "-json", filepath.Join(dir, "good.txt")}, &stdout, &stderr)
+	var raw map[string]interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if errors, ok := raw["errors"].([]interface{}); !ok || len(errors) != 0 {
+		t.Errorf("errors of a clean file = %v, want []", raw["errors"])
+	}
+}
+
+func TestImportArchiveRemoved(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "cards.db")
+	cardPath := filepath.Join(dir, "cards.txt")
+	importCards := func(content string, flags ...string) string {
+		t.Helper()
+		if err := os.WriteFile(cardPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		args := append(append([]string{"import", "-db", dbPath}, flags...), cardPath)
+		if code, _ := runCommand(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("import exited with %d: %s", code, stderr.String())
+		}
+		return stdout.String()
+	}
+	statuses := func() map[string]string {
+		t.Helper()
+		db, err := NewDatabase(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		statuses := make(map[string]string)
+		for question, dbCard := range storedCards(t, db, cardPath) {
+			statuses[question] = dbCard.Status
+		}
+		return statuses
+	}
+
+	importCards("Q1>>A1\nQ2>>A2\n")
+
+	out := importCards("Q2>>A2\n")
+	if !strings.Contains(out, "1 cards are no longer in the file, use -archive-removed to archive them") {
+		t.Errorf("output = %q, want a hint to archive the removed card", out)
+	}
+	if got, want := statuses(), map[string]string{"Q1": CardStatusActive, "Q2": CardStatusActive}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses without -archive-removed = %v, want %v", got, want)
+	}
+
+	out = importCards("Q2>>A2\n", "-archive-removed")
+	if !strings.Contains(out, "1 cards that are no longer in the file were archived") {
+		t.Errorf("output = %q, want the removed card archived", out)
+	}
+	if got, want := statuses(), map[string]string{"Q1": CardStatusArchived, "Q2": CardStatusActive}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses with -archive-removed = %v, want %v", got, want)
+	}
+
+	out = importCards("Q2>>A2\n", "-archive-removed")
+	if strings.Contains(out, "archived") {
+		t.Errorf("output = %q, want nothing archived again", out)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Cloze deletions use Anki-style markup: {{c1::term}} or {{c1::term::hint}}.
+// Every distinct cloze number in a note becomes its own card, in which that
+// number is hidden and all other deletions are shown as plain text.
+var clozePattern = regexp.MustCompile(`\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+
+func HasCloze(text string) bool {
+	return clozePattern.MatchString(text)
+}
+
+// ClozeNumbers returns the distinct cloze numbers used in text, in ascending order
+func ClozeNumbers(text string) []int {
+	seen := make(map[int]bool)
+	var numbers []int
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// BuildClozeCards expands a cloze note into one card per cloze number. The
+// card's Ordinal is its cloze number.
+func BuildClozeCards(text string) []Card {
+	var cards []Card
+	for _, n := range ClozeNumbers(text) {
+		cards = append(cards, Card{
+			Question: renderClozeQuestion(text, n),
+			Answer:   renderClozeAnswer(text, n),
+			Ordinal:  n,
+		})
+	}
+	return cards
+}
+
+func renderClozeQuestion(text string, number int) string {
+	return clozePattern.ReplaceAllStringFunc(text, func(markup string) string {
+		match := clozePattern.FindStringSubmatch(markup)
+		n, _ := strconv.Atoi(match[1])
+		if n != number {
+			return match[2]
+		}
+		if match[3] != "" {
+			return "[" + match[3] + "]"
+		}
+		return "[...]"
+	})
+}
+
+func renderClozeAnswer(text string, number int) string {
+	var terms []string
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(match[1])
+		if n == number {
+			terms = append(terms, strings.TrimSpace(match[2]))
+		}
+	}
+	return strings.Join(terms, ", ")
+}
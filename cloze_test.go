@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildClozeCards(t *testing.T) {
+	type qa struct {
+		Q, A    string
+		Ordinal int
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		cards []qa
+	}{
+		{
+			name:  "one deletion",
+			text:  "The {{c1::mitochondria}} is the powerhouse of the cell",
+			cards: []qa{{"The [...] is the powerhouse of the cell", "mitochondria", 1}},
+		},
+		{
+			name:  "hint",
+			text:  "{{c1::Paris::city}} is the capital of France",
+			cards: []qa{{"[city] is the capital of France", "Paris", 1}},
+		},
+		{
+			name: "siblings show the other deletions",
+			text: "{{c2::Go}} was announced in {{c1::2009}}",
+			cards: []qa{
+				{"Go was announced in [...]", "2009", 1},
+				{"[...] was announced in 2009", "Go", 2},
+			},
+		},
+		{
+			name:  "one number used twice",
+			text:  "{{c1::Salt}} and {{c1::pepper}}",
+			cards: []qa{{"[...] and [...]", "Salt, pepper", 1}},
+		},
+		{
+			name: "no deletions",
+			text: "Plain text",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cards []qa
+			for _, card := range BuildClozeCards(test.text) {
+				cards = append(cards, qa{card.Question, card.Answer, card.Ordinal})
+			}
+			if !reflect.DeepEqual(cards, test.cards) {
+				t.Errorf("BuildClozeCards(%q) = %q, want %q", test.text, cards, test.cards)
+			}
+		})
+	}
+}
+
+func TestClozeNumbers(t *testing.T) {
+	got := ClozeNumbers("{{c3::a}} {{c1::b}} {{c3::c}} {{c0::d}}")
+	if want := []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClozeNumbers = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cardTextBlock is a part of card text: Markdown, or a fenced code block
+type cardTextBlock struct {
+	text     string
+	code     bool
+	language string // Named after the opening fence, like ```go
+}
+
+// splitCodeBlocks cuts card text into Markdown and the fenced code blocks
+// between it. A code block that is never closed runs to the end of the text.
+func splitCodeBlocks(text string) []cardTextBlock {
+	var blocks []cardTextBlock
+	var lines []string
+	var fence string
+	var indent int
+	language := ""
+
+	flush := func(code bool) {
+		if len(lines) > 0 || code {
+			blocks = append(blocks, cardTextBlock{text: strings.Join(lines, "\n"), code: code, language: language})
+		}
+		lines = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if open := codeFence(trimmed); open != "" {
+				flush(false)
+				fence = open
+				indent = len(line) - len(strings.TrimLeft(line, " "))
+				language = strings.ToLower(firstWord(strings.TrimSpace(trimmed[len(open):])))
+				continue
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			flush(true)
+			fence, language = "", ""
+			continue
+		}
+		// Code is indented relative to its fence
+		for i := 0; i < indent && strings.HasPrefix(line, " "); i++ {
+			line = line[1:]
+		}
+		lines = append(lines, line)
+	}
+	flush(fence != "")
+
+	return blocks
+}
+
+// codeFence returns the fence a line opens a code block with: three or more
+// backticks or tildes
+func codeFence(line string) string {
+	for _, c := range []string{"`", "~"} {
+		n := len(line) - len(strings.TrimLeft(line, c))
+		if n >= 3 && (c == "~" || !strings.Contains(line[n:], "`")) {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+func firstWord(text string) string {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Kinds of token that code is highlighted with
+type codeTokenKind int
+
+const (
+	codePlain codeTokenKind = iota
+	codeKeyword
+	codeString
+	codeComment
+	codeNumber
+)
+
+type codeToken struct {
+	kind codeTokenKind
+	text string
+}
+
+// codeLanguage describes just enough of a programming language's syntax to
+// highlight it
+type codeLanguage struct {
+	keywords      []string
+	ignoreCase    bool     // Keywords match in any case, as in SQL
+	lineComments  []string // Start a comment that runs to the end of the line
+	blockComment  [2]string
+	quotes        string // Characters that start a string
+	rawQuotes     string // Quotes without escapes that may span lines
+	tripleQuotes  bool   // Python's """strings"""
+	keywordLookup map[string]bool
+}
+
+var codeLanguages = map[string]*codeLanguage{
+	"go": {
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+			"range", "return", "select", "struct", "switch", "type", "var", "true", "false", "nil", "iota"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		rawQuotes:    "`",
+	},
+	"python": {
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def",
+			"del", "elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in",
+			"is", "lambda", "nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with",
+			"yield", "True", "False", "None"},
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+		tripleQuotes: true,
+	},
+	"javascript": {
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue",
+			"default", "delete", "do", "else", "export", "extends", "finally", "for", "function", "if",
+			"import", "in", "instanceof", "interface", "let", "new", "of", "return", "switch", "this",
+			"throw", "try", "type", "typeof", "var", "void", "while", "yield", "true", "false", "null",
+			"undefined"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		rawQuotes:    "`",
+	},
+	"java": {
+		keywords: []string{"abstract", "boolean", "break", "byte", "case", "catch", "char", "class",
+			"continue", "default", "do", "double", "else", "enum", "extends", "final", "finally", "float",
+			"for", "if", "implements", "import", "instanceof", "int", "interface", "long", "new",
+			"package", "private", "protected", "public", "return", "short", "static", "super", "switch",
+			"synchronized", "this", "throw", "throws", "try", "var", "void", "while", "true", "false", "null"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"c": {
+		keywords: []string{"auto", "bool", "break", "case", "char", "class", "const", "continue",
+			"default", "delete", "do", "double", "else", "enum", "extern", "float", "for", "if", "int",
+			"long", "namespace", "new", "nullptr", "private", "public", "return", "short", "signed",
+			"sizeof", "static", "struct", "switch", "template", "this", "typedef", "union", "unsigned",
+			"using", "virtual", "void", "while", "true", "false", "NULL"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	},
+	"rust": {
+		keywords: []string{"as", "async", "await", "break", "const", "continue", "crate", "else", "enum",
+			"fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref",
+			"return", "self", "Self", "static", "struct", "trait", "type", "unsafe", "use", "where",
+			"while", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	},
+	"shell": {
+		keywords: []string{"case", "do", "done", "elif", "else", "esac", "export", "fi", "for",
+			"function", "if", "in", "local", "return", "then", "until", "while"},
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	},
+	"sql": {
+		keywords: []string{"select", "from", "where", "and", "or", "not", "insert", "into", "values",
+			"update", "set", "delete", "create", "table", "index", "drop", "alter", "join", "left",
+			"right", "inner", "outer", "on", "group", "by", "order", "having", "limit", "as", "null",
+			"is", "in", "distinct", "primary", "key", "references", "default", "union", "case", "when",
+			"then", "else", "end"},
+		ignoreCase:   true,
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'",
+	},
+}
+
+// Other names the languages are written with after a fence
+var codeLanguageAliases = map[string]string{
+	"golang":     "go",
+	"py":         "python",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"ts":         "javascript",
+	"tsx":        "javascript",
+	"typescript": "javascript",
+	"cpp":        "c",
+	"c++":        "c",
+	"h":          "c",
+	"rs":         "rust",
+	"sh":         "shell",
+	"bash":       "shell",
+	"zsh":        "shell",
+	"console":    "shell",
+}
+
+func init() {
+	for _, lang := range codeLanguages {
+		lang.keywordLookup = make(map[string]bool)
+		for _, keyword := range lang.keywords {
+			if lang.ignoreCase {
+				keyword = strings.ToLower(keyword)
+			}
+			lang.keywordLookup[keyword] = true
+		}
+	}
+}
+
+func lookupCodeLanguage(name string) *codeLanguage {
+	if alias, ok := codeLanguageAliases[name]; ok {
+		name = alias
+	}
+	return codeLanguages[name]
+}
+
+// highlightCode splits code into tokens to colour. Code in a language that
+// is not known comes back as a single plain token.
+func highlightCode(code, language string) []codeToken {
+	lang := lookupCodeLanguage(language)
+	if lang == nil {
+		return []codeToken{{kind: codePlain, text: code}}
+	}
+
+	var tokens []codeToken
+	add := func(kind codeTokenKind, text string) {
+		if last := len(tokens) - 1; last >= 0 && tokens[last].kind == kind {
+			tokens[last].text += text
+			return
+		}
+		tokens = append(tokens, codeToken{kind: kind, text: text})
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if n := lang.commentLength(rest); n > 0 {
+			add(codeComment, rest[:n])
+			i += n
+			continue
+		}
+		if strings.IndexByte(lang.quotes, rest[0]) >= 0 {
+			n := lang.stringLength(rest)
+			add(codeString, rest[:n])
+			i += n
+			continue
+		}
+
+		word := rest[:identifierLength(rest)]
+		switch {
+		case word == "":
+			add(codePlain, rest[:1])
+			i++
+		case unicode.IsDigit(rune(word[0])):
+			add(codeNumber, word)
+			i += len(word)
+		case lang.isKeyword(word):
+			add(codeKeyword, word)
+			i += len(word)
+		default:
+			add(codePlain, word)
+			i += len(word)
+		}
+	}
+	return tokens
+}
+
+// identifierLength returns the length of the word or number text starts
+// with. Numbers take in their fraction, so that 3.14 is one token.
+func identifierLength(text string) int {
+	n := 0
+	for n < len(text) {
+		c := text[n]
+		isWord := c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+		isFraction := c == '.' && n > 0 && unicode.IsDigit(rune(text[0])) && n+1 < len(text) && unicode.IsDigit(rune(text[n+1]))
+		if !isWord && !isFraction {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func (lang *codeLanguage) isKeyword(word string) bool {
+	if lang.ignoreCase {
+		word = strings.ToLower(word)
+	}
+	return lang.keywordLookup[word]
+}
+
+// commentLength returns the length of the comment text starts with, or 0
+func (lang *codeLanguage) commentLength(text string) int {
+	for _, prefix := range lang.lineComments {
+		if strings.HasPrefix(text, prefix) {
+			if end := strings.IndexByte(text, '\n'); end >= 0 {
+				return end
+			}
+			return len(text)
+		}
+	}
+	if start, end := lang.blockComment[0], lang.blockComment[1]; start != "" && strings.HasPrefix(text, start) {
+		if idx := strings.Index(text[len(start):], end); idx >= 0 {
+			return len(start) + idx + len(end)
+		}
+		return len(text)
+	}
+	return 0
+}
+
+// stringLength returns the length of the string literal text starts with.
+// An unterminated string ends with its line.
+func (lang *codeLanguage) stringLength(text string) int {
+	quote := text[:1]
+	if lang.tripleQuotes && strings.HasPrefix(text, strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+		if idx := strings.Index(text[3:], quote); idx >= 0 {
+			return 3 + idx + 3
+		}
+		return len(text)
+	}
+
+	raw := strings.Contains(lang.rawQuotes, quote)
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && !raw:
+			i++
+		case text[i] == quote[0]:
+			return i + 1
+		case text[i] == '\n' && !raw:
+			return i
+		}
+	}
+	return len(text)
+}
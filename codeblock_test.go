@@ -0,0 +1,207 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		blocks []cardTextBlock
+	}{
+		{
+			name:   "no code",
+			text:   "Just **Markdown**",
+			blocks: []cardTextBlock{{text: "Just **Markdown**"}},
+		},
+		{
+			name: "code between text",
+			text: "What does this print?\n```go\nfmt.Println(1)\n```\nIt prints 1",
+			blocks: []cardTextBlock{
+				{text: "What does this print?"},
+				{text: "fmt.Println(1)", code: true, language: "go"},
+				{text: "It prints 1"},
+			},
+		},
+		{
+			name:   "tildes and a language in capitals",
+			text:   "~~~Python extra words\nprint(1)\n~~~",
+			blocks: []cardTextBlock{{text: "print(1)", code: true, language: "python"}},
+		},
+		{
+			name:   "longer fence holds a shorter one",
+			text:   "````\n```\nnested\n```\n````",
+			blocks: []cardTextBlock{{text: "```\nnested\n```", code: true}},
+		},
+		{
+			name:   "code indented with its fence",
+			text:   "  ```\n  if x {\n      y()\n  }\n  ```",
+			blocks: []cardTextBlock{{text: "if x {\n    y()\n}", code: true}},
+		},
+		{
+			name: "unterminated fence runs to the end",
+			text: "Intro\n```sh\necho hi\n\necho bye",
+			blocks: []cardTextBlock{
+				{text: "Intro"},
+				{text: "echo hi\n\necho bye", code: true, language: "sh"},
+			},
+		},
+		{
+			name:   "empty code block",
+			text:   "```\n```",
+			blocks: []cardTextBlock{{code: true}},
+		},
+		{
+			name:   "inline code is not a fence",
+			text:   "```x``` is inline",
+			blocks: []cardTextBlock{{text: "```x``` is inline"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if blocks := splitCodeBlocks(test.text); !reflect.DeepEqual(blocks, test.blocks) {
+				t.Errorf("splitCodeBlocks(%q) = %+v, want %+v", test.text, blocks, test.blocks)
+			}
+		})
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		tokens   []codeToken
+	}{
+		{
+			name:     "keywords and identifiers",
+			code:     "func main()",
+			language: "go",
+			tokens: []codeToken{
+				{codeKeyword, "func"},
+				{codePlain, " main()"},
+			},
+		},
+		{
+			name:     "strings with escapes",
+			code:     `x := "a \"b\"" + 'c'`,
+			language: "go",
+			tokens: []codeToken{
+				{codePlain, "x := "},
+				{codeString, `"a \"b\""`},
+				{codePlain, " + "},
+				{codeString, "'c'"},
+			},
+		},
+		{
+			name:     "unterminated string ends with its line",
+			code:     "s = \"open\nnext",
+			language: "python",
+			tokens: []codeToken{
+				{codePlain, "s = "},
+				{codeString, "\"open"},
+				{codePlain, "\nnext"},
+			},
+		},
+		{
+			name:     "raw string spans lines",
+			code:     "`a\nb` + c",
+			language: "go",
+			tokens: []codeToken{
+				{codeString, "`a\nb`"},
+				{codePlain, " + c"},
+			},
+		},
+		{
+			name:     "triple quoted string",
+			code:     `"""doc "string" here""" x`,
+			language: "py",
+			tokens: []codeToken{
+				{codeString, `"""doc "string" here"""`},
+				{codePlain, " x"},
+			},
+		},
+		{
+			name:     "line and block comments",
+			code:     "x // note\n/* a\nb */ y",
+			language: "javascript",
+			tokens: []codeToken{
+				{codePlain, "x "},
+				{codeComment, "// note"},
+				{codePlain, "\n"},
+				{codeComment, "/* a\nb */"},
+				{codePlain, " y"},
+			},
+		},
+		{
+			name:     "unterminated block comment runs to the end",
+			code:     "x /* open",
+			language: "c",
+			tokens: []codeToken{
+				{codePlain, "x "},
+				{codeComment, "/* open"},
+			},
+		},
+		{
+			name:     "quotes in a comment",
+			code:     "# it's fine",
+			language: "shell",
+			tokens:   []codeToken{{codeComment, "# it's fine"}},
+		},
+		{
+			name:     "numbers",
+			code:     "3.14 + 42 - x1",
+			language: "rust",
+			tokens: []codeToken{
+				{codeNumber, "3.14"},
+				{codePlain, " + "},
+				{codeNumber, "42"},
+				{codePlain, " - x1"},
+			},
+		},
+		{
+			name:     "keywords in any case",
+			code:     "Select name FROM t -- all",
+			language: "sql",
+			tokens: []codeToken{
+				{codeKeyword, "Select"},
+				{codePlain, " name "},
+				{codeKeyword, "FROM"},
+				{codePlain, " t "},
+				{codeComment, "-- all"},
+			},
+		},
+		{
+			name:     "keywords match case",
+			code:     "Return return",
+			language: "java",
+			tokens: []codeToken{
+				{codePlain, "Return "},
+				{codeKeyword, "return"},
+			},
+		},
+		{
+			name:     "unknown language",
+			code:     "func \"x\" // 1",
+			language: "cobol",
+			tokens:   []codeToken{{codePlain, "func \"x\" // 1"}},
+		},
+		{
+			name:     "no language",
+			code:     "if x { 1 }",
+			language: "",
+			tokens:   []codeToken{{codePlain, "if x { 1 }"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if tokens := highlightCode(test.code, test.language); !reflect.DeepEqual(tokens, test.tokens) {
+				t.Errorf("highlightCode(%q, %q) = %q, want %q", test.code, test.language, tokens, test.tokens)
+			}
+		})
+	}
+}
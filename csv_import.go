@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Card fields a CSV column can be mapped to
+const (
+	CSVFieldQuestion   = "Question"
+	CSVFieldAnswer     = "Answer"
+	CSVFieldSource     = "Source"
+	CSVFieldTags       = "Tags"
+	CSVFieldPromptType = "Prompt Type"
+)
+
+var csvFields = []string{CSVFieldQuestion, CSVFieldAnswer, CSVFieldSource, CSVFieldTags, CSVFieldPromptType}
+
+// Header names recognised for each field when detecting a header row and
+// guessing the column mapping
+var csvHeaderNames = map[string][]string{
+	CSVFieldQuestion:   {"question", "front", "prompt", "q"},
+	CSVFieldAnswer:     {"answer", "back", "a"},
+	CSVFieldSource:     {"source", "source context", "context", "book"},
+	CSVFieldTags:       {"tags", "tag"},
+	CSVFieldPromptType: {"prompt type", "prompttype", "prompt_type", "type"},
+}
+
+// CSVTable holds the parsed records of a CSV or TSV file
+type CSVTable struct {
+	Records   [][]string
+	Lines     []int // Line on which each record starts
+	Delimiter rune
+	HasHeader bool
+	Columns   int
+}
+
+// CSVColumnMapping maps card fields to column indexes. Fields that are not
+// mapped are left out.
+type CSVColumnMapping map[string]int
+
+type CSVImportResult struct {
+	Imported   int
+	Duplicates int
+	Drafts     int // Incomplete rows saved to the inbox
+	Errors     []ParseError
+}
+
+type CSVImporter struct {
+	cardRepo CardRepository
+	inbox    bool
+}
+
+func NewCSVImporter(cardRepo CardRepository) *CSVImporter {
+	return &CSVImporter{cardRepo: cardRepo}
+}
+
+// SetInbox makes rows that lack a question or an answer go to the inbox as
+// drafts instead of being reported as errors
+func (ci *CSVImporter) SetInbox(enabled bool) {
+	ci.inbox = enabled
+}
+
+// ReadCSVFile parses a CSV or TSV file. Files ending in .tsv are read as
+// tab-separated; otherwise the delimiter is detected from the first line.
+func ReadCSVFile(filePath string) (*CSVTable, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+
+	delimiter := '\t'
+	if strings.ToLower(filepath.Ext(filePath)) != ".tsv" {
+		firstLine, err := buffered.Peek(4096)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+		}
+		delimiter = detectCSVDelimiter(string(firstLine))
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	table := &CSVTable{Delimiter: delimiter}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		table.Records = append(table.Records, record)
+		table.Lines = append(table.Lines, line)
+		if len(record) > table.Columns {
+			table.Columns = len(record)
+		}
+	}
+
+	if len(table.Records) == 0 {
+		return nil, fmt.Errorf("file %s contains no rows", filePath)
+	}
+
+	table.HasHeader = detectCSVHeader(table.Records[0])
+	return table, nil
+}
+
+// detectCSVDelimiter picks the most frequent candidate delimiter on the
+// first line, ignoring quoted text
+func detectCSVDelimiter(text string) rune {
+	if idx := strings.IndexAny(text, "\r\n"); idx >= 0 {
+		text = text[:idx]
+	}
+
+	counts := make(map[rune]int)
+	inQuotes := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ',' || r == '\t' || r == ';'):
+			counts[r]++
+		}
+	}
+
+	best := ','
+	for _, candidate := range []rune{'\t', ';'} {
+		if counts[candidate] > counts[best] {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// detectCSVHeader treats the first row as a header when one of its cells is
+// a recognised column name
+func detectCSVHeader(record []string) bool {
+	for _, cell := range record {
+		if csvFieldForHeader(cell) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func csvFieldForHeader(cell string) string {
+	cell = strings.ToLower(strings.TrimSpace(cell))
+	for _, field := range csvFields {
+		for _, name := range csvHeaderNames[field] {
+			if cell == name {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// Header returns the header row, or nil when the table has none
+func (t *CSVTable) Header() []string {
+	if !t.HasHeader {
+		return nil
+	}
+	return t.Records[0]
+}
+
+// DataRows returns the records after the header row, with their line numbers
+func (t *CSVTable) DataRows() ([][]string, []int) {
+	if t.HasHeader {
+		return t.Records[1:], t.Lines[1:]
+	}
+	return t.Records, t.Lines
+}
+
+// ColumnName describes a column for display, using the header when present
+func (t *CSVTable) ColumnName(column int) string {
+	header := t.Header()
+	if column < len(header) && strings.TrimSpace(header[column]) != "" {
+		return fmt.Sprintf("Column %d: %s", column+1, strings.TrimSpace(header[column]))
+	}
+	return fmt.Sprintf("Column %d", column+1)
+}
+
+// GuessMapping maps columns by header name, falling back to question in the
+// first column and answer in the second
+func (t *CSVTable) GuessMapping() CSVColumnMapping {
+	mapping := make(CSVColumnMapping)
+	for column, cell := range t.Header() {
+		field := csvFieldForHeader(cell)
+		if _, taken := mapping[field]; field != "" && !taken {
+			mapping[field] = column
+		}
+	}
+
+	if _, ok := mapping[CSVFieldQuestion]; !ok && t.Columns > 0 {
+		mapping[CSVFieldQuestion] = 0
+	}
+	if _, ok := mapping[CSVFieldAnswer]; !ok && t.Columns > 1 {
+		mapping[CSVFieldAnswer] = 1
+	}
+	return mapping
+}
+
+func (m CSVColumnMapping) value(record []string, field string) string {
+	column, ok := m[field]
+	if !ok || column < 0 || column >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[column])
+}
+
+// Import writes the table's data rows as cards, skipping rows that are
+// invalid or already exist
+func (ci *CSVImporter) Import(table *CSVTable, mapping CSVColumnMapping, sourceFile string) (*CSVImportResult, error) {
+	if _, ok := mapping[CSVFieldQuestion]; !ok {
+		return nil, fmt.Errorf("no column is mapped to the question")
+	}
+	if _, ok := mapping[CSVFieldAnswer]; !ok {
+		return nil, fmt.Errorf("no column is mapped to the answer")
+	}
+
+	result := &CSVImportResult{}
+	rows, lines := table.DataRows()
+
+	for i, record := range rows {
+		lineNum := lines[i]
+		line := strings.Join(record, string(table.Delimiter))
+
+		question := mapping.value(record, CSVFieldQuestion)
+		answer := mapping.value(record, CSVFieldAnswer)
+		source := mapping.value(record, CSVFieldSource)
+		tags := normalizeTags(mapping.value(record, CSVFieldTags))
+
+		if question == "" && answer == "" {
+			continue
+		}
+		if ci.inbox && (question == "" || answer == "") {
+			draft := &DBCard{
+				Question:      question,
+				Answer:        answer,
+				SourceFile:    sourceFile,
+				SourceLine:    lineNum,
+				SourceContext: sql.NullString{String: source, Valid: source != ""},
+				Tags:          tags,
+				Status:        CardStatusDraft,
+			}
+			if err := ci.cardRepo.Create(draft); err != nil {
+				result.Errors = append(result.Errors, ParseError{
+					LineNum: lineNum,
+					Line:    line,
+					Reason:  fmt.Sprintf("Database import failed: %v", err),
+				})
+				continue
+			}
+			result.Drafts++
+			continue
+		}
+		if question == "" {
+			result.Errors = append(result.Errors, ParseError{LineNum: lineNum, Line: line, Reason: "Empty question part"})
+			continue
+		}
+		if answer == "" {
+			result.Errors = append(result.Errors, ParseError{LineNum: lineNum, Line: line, Reason: "Empty answer part"})
+			continue
+		}
+
+		promptType := "factual"
+		if value := mapping.value(record, CSVFieldPromptType); value != "" {
+			normalized, ok := NormalizePromptType(value)
+			if !ok {
+				result.Errors = append(result.Errors, ParseError{
+					LineNum: lineNum,
+					Line:    line,
+					Reason:  fmt.Sprintf("Unknown prompt type %q. Expected one of: %s", value, strings.Join(promptTypes, ", ")),
+				})
+				continue
+			}
+			promptType = normalized
+		}
+
+		exists, err := ci.cardRepo.CardExists(question, answer)
+		if err != nil {
+			return result, fmt.Errorf("failed to check card existence: %w", err)
+		}
+		if exists {
+			result.Duplicates++
+			continue
+		}
+
+		card := &DBCard{
+			Question:      question,
+			Answer:        answer,
+			SourceFile:    sourceFile,
+			SourceLine:    lineNum,
+			SourceContext: sql.NullString{String: source, Valid: source != ""},
+			PromptType:    promptType,
+			Tags:          tags,
+		}
+		if err := ci.cardRepo.Create(card); err != nil {
+			result.Errors = append(result.Errors, ParseError{
+				LineNum: lineNum,
+				Line:    line,
+				Reason:  fmt.Sprintf("Database import failed: %v", err),
+			})
+			continue
+		}
+		result.Imported++
+	}
+
+	return result, nil
+}
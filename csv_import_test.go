@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeCSVFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadCSVFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		delimiter rune
+		header    bool
+		columns   int
+		rows      [][]string
+		lines     []int
+	}{
+		{
+			name:      "commas with a header",
+			file:      "cards.csv",
+			content:   "Front,Back,Tags\nHund,dog,de\nKatze,cat\n",
+			delimiter: ',',
+			header:    true,
+			columns:   3,
+			rows:      [][]string{{"Hund", "dog", "de"}, {"Katze", "cat"}},
+			lines:     []int{2, 3},
+		},
+		{
+			name:      "quoted delimiters and line breaks",
+			file:      "cards.csv",
+			content:   "\"a, b; c\",\"one\ntwo\"\nnext,row\n",
+			delimiter: ',',
+			columns:   2,
+			rows:      [][]string{{"a, b; c", "one\ntwo"}, {"next", "row"}},
+			lines:     []int{1, 3},
+		},
+		{
+			name:      "semicolons",
+			file:      "cards.txt",
+			content:   "question;answer\n1,5;one and a half\n",
+			delimiter: ';',
+			header:    true,
+			columns:   2,
+			rows:      [][]string{{"1,5", "one and a half"}},
+			lines:     []int{2},
+		},
+		{
+			name:      "tabs detected",
+			file:      "cards.csv",
+			content:   "Hund\tdog, hound\tde\n",
+			delimiter: '\t',
+			columns:   3,
+			rows:      [][]string{{"Hund", "dog, hound", "de"}},
+			lines:     []int{1},
+		},
+		{
+			name:      "tsv files always use tabs",
+			file:      "cards.tsv",
+			content:   "a,b,c\td\n",
+			delimiter: '\t',
+			columns:   2,
+			rows:      [][]string{{"a,b,c", "d"}},
+			lines:     []int{1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table, err := ReadCSVFile(writeCSVFile(t, test.file, test.content))
+			if err != nil {
+				t.Fatalf("ReadCSVFile: %v", err)
+			}
+			if table.Delimiter != test.delimiter || table.HasHeader != test.header || table.Columns != test.columns {
+				t.Errorf("delimiter %q, header %t, %d columns; want %q, %t, %d",
+					table.Delimiter, table.HasHeader, table.Columns, test.delimiter, test.header, test.columns)
+			}
+			rows, lines := table.DataRows()
+			if !reflect.DeepEqual(rows, test.rows) || !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("rows = %q on lines %v, want %q on lines %v", rows, lines, test.rows, test.lines)
+			}
+		})
+	}
+}
+
+func TestReadCSVFileEmpty(t *testing.T) {
+	if _, err := ReadCSVFile(writeCSVFile(t, "empty.csv", "")); err == nil {
+		t.Error("ReadCSVFile of an empty file succeeded, want an error")
+	}
+}
+
+func TestGuessMapping(t *testing.T) {
+	tests := []struct {
+		name  string
+		table CSVTable
+		want  CSVColumnMapping
+	}{
+		{
+			name:  "no header",
+			table: CSVTable{Records: [][]string{{"Hund", "dog", "de"}}, Columns: 3},
+			want:  CSVColumnMapping{CSVFieldQuestion: 0, CSVFieldAnswer: 1},
+		},
+		{
+			name: "header names in any order and case",
+			table: CSVTable{
+				Records:   [][]string{{" Tags ", "Back", "Book", "Front", "Type"}},
+				Columns:   5,
+				HasHeader: true,
+			},
+			want: CSVColumnMapping{CSVFieldTags: 0, CSVFieldAnswer: 1, CSVFieldSource: 2, CSVFieldQuestion: 3, CSVFieldPromptType: 4},
+		},
+		{
+			name:  "first of two matching columns",
+			table: CSVTable{Records: [][]string{{"Notes", "Answer", "Back"}}, Columns: 3, HasHeader: true},
+			want:  CSVColumnMapping{CSVFieldQuestion: 0, CSVFieldAnswer: 1},
+		},
+		{
+			name:  "one column",
+			table: CSVTable{Records: [][]string{{"Hund"}}, Columns: 1},
+			want:  CSVColumnMapping{CSVFieldQuestion: 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.table.GuessMapping(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GuessMapping() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	table := &CSVTable{Records: [][]string{{"Front", " "}}, Columns: 3, HasHeader: true}
+	for column, want := range []string{"Column 1: Front", "Column 2", "Column 3"} {
+		if got := table.ColumnName(column); got != want {
+			t.Errorf("ColumnName(%d) = %q, want %q", column, got, want)
+		}
+	}
+}
+
+const csvImportContent = `Tags,Front,Back,Book,Type
+de #nouns,Hund,dog,Wortschatz,
+,Katze,cat,,Factual Recall
+,Hund,dog,,
+,,,,
+,Haus,,,
+,,mouse,,
+,Baum,tree,,guess
+,Vogel,bird,,conceptual
+`
+
+func TestCSVImport(t *testing.T) {
+	db := newTestDatabase(t)
+	path := writeCSVFile(t, "cards.csv", csvImportContent)
+	table, err := ReadCSVFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewCSVImporter(NewSQLiteCardRepository(db)).Import(table, table.GuessMapping(), path)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Imported != 3 || result.Duplicates != 1 || result.Drafts != 0 {
+		t.Errorf("result = %+v, want 3 imported and 1 duplicate", result)
+	}
+	var errors []string
+	for _, parseErr := range result.Errors {
+		errors = append(errors, fmt.Sprintf("line %d: %s", parseErr.LineNum, parseErr.Reason))
+	}
+	if want := []string{
+		"line 6: Empty answer part",
+		"line 7: Empty question part",
+		`line 8: Unknown prompt type "guess". Expected one of: factual, conceptual, application, comparison`,
+	}; !reflect.DeepEqual(errors, want) {
+		t.Errorf("errors = %q, want %q", errors, want)
+	}
+
+	type imported struct {
+		Q, A, Source, PromptType, Tags string
+		Line                           int
+	}
+	cards, err := NewSQLiteCardRepository(db).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []imported
+	for _, card := range cards {
+		got = append(got, imported{card.Question, card.Answer, card.SourceContext.String, card.PromptType, card.Tags, card.SourceLine})
+	}
+	want := []imported{
+		{"Hund", "dog", "Wortschatz", "factual", "de, nouns", 2},
+		{"Katze", "cat", "", "factual", "", 3},
+		{"Vogel", "bird", "", "conceptual", "", 9},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cards = %+v, want %+v", got, want)
+	}
+}
+
+func TestCSVImportToInbox(t *testing.T) {
+	db := newTestDatabase(t)
+	path := writeCSVFile(t, "cards.csv", csvImportContent)
+	table, err := ReadCSVFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importer := NewCSVImporter(NewSQLiteCardRepository(db))
+	importer.SetInbox(true)
+	result, err := importer.Import(table, table.GuessMapping(), path)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Imported != 3 || result.Drafts != 2 || len(result.Errors) != 1 {
+		t.Errorf("result = %+v, want 3 imported, 2 drafts and the unknown prompt type reported", result)
+	}
+
+	drafts, err := NewSQLiteCardRepository(db).GetByStatus(CardStatusDraft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]string
+	for _, draft := range drafts {
+		got = append(got, [2]string{draft.Question, draft.Answer})
+	}
+	if want := [][2]string{{"Haus", ""}, {"", "mouse"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("drafts = %q, want %q", got, want)
+	}
+}
+
+func TestCSVImportNeedsQuestionAndAnswer(t *testing.T) {
+	table := &CSVTable{Records: [][]string{{"Hund", "dog"}}, Lines: []int{1}, Columns: 2, Delimiter: ','}
+	importer := NewCSVImporter(NewSQLiteCardRepository(newTestDatabase(t)))
+
+	for _, mapping := range []CSVColumnMapping{{CSVFieldQuestion: 0}, {CSVFieldAnswer: 1}} {
+		if _, err := importer.Import(table, mapping, "cards.csv"); err == nil {
+			t.Errorf("Import with mapping %v succeeded, want an error", mapping)
+		}
+	}
+}
+
+func TestNormalizePromptType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{"factual", "factual", true},
+		{" Conceptual ", "conceptual", true},
+		{"Factual Recall", "factual", true},
+		{"COMPARISON", "comparison", true},
+		{"application", "application", true},
+		{"guess", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := NormalizePromptType(test.value)
+		if got != test.want || ok != test.ok {
+			t.Errorf("NormalizePromptType(%q) = %q, %t; want %q, %t", test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		tags string
+		want string
+	}{
+		{"", ""},
+		{"go", "go"},
+		{" go  lang ", "go, lang"},
+		{"#go, #lang", "go, lang"},
+		{"go;lang,,cards", "go, lang, cards"},
+		{"## #", ""},
+	}
+
+	for _, test := range tests {
+		if got := normalizeTags(test.tags); got != test.want {
+			t.Errorf("normalizeTags(%q) = %q, want %q", test.tags, got, test.want)
+		}
+	}
+}
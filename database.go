@@ -9,20 +9,26 @@ import (
 )
 
 type Database struct {
-	db *sql.DB
+	db     *sql.DB
+	tx     *sql.Tx // Set on a Database returned by Begin
+	txConn *txConn // Runs queries in tx
+}
+
+// dbConn runs queries on the database or inside a transaction
+type dbConn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	// Enable foreign keys on every connection of the pool, not only the first,
+	// so deletes cascade while a transaction holds another connection
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
 	database := &Database{db: db}
 	if err := database.createTables(); err != nil {
 		return nil, fmt.Errorf("failed to create tables: %w", err)
@@ -32,6 +38,9 @@ func NewDatabase(dbPath string) (*Database, error) {
 	if err := database.migrateSchema(); err != nil {
 		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
+	if err := database.migrateTags(); err != nil {
+		return nil, fmt.Errorf("failed to migrate tags: %w", err)
+	}
 
 	return database, nil
 }
@@ -40,12 +49,95 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
+// conn returns what repositories run their queries on
+func (d *Database) conn() dbConn {
+	if d.tx != nil {
+		return d.txConn
+	}
+	return d.db
+}
+
+// Begin starts a transaction. Repositories created on the returned Database
+// run their queries in it until Commit or Rollback is called.
+func (d *Database) Begin() (*Database, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Database{db: d.db, tx: tx, txConn: &txConn{tx: tx, stmts: make(map[string]*sql.Stmt)}}, nil
+}
+
+// txConn runs queries in a transaction. Each query is prepared once, so an
+// import storing thousands of cards does not parse the same SQL for each one.
+// The statements are closed with the transaction.
+type txConn struct {
+	tx    *sql.Tx
+	stmts map[string]*sql.Stmt
+}
+
+func (c *txConn) stmt(query string) (*sql.Stmt, error) {
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := c.tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (c *txConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+func (c *txConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+func (c *txConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	stmt, err := c.stmt(query)
+	if err != nil {
+		// Runs into the same error, which Scan then returns
+		return c.tx.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
+
+func (d *Database) Commit() error {
+	if err := d.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) Rollback() error {
+	if err := d.tx.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back transaction: %w", err)
+	}
+	return nil
+}
+
 func (d *Database) migrateSchema() error {
 	// Check if new columns exist, add them if they don't
 	migrations := []string{
 		`ALTER TABLE cards ADD COLUMN source_context TEXT`,
 		`ALTER TABLE cards ADD COLUMN prompt_type TEXT DEFAULT 'factual'`,
 		`ALTER TABLE cards ADD COLUMN tags TEXT`,
+		`ALTER TABLE cards ADD COLUMN note_id INTEGER REFERENCES notes(id) ON DELETE CASCADE`,
+		`ALTER TABLE cards ADD COLUMN ordinal INTEGER DEFAULT 0`,
+		`ALTER TABLE cards ADD COLUMN deck_id INTEGER REFERENCES decks(id) ON DELETE SET NULL`,
+		`ALTER TABLE cards ADD COLUMN status TEXT DEFAULT 'active'`,
+		`ALTER TABLE decks ADD COLUMN text_format TEXT DEFAULT 'markdown'`,
+		`ALTER TABLE decks ADD COLUMN parent_id INTEGER REFERENCES decks(id) ON DELETE SET NULL`,
 	}
 
 	for _, migration := range migrations {
@@ -53,11 +145,95 @@ func (d *Database) migrateSchema() error {
 		d.db.Exec(migration)
 	}
 
+	// Indexes on migrated columns can only be created once the columns exist
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_cards_note_id ON cards(note_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_cards_deck_id ON cards(deck_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_cards_source_file ON cards(source_file)`,
+		`CREATE INDEX IF NOT EXISTS idx_decks_parent_id ON decks(parent_id)`,
+	}
+
+	for _, index := range indexes {
+		if _, err := d.db.Exec(index); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// migrateTags links cards stored before tags had tables of their own to
+// their tags, cleaning up the tag lists as it goes. It does nothing once any
+// card has linked tags.
+func (d *Database) migrateTags() error {
+	var linked int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM card_tags`).Scan(&linked); err != nil {
+		return fmt.Errorf("failed to count card tags: %w", err)
+	}
+	if linked > 0 {
+		return nil
+	}
+
+	rows, err := d.db.Query(`SELECT id, tags FROM cards WHERE tags IS NOT NULL AND tags != ''`)
+	if err != nil {
+		return fmt.Errorf("failed to query card tags: %w", err)
+	}
+	tagLists := make(map[int64]string)
+	for rows.Next() {
+		var cardID int64
+		var tags string
+		if err := rows.Scan(&cardID, &tags); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan card tags: %w", err)
+		}
+		tagLists[cardID] = tags
+	}
+	rows.Close()
+	if len(tagLists) == 0 {
+		return nil
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return err
+	}
+	for cardID, tags := range tagLists {
+		// "#golang" and "golang" are the same tag
+		normalized := cleanTags(tags)
+		if normalized != tags {
+			if _, err := tx.conn().Exec(`UPDATE cards SET tags = ? WHERE id = ?`, normalized, cardID); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to update card tags: %w", err)
+			}
+		}
+		if err := saveCardTags(tx.conn(), cardID, normalized); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (d *Database) createTables() error {
 	schemas := []string{
+		`CREATE TABLE IF NOT EXISTS decks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			description TEXT,
+			settings TEXT,
+			text_format TEXT DEFAULT 'markdown',
+			parent_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (parent_id) REFERENCES decks(id) ON DELETE SET NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS notes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			note_type TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
 		`CREATE TABLE IF NOT EXISTS cards (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			question TEXT NOT NULL,
@@ -67,8 +243,28 @@ func (d *Database) createTables() error {
 			source_context TEXT,
 			prompt_type TEXT DEFAULT 'factual',
 			tags TEXT,
+			note_id INTEGER,
+			ordinal INTEGER DEFAULT 0,
+			deck_id INTEGER,
+			status TEXT DEFAULT 'active',
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+			FOREIGN KEY (deck_id) REFERENCES decks(id) ON DELETE SET NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			parent_id INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (parent_id) REFERENCES tags(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS card_tags (
+			card_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			PRIMARY KEY (card_id, tag_id),
+			FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE,
+			FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
 		)`,
 		`CREATE TABLE IF NOT EXISTS review_states (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -81,6 +277,31 @@ func (d *Database) createTables() error {
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS review_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id INTEGER NOT NULL,
+			reviewed_at DATETIME NOT NULL,
+			rating INTEGER NOT NULL,
+			elapsed_ms INTEGER DEFAULT 0,
+			state_before INTEGER,
+			state_after INTEGER,
+			stability_before REAL,
+			stability_after REAL,
+			difficulty_before REAL,
+			difficulty_after REAL,
+			scheduled_days INTEGER,
+			due_date DATETIME,
+			FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS media (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			card_id INTEGER NOT NULL,
+			reference TEXT NOT NULL,
+			file_name TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (card_id, reference),
+			FOREIGN KEY (card_id) REFERENCES cards(id) ON DELETE CASCADE
+		)`,
 		`CREATE TABLE IF NOT EXISTS sessions (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			start_time DATETIME NOT NULL,
@@ -109,8 +330,13 @@ func (d *Database) createTables() error {
 	// Create indexes for better performance
 	indexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_cards_question ON cards(question)`,
+		`CREATE INDEX IF NOT EXISTS idx_notes_content ON notes(content)`,
 		`CREATE INDEX IF NOT EXISTS idx_review_states_card_id ON review_states(card_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_review_states_due_date ON review_states(due_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_tags_parent_id ON tags(parent_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_card_tags_tag_id ON card_tags(tag_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_log_card_id ON review_log(card_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_log_reviewed_at ON review_log(reviewed_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_daily_stats_date ON daily_stats(date)`,
 	}
 
@@ -133,10 +359,46 @@ type DBCard struct {
 	SourceContext sql.NullString `db:"source_context"`
 	PromptType    string         `db:"prompt_type"`
 	Tags          string         `db:"tags"`
+	NoteID        sql.NullInt64  `db:"note_id"` // Set for cards generated from a note
+	Ordinal       int            `db:"ordinal"` // Which card of the note this is (cloze number)
+	DeckID        sql.NullInt64  `db:"deck_id"` // Deck declared by the card file's front matter
+	Status        string         `db:"status"`  // active, archived or draft
 	CreatedAt     time.Time      `db:"created_at"`
 	UpdatedAt     time.Time      `db:"updated_at"`
 }
 
+// Database note structure. A note holds the source text that one or more
+// sibling cards are generated from.
+type DBNote struct {
+	ID        int64     `db:"id"`
+	NoteType  string    `db:"note_type"`
+	Content   string    `db:"content"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// Database deck structure. Settings holds the deck's DeckSettings as JSON.
+// Nested decks are named after their parents, "Languages::Spanish".
+type DBDeck struct {
+	ID          int64          `db:"id"`
+	Name        string         `db:"name"`
+	Description sql.NullString `db:"description"`
+	Settings    string         `db:"settings"`
+	TextFormat  string         `db:"text_format"` // How card text is shown: markdown or plain
+	ParentID    sql.NullInt64  `db:"parent_id"`   // Deck this deck is nested in
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+// Database tag structure. Nested tags are named after their parents,
+// "lang::go::generics"; names are matched without regard to case.
+type DBTag struct {
+	ID        int64         `db:"id"`
+	Name      string        `db:"name"`
+	ParentID  sql.NullInt64 `db:"parent_id"` // Tag this tag is nested in
+	CreatedAt time.Time     `db:"created_at"`
+}
+
 // Database review state structure
 type DBReviewState struct {
 	ID           int64     `db:"id"`
@@ -149,6 +411,34 @@ type DBReviewState struct {
 	UpdatedAt    time.Time `db:"updated_at"`
 }
 
+// Database review log structure. One is written for every rating given, with
+// the card's FSRS state before and after the review.
+type DBReviewLog struct {
+	ID               int64     `db:"id"`
+	CardID           int64     `db:"card_id"`
+	ReviewedAt       time.Time `db:"reviewed_at"`
+	Rating           int       `db:"rating"`       // fsrs.Rating, 1 (Again) to 4 (Easy)
+	ElapsedMs        int64     `db:"elapsed_ms"`   // Time taken to answer
+	StateBefore      int       `db:"state_before"` // fsrs.State: new, learning, review or relearning
+	StateAfter       int       `db:"state_after"`
+	StabilityBefore  float64   `db:"stability_before"`
+	StabilityAfter   float64   `db:"stability_after"`
+	DifficultyBefore float64   `db:"difficulty_before"`
+	DifficultyAfter  float64   `db:"difficulty_after"`
+	ScheduledDays    int       `db:"scheduled_days"`
+	DueDate          time.Time `db:"due_date"`
+}
+
+// Database media structure. It links an image a card's text refers to with
+// the copy kept in the media folder.
+type DBMedia struct {
+	ID        int64     `db:"id"`
+	CardID    int64     `db:"card_id"`
+	Reference string    `db:"reference"` // As written in the card text
+	FileName  string    `db:"file_name"` // Name of the copy in the media folder
+	CreatedAt time.Time `db:"created_at"`
+}
+
 // Database session structure
 type DBSession struct {
 	ID            int64     `db:"id"`
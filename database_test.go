@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDatabase opens an empty database that is removed after the test
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "cards.db"))
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestParser returns a parser that stores the cards it loads in db
+func newTestParser(db *Database) *CardParser {
+	return NewCardParserWithDatabase(NewSQLiteCardRepository(db), NewSQLiteNoteRepository(db), NewSQLiteDeckRepository(db))
+}
+
+// countRows returns the number of rows in a table
+func countRows(t *testing.T, db *Database, table string) int {
+	t.Helper()
+	var n int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("failed to count %s: %v", table, err)
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// How the text of a deck's cards is shown. Markdown is the default; plain
+// text suits decks where asterisks, underscores or "#" are meant literally.
+const (
+	TextFormatMarkdown = "markdown"
+	TextFormatPlain    = "plain"
+)
+
+// DeckSettings are the scheduling options a deck can override. Zero values
+// keep the FSRS defaults.
+type DeckSettings struct {
+	DesiredRetention float64 `json:"desired_retention,omitempty" yaml:"desired_retention,omitempty" toml:"desired_retention"`
+	MaximumInterval  int     `json:"maximum_interval,omitempty" yaml:"maximum_interval,omitempty" toml:"maximum_interval"` // Days
+}
+
+func (s DeckSettings) Validate() error {
+	if s.DesiredRetention < 0 || s.DesiredRetention >= 1 {
+		return fmt.Errorf("desired_retention must be between 0 and 1, got %g", s.DesiredRetention)
+	}
+	if s.MaximumInterval < 0 {
+		return fmt.Errorf("maximum_interval must be a positive number of days, got %d", s.MaximumInterval)
+	}
+	return nil
+}
+
+// Parameters returns the FSRS parameters for cards in the deck
+func (s DeckSettings) Parameters() fsrs.Parameters {
+	params := fsrs.DefaultParam()
+	if s.DesiredRetention > 0 {
+		params.RequestRetention = s.DesiredRetention
+	}
+	if s.MaximumInterval > 0 {
+		params.MaximumInterval = float64(s.MaximumInterval)
+	}
+	return params
+}
+
+func (s DeckSettings) IsDefault() bool {
+	return s == DeckSettings{}
+}
+
+// ParseDeckSettings reads the settings stored on a deck row
+func ParseDeckSettings(data string) (DeckSettings, error) {
+	var settings DeckSettings
+	if data == "" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		return settings, fmt.Errorf("failed to parse deck settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s DeckSettings) JSON() (string, error) {
+	if s.IsDefault() {
+		return "", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal deck settings: %w", err)
+	}
+	return string(data), nil
+}
+
+// saveDeck creates the named deck, or updates its description, text format
+// and settings when it already exists, and returns it
+func saveDeck(deckRepo DeckRepository, name, description, textFormat string, settings DeckSettings) (*DBDeck, error) {
+	settingsJSON, err := settings.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	parentID, err := parentDeckID(deckRepo, name)
+	if err != nil {
+		return nil, err
+	}
+
+	deck, err := deckRepo.GetByName(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		deck = &DBDeck{
+			Name:        name,
+			Description: sql.NullString{String: description, Valid: description != ""},
+			Settings:    settingsJSON,
+			TextFormat:  textFormat,
+			ParentID:    parentID,
+		}
+		if err := deckRepo.Create(deck); err != nil {
+			return nil, err
+		}
+		return deck, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deck.Description = sql.NullString{String: description, Valid: description != ""}
+	deck.Settings = settingsJSON
+	deck.TextFormat = textFormat
+	deck.ParentID = parentID
+	if err := deckRepo.Update(deck); err != nil {
+		return nil, err
+	}
+	return deck, nil
+}
+
+// ensureDeck returns the named deck, creating it with default settings when
+// it does not exist yet
+func ensureDeck(deckRepo DeckRepository, name string) (*DBDeck, error) {
+	deck, err := deckRepo.GetByName(name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if deck != nil && deck.ParentID.Valid {
+		return deck, nil
+	}
+
+	parentID, err := parentDeckID(deckRepo, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if deck == nil {
+		deck = &DBDeck{Name: name, ParentID: parentID}
+		if err := deckRepo.Create(deck); err != nil {
+			return nil, err
+		}
+		return deck, nil
+	}
+
+	// Decks saved before decks were nested get their parent now
+	if parentID.Valid {
+		deck.ParentID = parentID
+		if err := deckRepo.Update(deck); err != nil {
+			return nil, err
+		}
+	}
+	return deck, nil
+}
+
+// parentDeckID returns the ID of the deck a deck is nested in, going by its
+// name, and creates the parent when it does not exist yet. "Languages" is
+// the parent of "Languages::Spanish".
+func parentDeckID(deckRepo DeckRepository, name string) (sql.NullInt64, error) {
+	idx := strings.LastIndex(name, deckPathSeparator)
+	if idx <= 0 {
+		return sql.NullInt64{}, nil
+	}
+
+	parent, err := ensureDeck(deckRepo, name[:idx])
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: parent.ID, Valid: true}, nil
+}
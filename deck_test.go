@@ -0,0 +1,192 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// deckParents returns the parent of every deck by name, "" for top-level decks
+func deckParents(t *testing.T, deckRepo DeckRepository) map[string]string {
+	t.Helper()
+	decks, err := deckRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[int64]string)
+	for _, deck := range decks {
+		names[deck.ID] = deck.Name
+	}
+	parents := make(map[string]string)
+	for _, deck := range decks {
+		parents[deck.Name] = names[deck.ParentID.Int64]
+	}
+	return parents
+}
+
+func TestEnsureDeckNests(t *testing.T) {
+	deckRepo := NewSQLiteDeckRepository(newTestDatabase(t))
+
+	deck, err := ensureDeck(deckRepo, "Languages::Romance::Spanish")
+	if err != nil {
+		t.Fatalf("ensureDeck: %v", err)
+	}
+	if again, err := ensureDeck(deckRepo, "Languages::Romance::Spanish"); err != nil || again.ID != deck.ID {
+		t.Errorf("ensureDeck again = %+v, %v; want deck %d", again, err, deck.ID)
+	}
+	if _, err := saveDeck(deckRepo, "Languages::Germanic", "", TextFormatMarkdown, DeckSettings{}); err != nil {
+		t.Fatalf("saveDeck: %v", err)
+	}
+
+	// A deck saved before decks were nested gets its parent when used
+	old := &DBDeck{Name: "Geography::Capitals"}
+	if err := deckRepo.Create(old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ensureDeck(deckRepo, old.Name); err != nil {
+		t.Fatalf("ensureDeck of an old deck: %v", err)
+	}
+
+	want := map[string]string{
+		"Languages":                   "",
+		"Languages::Romance":          "Languages",
+		"Languages::Romance::Spanish": "Languages::Romance",
+		"Languages::Germanic":         "Languages",
+		"Geography":                   "",
+		"Geography::Capitals":         "Geography",
+	}
+	if got := deckParents(t, deckRepo); !reflect.DeepEqual(got, want) {
+		t.Errorf("deck parents = %q, want %q", got, want)
+	}
+}
+
+func TestParentDeckID(t *testing.T) {
+	deckRepo := NewSQLiteDeckRepository(newTestDatabase(t))
+	for _, name := range []string{"Languages", "::Languages", ""} {
+		if id, err := parentDeckID(deckRepo, name); err != nil || id.Valid {
+			t.Errorf("parentDeckID(%q) = %v, %v; want no parent", name, id, err)
+		}
+	}
+	id, err := parentDeckID(deckRepo, "Languages::Go")
+	if err != nil || !id.Valid {
+		t.Fatalf("parentDeckID(Languages::Go) = %v, %v; want a parent", id, err)
+	}
+	if parent, err := deckRepo.GetByID(id.Int64); err != nil || parent.Name != "Languages" {
+		t.Errorf("parent = %+v, %v; want Languages", parent, err)
+	}
+}
+
+// nestedDeckFiles puts cards into a deck, a deck nested in it and another deck
+var nestedDeckFiles = map[string]string{
+	"languages.txt": "---\ndeck: Languages\n---\nWhat is a language? >> A way to talk\n",
+	"go.txt": "---\ndeck: Languages::Go\nscheduling:\n  maximum_interval: 1\n---\n" +
+		"What is Go? >> A language\nWho made Go? >> Google\n",
+	"generics.txt":  "---\ndeck: Languages::Go::Generics\n---\nWhen did Go get generics? >> 1.18\n",
+	"geography.txt": "---\ndeck: Geography\n---\nCapital of France? >> Paris\n",
+}
+
+func TestGetDeckCards(t *testing.T) {
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadDirectory(writeCardTree(t, nestedDeckFiles)); err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	decks, err := cp.GetDecks()
+	if err != nil {
+		t.Fatalf("GetDecks: %v", err)
+	}
+	ids := make(map[string]int64)
+	for _, deck := range decks {
+		ids[deck.Name] = deck.ID
+	}
+
+	tests := []struct {
+		deck string
+		want []string
+	}{
+		{"Languages", []string{"What is Go?", "What is a language?", "When did Go get generics?", "Who made Go?"}},
+		{"Languages::Go", []string{"What is Go?", "When did Go get generics?", "Who made Go?"}},
+		{"Languages::Go::Generics", []string{"When did Go get generics?"}},
+		{"Geography", []string{"Capital of France?"}},
+	}
+	for _, test := range tests {
+		var questions []string
+		for _, card := range cp.GetDeckCards(ids[test.deck]) {
+			questions = append(questions, card.Question)
+		}
+		sort.Strings(questions)
+		if !reflect.DeepEqual(questions, test.want) {
+			t.Errorf("cards of %s = %q, want %q", test.deck, questions, test.want)
+		}
+	}
+	if cards := cp.GetDeckCards(ids["Languages"] + 100); len(cards) != 0 {
+		t.Errorf("cards of a missing deck = %+v, want none", cards)
+	}
+}
+
+func TestStudyNestedDeckUsesItsSettings(t *testing.T) {
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadDirectory(writeCardTree(t, nestedDeckFiles)); err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+	deck, err := NewSQLiteDeckRepository(db).GetByName("Languages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fm := NewFSRSManagerWithDatabase(NewSQLiteReviewStateRepository(db), NewSQLiteDeckRepository(db))
+	due := fm.GetDueCards(cp.GetDeckCards(deck.ID))
+	if len(due) != 4 {
+		t.Fatalf("%d cards due in Languages, want 4", len(due))
+	}
+
+	// Languages::Go keeps its cards within a day, the other decks do not
+	for _, card := range due {
+		if err := fm.ReviewCard(card, fsrs.Easy, time.Second); err != nil {
+			t.Fatalf("ReviewCard: %v", err)
+		}
+		days := fm.GetCardState(card).FSRSCard.ScheduledDays
+		if limited := card.Question == "What is Go?" || card.Question == "Who made Go?"; limited != (days <= 1) {
+			t.Errorf("%q scheduled in %d days, want a limit of 1 day %t", card.Question, days, limited)
+		}
+	}
+}
+
+func TestDeckSettings(t *testing.T) {
+	tests := []struct {
+		settings  DeckSettings
+		valid     bool
+		retention float64
+		interval  float64
+	}{
+		{DeckSettings{}, true, fsrs.DefaultParam().RequestRetention, fsrs.DefaultParam().MaximumInterval},
+		{DeckSettings{DesiredRetention: 0.85, MaximumInterval: 30}, true, 0.85, 30},
+		{DeckSettings{DesiredRetention: 1}, false, 1, fsrs.DefaultParam().MaximumInterval},
+		{DeckSettings{MaximumInterval: -1}, false, fsrs.DefaultParam().RequestRetention, fsrs.DefaultParam().MaximumInterval},
+	}
+
+	for _, test := range tests {
+		if err := test.settings.Validate(); (err == nil) != test.valid {
+			t.Errorf("%+v.Validate() = %v, want valid %t", test.settings, err, test.valid)
+		}
+		params := test.settings.Parameters()
+		if params.RequestRetention != test.retention || params.MaximumInterval != test.interval {
+			t.Errorf("%+v.Parameters() = retention %g, interval %g; want %g, %g",
+				test.settings, params.RequestRetention, params.MaximumInterval, test.retention, test.interval)
+		}
+
+		// Settings survive being stored on a deck
+		data, err := test.settings.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed, err := ParseDeckSettings(data); err != nil || parsed != test.settings {
+			t.Errorf("ParseDeckSettings(%q) = %+v, %v; want %+v", data, parsed, err, test.settings)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Separator between the levels of a deck name built from a path, as in
+// go/concurrency.txt -> go::concurrency
+const deckPathSeparator = "::"
+
+// deckNameForPath turns a card file's path relative to the loaded directory
+// into a deck name
+func deckNameForPath(relPath string) string {
+	relPath = strings.TrimSuffix(filepath.ToSlash(relPath), filepath.Ext(relPath))
+	return strings.Join(strings.Split(relPath, "/"), deckPathSeparator)
+}
+
+// findCardFiles returns the card files below dir, relative to dir and in
+// path order. Hidden files and directories are skipped.
+func findCardFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && isIgnoredName(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isCardFile(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// isIgnoredName reports whether a file or directory is left out when looking
+// for card files: hidden ones, and the files editors keep next to the file
+// being edited, like .#cards.txt and ~cards.txt
+func isIgnoredName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "#") || strings.HasPrefix(name, "~")
+}
+
+// LoadDirectory parses every card file in a directory tree. Each file's cards
+// go into the deck named after its path unless its front matter names one.
+// The parse result combines the results of all files.
+func (cp *CardParser) LoadDirectory(dir string) error {
+	files, err := findCardFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no card files found in %s", dir)
+	}
+
+	cp.currentDir = dir
+	combined := &ParseResult{
+		Cards:  make([]Card, 0),
+		Errors: make([]ParseError, 0),
+	}
+
+	for i, rel := range files {
+		path := filepath.Join(dir, rel)
+		combined.Files = append(combined.Files, path)
+
+		cp.startImportFile(i+1, len(files))
+		if err := cp.LoadFromFile(path); err != nil {
+			if errors.Is(err, ErrImportCancelled) {
+				return err
+			}
+			combined.Errors = append(combined.Errors, ParseError{
+				FilePath: path,
+				Reason:   err.Error(),
+			})
+			continue
+		}
+		combined.add(cp.parseResult, path)
+	}
+
+	// Cards added from now on do not belong to any one file
+	cp.currentFile = ""
+	cp.parseResult = combined
+	return nil
+}
+
+// add merges the result of parsing one file into a combined result
+func (r *ParseResult) add(file *ParseResult, path string) {
+	r.Cards = append(r.Cards, file.Cards...)
+	for _, parseErr := range file.Errors {
+		parseErr.FilePath = path
+		r.Errors = append(r.Errors, parseErr)
+	}
+	r.TotalLines += file.TotalLines
+	r.ValidCards += file.ValidCards
+	r.SkippedLines += file.SkippedLines
+	r.Updated += file.Updated
+	r.Removed = append(r.Removed, file.Removed...)
+
+	// List each encoding once, "UTF-8, Windows-1252"
+	if !slices.Contains(strings.Split(r.Encoding, ", "), file.Encoding) {
+		if r.Encoding != "" {
+			r.Encoding += ", "
+		}
+		r.Encoding += file.Encoding
+	}
+}
+
+// pathDeck returns the deck name for a file inside the loaded directory, or
+// "" when no directory is loaded or the file is outside it
+func (cp *CardParser) pathDeck(filePath string) string {
+	if cp.currentDir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(cp.currentDir, filePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return deckNameForPath(rel)
+}
+
+// usePathDeck puts the cards of a file without front matter into the deck
+// named after its path, when it is part of a loaded directory
+func (cp *CardParser) usePathDeck() {
+	name := cp.pathDeck(cp.currentFile)
+	if name == "" {
+		return
+	}
+
+	cp.parseResult.Deck = name
+	if cp.deckRepo == nil {
+		return
+	}
+
+	deck, err := ensureDeck(cp.deckRepo, name)
+	if err != nil {
+		cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+			Reason: fmt.Sprintf("Failed to save deck %q: %v", name, err),
+		})
+		return
+	}
+	cp.defaults.DeckID = deck.ID
+}
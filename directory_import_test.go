@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeCardTree writes card files, by path relative to a new directory
+func writeCardTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDeckNameForPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"cards.txt", "cards"},
+		{filepath.Join("go", "concurrency.txt"), "go::concurrency"},
+		{filepath.Join("lang", "go", "basics.TXT"), "lang::go::basics"},
+		{"v1.2.txt", "v1.2"},
+	}
+
+	for _, test := range tests {
+		if got := deckNameForPath(test.path); got != test.want {
+			t.Errorf("deckNameForPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestFindCardFiles(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{
+		"b.txt":                "",
+		"a.TXT":                "",
+		"notes.md":             "",
+		"go/concurrency.txt":   "",
+		"go/.#concurrency.txt": "",
+		"go/~basics.txt":       "",
+		"go/#basics.txt#":      "",
+		".git/cards.txt":       "",
+		".hidden.txt":          "",
+	})
+
+	files, err := findCardFiles(dir)
+	if err != nil {
+		t.Fatalf("findCardFiles: %v", err)
+	}
+	want := []string{"a.TXT", "b.txt", filepath.Join("go", "concurrency.txt")}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("findCardFiles() = %q, want %q", files, want)
+	}
+
+	if _, err := findCardFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Error("findCardFiles of a missing directory succeeded, want an error")
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{
+		"go/concurrency.txt": "What is a goroutine? >> A lightweight thread\n",
+		"go/basics.txt":      "---\ndeck: Golang\n---\nWhat is Go? >> A language\n",
+		"rust.txt":           "---\ndek: Rust\n---\nWhat is Rust? >> Another language\nno separator\n",
+		".drafts/skip.txt":   "Hidden >> card\n",
+	})
+
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadDirectory(dir); err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	decks := make(map[int64]string)
+	allDecks, err := NewSQLiteDeckRepository(db).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, deck := range allDecks {
+		decks[deck.ID] = deck.Name
+	}
+
+	// Each file's cards go into the deck named after its path, unless its
+	// front matter names one
+	got := make(map[string]string)
+	for _, card := range cp.GetCards() {
+		got[card.Question] = decks[card.DeckID]
+	}
+	want := map[string]string{
+		"What is a goroutine?": "go::concurrency",
+		"What is Go?":          "Golang",
+		"What is Rust?":        "rust",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decks of loaded cards = %q, want %q", got, want)
+	}
+
+	result := cp.GetParseResult()
+	wantFiles := []string{
+		filepath.Join(dir, "go", "basics.txt"),
+		filepath.Join(dir, "go", "concurrency.txt"),
+		filepath.Join(dir, "rust.txt"),
+	}
+	if !reflect.DeepEqual(result.Files, wantFiles) {
+		t.Errorf("files = %q, want %q", result.Files, wantFiles)
+	}
+	if result.ValidCards != 3 {
+		t.Errorf("%d valid cards, want 3", result.ValidCards)
+	}
+
+	var errors []string
+	for _, parseErr := range result.Errors {
+		errors = append(errors, cp.describeLocation(parseErr.FilePath, parseErr.LineNum))
+	}
+	if want := []string{"rust.txt line 1", "rust.txt line 5"}; !reflect.DeepEqual(errors, want) {
+		t.Errorf("errors reported at %q, want %q", errors, want)
+	}
+	if report := cp.GetParseReport(); !strings.Contains(report, "Files parsed: 3") || !strings.Contains(report, "rust.txt line 5: no separator") {
+		t.Errorf("parse report does not name the files:\n%s", report)
+	}
+
+	// Cards added afterwards do not belong to any one file
+	if cp.GetCurrentFile() != "" || !cp.HasFile() {
+		t.Errorf("current file %q, has file %t; want none of the loaded files", cp.GetCurrentFile(), cp.HasFile())
+	}
+}
+
+func TestLoadDirectoryWithoutCardFiles(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{"notes.md": "", ".hidden/cards.txt": "a >> b\n"})
+	if err := NewCardParser().LoadDirectory(dir); err == nil || !strings.Contains(err.Error(), "no card files") {
+		t.Errorf("LoadDirectory error = %v, want no card files found", err)
+	}
+}
+
+func TestReloadFileOfDirectory(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{
+		"go.txt":   "What is Go? >> A language\n",
+		"rust.txt": "What is Rust? >> Another language\n",
+	})
+
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.ImportDirectory(context.Background(), db, dir, nil); err != nil {
+		t.Fatalf("ImportDirectory: %v", err)
+	}
+
+	path := filepath.Join(dir, "go.txt")
+	if err := os.WriteFile(path, []byte("What is Go? >> A language\nWho made Go? >> Google\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.ReloadFile(context.Background(), db, path); err != nil {
+		t.Fatalf("ReloadFile: %v", err)
+	}
+	if cp.GetCurrentFile() != "" {
+		t.Errorf("current file after reloading %s = %q, want none", path, cp.GetCurrentFile())
+	}
+
+	// The reloaded file keeps its path deck
+	cards, err := NewSQLiteCardRepository(db).GetBySourceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deck, err := NewSQLiteDeckRepository(db).GetByName("go")
+	if err != nil {
+		t.Fatalf("deck go: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("%d cards from %s, want 2", len(cards), path)
+	}
+	for _, card := range cards {
+		if card.DeckID.Int64 != deck.ID {
+			t.Errorf("card %q is in deck %d, want %d", card.Question, card.DeckID.Int64, deck.ID)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CardExportOptions select the cards to export. Without a deck the cards of
+// every deck go into one file and lose their deck; exporting a single deck
+// writes its name and settings as front matter. The cards of decks nested in
+// it are exported with it, under its name.
+type CardExportOptions struct {
+	Deck string // Only cards in this deck
+	Tag  string // Only cards with this tag
+}
+
+type CardExportResult struct {
+	Cards   int // Cards written, counting every card generated from a note
+	Notes   int
+	Images  int // Images copied next to the exported file
+	Skipped []string
+
+	exported []*DBCard
+}
+
+// CardExporter writes cards from the database back to a card file. Reading
+// the file with CardParser gives the same cards, and importing it into the
+// same database adds no duplicates.
+type CardExporter struct {
+	cardRepo CardRepository
+	noteRepo NoteRepository
+	deckRepo DeckRepository
+	media    *MediaStore // Images of the exported cards, if any
+}
+
+// exportEntry is a standalone card, or a note together with its cards
+type exportEntry struct {
+	cards []*DBCard
+	note  *DBNote
+}
+
+// exportFrontMatter is the front matter written for a single deck export
+type exportFrontMatter struct {
+	Deck        string        `yaml:"deck"`
+	Description string        `yaml:"description,omitempty"`
+	Format      string        `yaml:"format,omitempty"`
+	Scheduling  *DeckSettings `yaml:"scheduling,omitempty"`
+}
+
+func NewCardExporter(cardRepo CardRepository, noteRepo NoteRepository, deckRepo DeckRepository, media *MediaStore) *CardExporter {
+	return &CardExporter{
+		cardRepo: cardRepo,
+		noteRepo: noteRepo,
+		deckRepo: deckRepo,
+		media:    media,
+	}
+}
+
+// ExportFile writes the selected cards to a card file and copies the images
+// they refer to next to it, where the references in the file point
+func (e *CardExporter) ExportFile(filePath string, options CardExportOptions) (*CardExportResult, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file %s: %w", filePath, err)
+	}
+
+	result, err := e.Export(file, options)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write file %s: %w", filePath, closeErr)
+	}
+	if err != nil || e.media == nil {
+		return result, err
+	}
+
+	for _, dbCard := range result.exported {
+		media, err := e.media.repo.GetByCardID(dbCard.ID)
+		if err != nil {
+			return result, fmt.Errorf("failed to get images of card %d: %w", dbCard.ID, err)
+		}
+		for _, m := range media {
+			if err := e.media.exportFile(m, filePath); err != nil {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("Image %s of card %d: %v", m.Reference, dbCard.ID, err))
+				continue
+			}
+			result.Images++
+		}
+	}
+	return result, nil
+}
+
+// Export writes the selected cards in card file format. Archived cards are
+// left out, as they were removed from their card file, and so are drafts.
+func (e *CardExporter) Export(w io.Writer, options CardExportOptions) (*CardExportResult, error) {
+	out := bufio.NewWriter(w)
+	result := &CardExportResult{}
+
+	var deck *DBDeck
+	if options.Deck != "" {
+		var err error
+		if deck, err = e.deckRepo.GetByName(options.Deck); err != nil {
+			return nil, fmt.Errorf("failed to get deck %q: %w", options.Deck, err)
+		}
+		if err := writeExportFrontMatter(out, deck); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := e.collect(deck, strings.TrimLeft(strings.TrimSpace(options.Tag), "#"))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "# Exported on %s\n\n", time.Now().Format("2006-01-02 15:04"))
+
+	for _, entry := range entries {
+		text, err := formatExportEntry(entry)
+		if err != nil {
+			first := entry.cards[0]
+			result.Skipped = append(result.Skipped, fmt.Sprintf("Card %d (%s): %v", first.ID, truncateExportText(first.Question), err))
+			continue
+		}
+
+		out.WriteString(text)
+		result.exported = append(result.exported, entry.cards...)
+		result.Cards += len(entry.cards)
+		if entry.note != nil {
+			result.Notes++
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write cards: %w", err)
+	}
+	return result, nil
+}
+
+// collect returns the cards to export in the order they were created, with
+// the cards of a note grouped at the position of its first card
+func (e *CardExporter) collect(deck *DBDeck, tag string) ([]*exportEntry, error) {
+	var dbCards []*DBCard
+	var err error
+	if deck != nil {
+		dbCards, err = e.cardRepo.GetByDeckID(deck.ID)
+	} else {
+		dbCards, err = e.cardRepo.GetAll()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
+	}
+
+	var entries []*exportEntry
+	notes := make(map[int64]*exportEntry)
+	for _, dbCard := range dbCards {
+		if dbCard.Status == CardStatusArchived || dbCard.Status == CardStatusDraft {
+			continue
+		}
+		if tag != "" && !hasTag(dbCard.Tags, tag) {
+			continue
+		}
+
+		if !dbCard.NoteID.Valid {
+			entries = append(entries, &exportEntry{cards: []*DBCard{dbCard}})
+			continue
+		}
+		if entry, ok := notes[dbCard.NoteID.Int64]; ok {
+			entry.cards = append(entry.cards, dbCard)
+			continue
+		}
+		note, err := e.noteRepo.GetByID(dbCard.NoteID.Int64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get note of card %d: %w", dbCard.ID, err)
+		}
+		entry := &exportEntry{cards: []*DBCard{dbCard}, note: note}
+		notes[note.ID] = entry
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// hasTag reports whether a comma-separated tag list contains tag
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ",") {
+		if strings.TrimSpace(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeExportFrontMatter(out *bufio.Writer, deck *DBDeck) error {
+	settings, err := ParseDeckSettings(deck.Settings)
+	if err != nil {
+		return err
+	}
+
+	fm := exportFrontMatter{Deck: deck.Name, Description: deck.Description.String}
+	if deck.TextFormat == TextFormatPlain {
+		fm.Format = TextFormatPlain
+	}
+	if !settings.IsDefault() {
+		fm.Scheduling = &settings
+	}
+
+	fmt.Fprintln(out, yamlFrontMatterFence)
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(fm); err != nil {
+		return fmt.Errorf("failed to write front matter: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to write front matter: %w", err)
+	}
+	fmt.Fprintln(out, yamlFrontMatterFence)
+	return nil
+}
+
+// formatExportEntry writes an entry with the metadata of its first card
+func formatExportEntry(entry *exportEntry) (string, error) {
+	first := entry.cards[0]
+	meta := cardMetadata{
+		SourceContext: first.SourceContext.String,
+		PromptType:    first.PromptType,
+		Tags:          normalizeTags(first.Tags),
+	}.withoutDefaults(cardMetadata{})
+
+	text := cardText{first: first.Question, second: first.Answer, meta: meta}
+	if entry.note != nil {
+		var err error
+		if text, err = noteCardText(entry.note.NoteType, entry.note.Content, meta); err != nil {
+			return "", err
+		}
+	}
+	return text.format("")
+}
+
+func truncateExportText(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if len(text) > 40 {
+		return text[:37] + "..."
+	}
+	return text
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// exportedCard is what of a card survives an export and import
+type exportedCard struct {
+	Question, Answer, Tags, SourceContext, PromptType, Note string
+}
+
+func exportedCards(t *testing.T, db *Database) []exportedCard {
+	t.Helper()
+	dbCards, err := NewSQLiteCardRepository(db).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cards []exportedCard
+	for _, dbCard := range dbCards {
+		card := exportedCard{dbCard.Question, dbCard.Answer, dbCard.Tags, dbCard.SourceContext.String, dbCard.PromptType, ""}
+		if dbCard.NoteID.Valid {
+			note, err := NewSQLiteNoteRepository(db).GetByID(dbCard.NoteID.Int64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			card.Note = note.NoteType + ": " + note.Content
+		}
+		cards = append(cards, card)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Question < cards[j].Question })
+	return cards
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"languages.txt": "---\ndeck: Languages\ndescription: Programming languages\n" +
+			"scheduling:\n  desired_retention: 0.85\n---\n" +
+			"What is Go?>>a language #golang @\"The Go Book\" [conceptual]\n",
+		"go.txt": "---\ndeck: Languages::Go\ntags: [golang]\n---\n" +
+			"Q: What does this print?\nfmt.Println(1 << 3)\nA: 8\n--- #bits\n" +
+			"{{c1::Goroutines}} are started with {{c2::go}}\n" +
+			"channel<>chan\n",
+		"geography.txt": "---\ndeck: Geography\n---\nCapital of France?>>Paris\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := newTestDatabase(t)
+	if err := newTestParser(db).LoadDirectory(dir); err != nil {
+		t.Fatalf("LoadDirectory: %v", err)
+	}
+
+	exportPath := filepath.Join(t.TempDir(), "export.txt")
+	exporter := NewCardExporter(NewSQLiteCardRepository(db), NewSQLiteNoteRepository(db), NewSQLiteDeckRepository(db), nil)
+	result, err := exporter.ExportFile(exportPath, CardExportOptions{Deck: "Languages"})
+	if err != nil {
+		t.Fatalf("ExportFile: %v", err)
+	}
+	// The cards of Languages::Go are exported with Languages, Geography is not
+	if result.Cards != 6 || result.Notes != 2 || len(result.Skipped) != 0 {
+		t.Errorf("exported %d cards and %d notes, skipped %q; want 6 cards and 2 notes", result.Cards, result.Notes, result.Skipped)
+	}
+
+	imported := newTestDatabase(t)
+	parser := newTestParser(imported)
+	if err := parser.LoadFromFile(exportPath); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if parser.HasParseErrors() {
+		t.Fatalf("exported file does not parse cleanly: %s", parser.GetParseReport())
+	}
+
+	var want []exportedCard
+	for _, card := range exportedCards(t, db) {
+		if card.Question != "Capital of France?" {
+			want = append(want, card)
+		}
+	}
+	if got := exportedCards(t, imported); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported cards = %+v\nwant %+v", got, want)
+	}
+
+	deck, err := NewSQLiteDeckRepository(imported).GetByName("Languages")
+	if err != nil {
+		t.Fatalf("imported deck: %v", err)
+	}
+	settings, err := ParseDeckSettings(deck.Settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Description.String != "Programming languages" || settings.DesiredRetention != 0.85 {
+		t.Errorf("imported deck has description %q and retention %g", deck.Description.String, settings.DesiredRetention)
+	}
+
+	// Importing the export into the database it came from adds nothing
+	if err := newTestParser(db).LoadFromFile(exportPath); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if got := exportedCards(t, db); len(got) != 7 {
+		t.Errorf("reimporting the export into the same database gives %d cards, want 7", len(got))
+	}
+}
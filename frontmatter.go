@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Fences around the optional front matter at the top of a card file. YAML is
+// written between "---" lines, TOML between "+++" lines:
+//
+//	---
+//	deck: Go
+//	description: Concurrency notes from Go in Action
+//	source: Go in Action
+//	tags: [golang, concurrency]
+//	prompt_type: conceptual
+//	separator: "=>"
+//	format: plain
+//	scheduling:
+//	  desired_retention: 0.92
+//	  maximum_interval: 180
+//	---
+//
+// The fence has to be the first line of the file; anywhere else "---" closes
+// a card block.
+const (
+	yamlFrontMatterFence = "---"
+	tomlFrontMatterFence = "+++"
+)
+
+// frontMatter holds the deck-level defaults declared by a card file
+type frontMatter struct {
+	Deck        string       `yaml:"deck" toml:"deck"`
+	Description string       `yaml:"description" toml:"description"`
+	Source      string       `yaml:"source" toml:"source"`
+	Tags        interface{}  `yaml:"tags" toml:"tags"` // A list or a "golang, concurrency" string
+	PromptType  string       `yaml:"prompt_type" toml:"prompt_type"`
+	Separator   string       `yaml:"separator" toml:"separator"` // Replaces the default separators
+	Format      string       `yaml:"format" toml:"format"`       // Card text as markdown or plain text
+	Scheduling  DeckSettings `yaml:"scheduling" toml:"scheduling"`
+}
+
+func isFrontMatterFence(line string) bool {
+	return line == yamlFrontMatterFence || line == tomlFrontMatterFence
+}
+
+// parseFrontMatter decodes the lines between the fences. Unknown keys are
+// rejected so that a misspelled option does not go unnoticed.
+func parseFrontMatter(fence string, lines []string) (*frontMatter, error) {
+	text := strings.Join(lines, "\n")
+	fm := &frontMatter{}
+
+	if fence == tomlFrontMatterFence {
+		md, err := toml.Decode(text, fm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TOML: %w", err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			return nil, fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+		}
+	} else {
+		decoder := yaml.NewDecoder(strings.NewReader(text))
+		decoder.KnownFields(true)
+		err := decoder.Decode(fm)
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// "line 3: field bogus not found in type main.frontMatter"
+			messages := make([]string, len(typeErr.Errors))
+			for i, message := range typeErr.Errors {
+				if idx := strings.Index(message, " not found in type "); idx >= 0 {
+					message = strings.Replace(message[:idx], "field ", "unknown key ", 1)
+				}
+				messages[i] = message
+			}
+			return nil, fmt.Errorf("invalid YAML: %s", strings.Join(messages, "; "))
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+
+	if fm.PromptType != "" {
+		promptType, ok := NormalizePromptType(fm.PromptType)
+		if !ok {
+			return nil, fmt.Errorf("unknown prompt type %q. Expected one of: %s", fm.PromptType, strings.Join(promptTypes, ", "))
+		}
+		fm.PromptType = promptType
+	}
+
+	if fm.Separator != "" && strings.ContainsAny(fm.Separator, " \t\"`\\#") {
+		return nil, fmt.Errorf("separator %q cannot contain spaces, quotes, backslashes or #", fm.Separator)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(fm.Format)) {
+	case "", TextFormatMarkdown:
+		fm.Format = TextFormatMarkdown
+	case TextFormatPlain:
+		fm.Format = TextFormatPlain
+	default:
+		return nil, fmt.Errorf("unknown format %q. Expected %s or %s", fm.Format, TextFormatMarkdown, TextFormatPlain)
+	}
+
+	if err := fm.Scheduling.Validate(); err != nil {
+		return nil, err
+	}
+
+	return fm, nil
+}
+
+// tags returns the declared tags in the comma-separated form stored on cards
+func (fm *frontMatter) tags() (string, error) {
+	switch tags := fm.Tags.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return normalizeTags(tags), nil
+	case []interface{}:
+		var names []string
+		for _, tag := range tags {
+			name, ok := tag.(string)
+			if !ok {
+				return "", fmt.Errorf("tags must be text, got %v", tag)
+			}
+			names = append(names, name)
+		}
+		return normalizeTags(strings.Join(names, ",")), nil
+	default:
+		return "", fmt.Errorf("tags must be a list or a comma-separated string")
+	}
+}
+
+// deckName is the declared deck. When the front matter does not name one the
+// deck follows the file's path inside a loaded directory, or its file name.
+func (fm *frontMatter) deckName(cp *CardParser) string {
+	if name := strings.TrimSpace(fm.Deck); name != "" {
+		return name
+	}
+	if name := cp.pathDeck(cp.currentFile); name != "" {
+		return name
+	}
+	return strings.TrimSuffix(filepath.Base(cp.currentFile), filepath.Ext(cp.currentFile))
+}
+
+// applyFrontMatter turns parsed front matter into the defaults used for the
+// rest of the file, creating or updating the deck it declares
+func (cp *CardParser) applyFrontMatter(fm *frontMatter) error {
+	tags, err := fm.tags()
+	if err != nil {
+		return err
+	}
+
+	cp.defaults = cardMetadata{
+		SourceContext: strings.TrimSpace(fm.Source),
+		PromptType:    fm.PromptType,
+		Tags:          tags,
+	}
+
+	cp.separator = fm.Separator
+	cp.parseResult.Deck = fm.deckName(cp)
+	cp.parseResult.Settings = fm.Scheduling
+
+	if cp.deckRepo == nil {
+		return nil
+	}
+
+	deck, err := saveDeck(cp.deckRepo, cp.parseResult.Deck, strings.TrimSpace(fm.Description), fm.Format, fm.Scheduling)
+	if err != nil {
+		return fmt.Errorf("failed to save deck %q: %w", cp.parseResult.Deck, err)
+	}
+	cp.defaults.DeckID = deck.ID
+	return nil
+}
+
+// describeSettings formats deck settings for the parse report
+func describeSettings(settings DeckSettings) string {
+	var parts []string
+	if settings.DesiredRetention > 0 {
+		parts = append(parts, fmt.Sprintf("desired retention %.0f%%", settings.DesiredRetention*100))
+	}
+	if settings.MaximumInterval > 0 {
+		parts = append(parts, fmt.Sprintf("maximum interval %d days", settings.MaximumInterval))
+	}
+	return strings.Join(parts, ", ")
+}
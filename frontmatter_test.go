@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromFileFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		deck    string
+		card    Card   // The first card, compared on its text and metadata
+		err     string // Part of the only parse error, if any
+	}{
+		{
+			name:    "YAML defaults",
+			content: "---\ndeck: Go\nsource: Go in Action\ntags: [golang, concurrency]\nprompt_type: conceptual\n---\nWhat is a goroutine? >> A lightweight thread\n",
+			deck:    "Go",
+			card:    Card{Question: "What is a goroutine?", Answer: "A lightweight thread", SourceContext: "Go in Action", Tags: "golang, concurrency", PromptType: "conceptual"},
+		},
+		{
+			name:    "TOML defaults",
+			content: "+++\ndeck = \"Go\"\ntags = \"golang\"\n+++\nWhat is a channel? >> A typed pipe\n",
+			deck:    "Go",
+			card:    Card{Question: "What is a channel?", Answer: "A typed pipe", Tags: "golang"},
+		},
+		{
+			name:    "inline metadata adds to the defaults",
+			content: "---\ntags: golang\nsource: Go in Action\n---\nWhat is a slice? >> A view of an array #arrays @\"The Go Blog\"\n",
+			deck:    "cards",
+			card:    Card{Question: "What is a slice?", Answer: "A view of an array", SourceContext: "The Go Blog", Tags: "golang, arrays"},
+		},
+		{
+			name:    "declared separator",
+			content: "---\nseparator: \"=>\"\n---\na >> b => c\n",
+			deck:    "cards",
+			card:    Card{Question: "a >> b", Answer: "c"},
+		},
+		{
+			name:    "front matter only on the first line",
+			content: "What is Go? >> A language\n---\n",
+			card:    Card{Question: "What is Go?", Answer: "A language"},
+		},
+		{
+			name:    "unknown key",
+			content: "---\ndek: Go\n---\nWhat is Go? >> A language\n",
+			card:    Card{Question: "What is Go?", Answer: "A language"},
+			err:     "unknown key dek",
+		},
+		{
+			name:    "unknown prompt type",
+			content: "---\nprompt_type: trivia\n---\nWhat is Go? >> A language\n",
+			card:    Card{Question: "What is Go?", Answer: "A language"},
+			err:     `unknown prompt type "trivia"`,
+		},
+		{
+			name:    "never closed",
+			content: "---\ndeck: Go\nWhat is Go? >> A language\n",
+			err:     "never closed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cards.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cp := NewCardParser()
+			if err := cp.LoadFromFile(path); err != nil {
+				t.Fatalf("LoadFromFile: %v", err)
+			}
+			result := cp.GetParseResult()
+
+			if result.Deck != test.deck {
+				t.Errorf("deck = %q, want %q", result.Deck, test.deck)
+			}
+
+			errors := result.Errors
+			switch {
+			case test.err == "" && len(errors) > 0:
+				t.Errorf("unexpected parse errors %+v", errors)
+			case test.err != "" && (len(errors) != 1 || !strings.Contains(errors[0].Reason, test.err)):
+				t.Errorf("parse errors %+v, want one containing %q", errors, test.err)
+			}
+
+			cards := cp.GetCards()
+			if test.card.Question == "" {
+				if len(cards) > 0 {
+					t.Errorf("got cards %+v, want none", cards)
+				}
+				return
+			}
+			if len(cards) == 0 {
+				t.Fatalf("got no cards")
+			}
+			got := cards[0]
+			if got.Question != test.card.Question || got.Answer != test.card.Answer ||
+				got.SourceContext != test.card.SourceContext || got.Tags != test.card.Tags || got.PromptType != test.card.PromptType {
+				t.Errorf("card = %+v, want %+v", got, test.card)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -22,6 +23,8 @@ type FSRSManager struct {
 	states       map[string]*ReviewState
 	stateFile    string
 	reviewRepo   ReviewStateRepository
+	deckRepo     DeckRepository
+	reviewLog    ReviewLogRepository
 	useDatabase  bool
 }
 
@@ -34,15 +37,21 @@ func NewFSRSManager(stateFile string) *FSRSManager {
 	}
 }
 
-func NewFSRSManagerWithDatabase(reviewRepo ReviewStateRepository) *FSRSManager {
+func NewFSRSManagerWithDatabase(reviewRepo ReviewStateRepository, deckRepo DeckRepository) *FSRSManager {
 	return &FSRSManager{
 		fsrs:        fsrs.NewFSRS(fsrs.DefaultParam()),
 		states:      make(map[string]*ReviewState),
 		reviewRepo:  reviewRepo,
+		deckRepo:    deckRepo,
 		useDatabase: true,
 	}
 }
 
+// SetReviewLog makes ReviewCard record every rating in the review log
+func (fm *FSRSManager) SetReviewLog(reviewLog ReviewLogRepository) {
+	fm.reviewLog = reviewLog
+}
+
 func (fm *FSRSManager) LoadState() error {
 	if _, err := os.Stat(fm.stateFile); os.IsNotExist(err) {
 		return nil
@@ -81,6 +90,10 @@ func (fm *FSRSManager) SaveState() error {
 }
 
 func (fm *FSRSManager) getCardID(card Card) string {
+	// Sibling cards generated from one line share FilePath and LineNum
+	if card.Ordinal > 0 {
+		return fmt.Sprintf("%s:%d#%d", card.FilePath, card.LineNum, card.Ordinal)
+	}
 	return fmt.Sprintf("%s:%d", card.FilePath, card.LineNum)
 }
 
@@ -156,11 +169,14 @@ func (fm *FSRSManager) IsCardDue(card Card) bool {
 	return time.Now().After(state.FSRSCard.Due)
 }
 
-func (fm *FSRSManager) ReviewCard(card Card, rating fsrs.Rating) error {
+// ReviewCard schedules a card after it was rated. elapsed is how long it took
+// to answer, it is kept in the review log together with the rating.
+func (fm *FSRSManager) ReviewCard(card Card, rating fsrs.Rating, elapsed time.Duration) error {
 	state := fm.GetCardState(card)
 	now := time.Now()
+	before := state.FSRSCard
 
-	schedulingInfo := fm.fsrs.Next(state.FSRSCard, now, rating)
+	schedulingInfo := fm.schedulerFor(card).Next(state.FSRSCard, now, rating)
 
 	state.FSRSCard = schedulingInfo.Card
 	state.LastReview = now
@@ -185,35 +201,133 @@ func (fm *FSRSManager) ReviewCard(card Card, rating fsrs.Rating) error {
 		existing, err := fm.reviewRepo.GetByCardID(card.ID)
 		if err != nil {
 			// Create new state
-			return fm.reviewRepo.Create(dbState)
+			err = fm.reviewRepo.Create(dbState)
 		} else {
 			// Update existing state
 			dbState.ID = existing.ID
-			return fm.reviewRepo.Update(dbState)
+			err = fm.reviewRepo.Update(dbState)
 		}
+		if err != nil {
+			return err
+		}
+
+		if fm.reviewLog == nil {
+			return nil
+		}
+
+		// The card is already rescheduled, failing here would have it rated
+		// a second time
+		err = fm.reviewLog.Create(&DBReviewLog{
+			CardID:           card.ID,
+			ReviewedAt:       now,
+			Rating:           int(rating),
+			ElapsedMs:        elapsed.Milliseconds(),
+			StateBefore:      int(before.State),
+			StateAfter:       int(state.FSRSCard.State),
+			StabilityBefore:  before.Stability,
+			StabilityAfter:   state.FSRSCard.Stability,
+			DifficultyBefore: before.Difficulty,
+			DifficultyAfter:  state.FSRSCard.Difficulty,
+			ScheduledDays:    int(state.FSRSCard.ScheduledDays),
+			DueDate:          state.FSRSCard.Due,
+		})
+		if err != nil {
+			log.Printf("Failed to log review of card %d: %v", card.ID, err)
+		}
+		return nil
 	}
 
 	// Fall back to file-based saving
 	return fm.SaveState()
 }
 
+// schedulerFor returns the scheduler for a card, using the scheduling options
+// of its deck when the deck sets any
+func (fm *FSRSManager) schedulerFor(card Card) *fsrs.FSRS {
+	if card.DeckID == 0 || fm.deckRepo == nil {
+		return fm.fsrs
+	}
+
+	deck, err := fm.deckRepo.GetByID(card.DeckID)
+	if err != nil {
+		return fm.fsrs
+	}
+
+	settings, err := ParseDeckSettings(deck.Settings)
+	if err != nil || settings.IsDefault() {
+		return fm.fsrs
+	}
+
+	return fsrs.NewFSRS(settings.Parameters())
+}
+
+// GetDueCards returns the cards that are due for review, leaving out archived
+// cards. Sibling cards that were generated from the same note (both
+// directions of a reverse card, the deletions of a cloze note) are never
+// shown on the same day: once one sibling is due or has been reviewed today,
+// the others wait for another day.
 func (fm *FSRSManager) GetDueCards(cards []Card) []Card {
+	today := time.Now().Format("2006-01-02")
+
+	// note ID -> IDs of its cards reviewed today
+	reviewedToday := make(map[int64][]int64)
+	for _, card := range cards {
+		if card.NoteID == 0 {
+			continue
+		}
+		state := fm.GetCardState(card)
+		if state.ReviewCount > 0 && state.LastReview.Format("2006-01-02") == today {
+			reviewedToday[card.NoteID] = append(reviewedToday[card.NoteID], card.ID)
+		}
+	}
+
+	// note ID -> ID of the sibling picked for today
+	picked := make(map[int64]int64)
+
 	var dueCards []Card
 	for _, card := range cards {
+		if card.Status == CardStatusArchived || card.Status == CardStatusDraft {
+			continue
+		}
+		if card.NoteID != 0 {
+			if hasOtherSibling(reviewedToday[card.NoteID], card.ID) {
+				continue
+			}
+			if pickedID, ok := picked[card.NoteID]; ok && pickedID != card.ID {
+				continue
+			}
+		}
+
 		if fm.IsCardDue(card) {
 			dueCards = append(dueCards, card)
+			if card.NoteID != 0 {
+				picked[card.NoteID] = card.ID
+			}
 		}
 	}
 	return dueCards
 }
 
+func hasOtherSibling(siblingIDs []int64, cardID int64) bool {
+	for _, id := range siblingIDs {
+		if id != cardID {
+			return true
+		}
+	}
+	return false
+}
+
 func (fm *FSRSManager) GetStats(cards []Card) (total, due, reviewed int) {
-	total = len(cards)
+	// Count due cards the same way the study queue does, so siblings held
+	// back for another day are not reported as due
+	due = len(fm.GetDueCards(cards))
 	for _, card := range cards {
-		state := fm.GetCardState(card)
-		if fm.IsCardDue(card) {
-			due++
+		// Drafts in the inbox are not studied yet
+		if card.Status == CardStatusDraft {
+			continue
 		}
+		total++
+		state := fm.GetCardState(card)
 		if state.ReviewCount > 0 {
 			reviewed++
 		}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// newTestScheduler loads card file content into a test database and returns
+// its cards with a manager scheduling them
+func newTestScheduler(t *testing.T, content string) (*FSRSManager, []Card) {
+	t.Helper()
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	reloadCards(t, cp, filepath.Join(t.TempDir(), "cards.txt"), content)
+	fm := NewFSRSManagerWithDatabase(NewSQLiteReviewStateRepository(db), NewSQLiteDeckRepository(db))
+	return fm, cp.GetCards()
+}
+
+// setReviewed makes a card look reviewed at a time and due at another
+func setReviewed(t *testing.T, fm *FSRSManager, card Card, reviewed, due time.Time) {
+	t.Helper()
+	state := fm.GetCardState(card)
+	state.FSRSCard.State = fsrs.Review
+	state.FSRSCard.Due = due
+	state.FSRSCard.LastReview = reviewed
+	data, err := FSRSCardToJSON(state.FSRSCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fm.reviewRepo.Update(&DBReviewState{CardID: card.ID, FSRSCardData: data, LastReview: reviewed, ReviewCount: 1, DueDate: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dueByNote counts the due cards of each note, with standalone cards under 0
+func dueByNote(cards []Card) map[int64]int {
+	due := make(map[int64]int)
+	for _, card := range cards {
+		due[card.NoteID]++
+	}
+	return due
+}
+
+const siblingCards = "{{c1::Go}} was announced by {{c2::Google}} in {{c3::2009}}\n" +
+	"channel<>chan\n" +
+	"What is Go?>>a language\n"
+
+func TestGetDueCardsSpreadsSiblings(t *testing.T) {
+	fm, cards := newTestScheduler(t, siblingCards)
+	if len(cards) != 6 {
+		t.Fatalf("loaded %d cards, want 6", len(cards))
+	}
+
+	// All new, so all due: one card of each note, and the standalone card
+	due := fm.GetDueCards(cards)
+	for noteID, n := range dueByNote(due) {
+		if n != 1 {
+			t.Errorf("%d cards of note %d due, want 1", n, noteID)
+		}
+	}
+	if len(due) != 3 {
+		t.Errorf("%d cards due, want 3", len(due))
+	}
+	if _, dueCount, _ := fm.GetStats(cards); dueCount != len(due) {
+		t.Errorf("GetStats counts %d due, GetDueCards returns %d", dueCount, len(due))
+	}
+}
+
+func TestGetDueCardsSiblingsDueTheSameDay(t *testing.T) {
+	fm, cards := newTestScheduler(t, siblingCards)
+
+	// Every card was reviewed before and came due again today
+	yesterday := time.Now().AddDate(0, 0, -1)
+	for _, card := range cards {
+		setReviewed(t, fm, card, yesterday.Add(-time.Hour), yesterday)
+	}
+
+	due := fm.GetDueCards(cards)
+	if len(due) != 3 {
+		t.Fatalf("%d cards due, want one per note and the standalone card", len(due))
+	}
+	// The first sibling in the list is the one shown
+	picked := make(map[int64]int64)
+	for _, card := range cards {
+		if _, ok := picked[card.NoteID]; !ok && card.NoteID != 0 {
+			picked[card.NoteID] = card.ID
+		}
+	}
+	for _, card := range due {
+		if card.NoteID != 0 && picked[card.NoteID] != card.ID {
+			t.Errorf("card %d of note %d due, want card %d", card.ID, card.NoteID, picked[card.NoteID])
+		}
+	}
+}
+
+func TestGetDueCardsHoldsSiblingsOfCardReviewedToday(t *testing.T) {
+	fm, cards := newTestScheduler(t, siblingCards)
+
+	var cloze []Card
+	for _, card := range cards {
+		if card.NoteID != 0 && card.NoteID == cards[0].NoteID {
+			cloze = append(cloze, card)
+		}
+	}
+	if len(cloze) != 3 {
+		t.Fatalf("cloze note has %d cards, want 3", len(cloze))
+	}
+
+	// One sibling was reviewed today and is due again later today, the
+	// others are due
+	setReviewed(t, fm, cloze[0], time.Now(), time.Now().Add(time.Hour))
+	for _, card := range cloze[1:] {
+		setReviewed(t, fm, card, time.Now().AddDate(0, 0, -3), time.Now().AddDate(0, 0, -1))
+	}
+
+	for _, card := range fm.GetDueCards(cards) {
+		if card.NoteID == cloze[0].NoteID {
+			t.Errorf("sibling %q is due on the day another sibling was reviewed", card.Question)
+		}
+	}
+
+	// Once the reviewed sibling is due again, it is the one shown
+	setReviewed(t, fm, cloze[0], time.Now(), time.Now().Add(-time.Minute))
+	var due []Card
+	for _, card := range fm.GetDueCards(cards) {
+		if card.NoteID == cloze[0].NoteID {
+			due = append(due, card)
+		}
+	}
+	if len(due) != 1 || due[0].ID != cloze[0].ID {
+		t.Errorf("due cards of the note = %+v, want only the card reviewed today", due)
+	}
+}
+
+func TestGetDueCardsSkipsArchivedAndDrafts(t *testing.T) {
+	fm, cards := newTestScheduler(t, "Q1>>A1\nQ2>>A2\nQ3>>A3\n")
+	cards[0].Status = CardStatusArchived
+	cards[1].Status = CardStatusDraft
+
+	due := fm.GetDueCards(cards)
+	if len(due) != 1 || due[0].ID != cards[2].ID {
+		t.Errorf("due cards = %+v, want only the active card", due)
+	}
+}
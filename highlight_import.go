@@ -0,0 +1,398 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Highlight is a passage marked while reading, with the note written on it
+type Highlight struct {
+	Title    string
+	Author   string
+	Text     string
+	Note     string
+	Location string
+	LineNum  int // Line of the highlight in the file it was read from
+}
+
+// Source describes the book a highlight is from, as stored in a card's
+// SourceContext
+func (h Highlight) Source() string {
+	if h.Author == "" {
+		return h.Title
+	}
+	if h.Title == "" {
+		return h.Author
+	}
+	return fmt.Sprintf("%s by %s", h.Title, h.Author)
+}
+
+// HighlightDraft is a card made from a highlight that still needs its
+// question. The highlight is the answer, or with UseAsContext it is quoted
+// above the question and the answer is written separately.
+type HighlightDraft struct {
+	Highlight    Highlight
+	UseAsContext bool
+	Question     string
+	Answer       string
+}
+
+// Ready reports whether the draft has what it needs to become a card
+func (d *HighlightDraft) Ready() bool {
+	if strings.TrimSpace(d.Question) == "" {
+		return false
+	}
+	return !d.UseAsContext || strings.TrimSpace(d.Answer) != ""
+}
+
+// Card returns the question and answer of the card the draft becomes
+func (d *HighlightDraft) Card() (string, string) {
+	question := strings.TrimSpace(d.Question)
+	if !d.UseAsContext {
+		return question, d.Highlight.Text
+	}
+
+	var quote strings.Builder
+	for _, line := range strings.Split(d.Highlight.Text, "\n") {
+		quote.WriteString("> " + line + "\n")
+	}
+	return quote.String() + "\n" + question, strings.TrimSpace(d.Answer)
+}
+
+// ReadHighlights reads a Kindle "My Clippings.txt" file, or a CSV or TSV
+// export of highlights, and returns a draft for each highlight
+func ReadHighlights(filePath string) ([]*HighlightDraft, error) {
+	var highlights []Highlight
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".tsv":
+		table, err := ReadCSVFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if highlights, err = parseHighlightTable(table); err != nil {
+			return nil, fmt.Errorf("failed to read highlights from %s: %w", filePath, err)
+		}
+	default:
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+		}
+		content, _, err := decodeCardFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
+		}
+		highlights = parseKindleClippings(content)
+	}
+
+	drafts := make([]*HighlightDraft, len(highlights))
+	for i, highlight := range highlights {
+		drafts[i] = &HighlightDraft{Highlight: highlight}
+	}
+	return drafts, nil
+}
+
+// Line that ends each clipping in My Clippings.txt
+const kindleClippingSeparator = "=========="
+
+// parseKindleClippings reads the clippings a Kindle keeps. Each one is a
+// "Title (Author)" line, a line describing it, a blank line and the text:
+//
+//	Thinking, Fast and Slow (Daniel Kahneman)
+//	- Your Highlight on page 20 | Location 300-301 | Added on Monday, ...
+//
+//	Nothing in life is as important as you think it is.
+//	==========
+//
+// Notes are attached to the highlight they were written on and bookmarks
+// are left out, as are highlights already read from the same book.
+func parseKindleClippings(content string) []Highlight {
+	content = strings.TrimPrefix(content, "\ufeff")
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var highlights []Highlight
+	seen := make(map[string]bool)
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && strings.TrimSpace(lines[i]) != kindleClippingSeparator {
+			continue
+		}
+		clipping := lines[start:i]
+		lineNum := start + 1
+		start = i + 1
+
+		// Kindles write a byte order mark before some titles
+		for len(clipping) > 0 && strings.TrimSpace(strings.TrimPrefix(clipping[0], "\ufeff")) == "" {
+			clipping = clipping[1:]
+			lineNum++
+		}
+		if len(clipping) < 2 {
+			continue
+		}
+
+		title, author := splitKindleTitle(strings.TrimPrefix(clipping[0], "\ufeff"))
+		kind, location := parseKindleDescription(clipping[1])
+		text := strings.TrimSpace(strings.Join(clipping[2:], "\n"))
+		if text == "" {
+			continue
+		}
+
+		switch kind {
+		case "note":
+			// A note follows the highlight it belongs to
+			if n := len(highlights); n > 0 && highlights[n-1].Title == title && highlights[n-1].Note == "" {
+				highlights[n-1].Note = text
+			}
+		case "highlight":
+			key := title + "\x00" + text
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			highlights = append(highlights, Highlight{
+				Title:    title,
+				Author:   author,
+				Text:     text,
+				Location: location,
+				LineNum:  lineNum + 3,
+			})
+		}
+	}
+	return highlights
+}
+
+// splitKindleTitle splits "Title (Author)" into its title and author
+func splitKindleTitle(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if !strings.HasSuffix(line, ")") {
+		return line, ""
+	}
+	open := strings.LastIndex(line, "(")
+	if open <= 0 {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:open]), strings.TrimSpace(line[open+1 : len(line)-1])
+}
+
+// parseKindleDescription returns the kind of clipping, highlight, note or
+// bookmark, and where in the book it is, from a line like
+// "- Your Highlight on page 20 | Location 300-301 | Added on ...". Kindles
+// set to other languages write the same parts in that language, so the
+// place is found by its numbers and the last part is always the date.
+func parseKindleDescription(line string) (string, string) {
+	parts := strings.Split(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "-")), "|")
+	first := strings.ToLower(parts[0])
+
+	kind := "highlight"
+	for k, words := range kindleClippingKinds {
+		for _, word := range words {
+			if strings.Contains(first, word) {
+				kind = k
+			}
+		}
+	}
+
+	var location []string
+	words := strings.Fields(parts[0])
+	for i, word := range words {
+		if strings.ContainsAny(word, "0123456789") {
+			if i > 0 {
+				i--
+			}
+			location = append(location, strings.Join(words[i:], " "))
+			break
+		}
+	}
+	if len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	for _, part := range parts[1:] {
+		if part = strings.TrimSpace(part); part != "" {
+			location = append(location, part)
+		}
+	}
+	return kind, strings.Join(location, ", ")
+}
+
+// Words naming the clippings that are not highlights, in the languages
+// Kindles are commonly set to
+var kindleClippingKinds = map[string][]string{
+	"note":     {"note", "nota", "notiz"},
+	"bookmark": {"bookmark", "marcador", "lesezeichen", "signet", "segnalibro"},
+}
+
+// Header names recognised for the columns of a highlight export
+var highlightHeaderNames = map[string][]string{
+	"text":     {"highlight", "text", "quote", "annotation", "highlight text"},
+	"title":    {"title", "book title", "book"},
+	"author":   {"author", "book author", "authors"},
+	"note":     {"note", "notes", "comment"},
+	"location": {"location", "page", "location/page"},
+	"type":     {"annotation type", "type"},
+}
+
+// parseHighlightTable reads highlights from an export such as Readwise's,
+// which has a column per field, or the Kindle app's notebook export, which
+// names the book in the rows above its header
+func parseHighlightTable(table *CSVTable) ([]Highlight, error) {
+	header := -1
+	columns := make(map[string]int)
+	for row, record := range table.Records {
+		found := make(map[string]int)
+		for column, cell := range record {
+			cell = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff")))
+			for field, names := range highlightHeaderNames {
+				if _, taken := found[field]; !taken && containsString(names, cell) {
+					found[field] = column
+				}
+			}
+		}
+		if _, ok := found["text"]; ok {
+			header, columns = row, found
+			break
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("no column holds the highlight text. Expected a header such as Highlight, Text or Annotation")
+	}
+
+	title, author := "", ""
+	previous := ""
+	for _, record := range table.Records[:header] {
+		if len(record) == 0 {
+			continue
+		}
+		cell := strings.TrimSpace(strings.TrimPrefix(record[0], "\ufeff"))
+		switch {
+		case strings.HasPrefix(cell, "by "):
+			author = strings.TrimSpace(cell[3:])
+		case strings.HasPrefix(previous, "Your Kindle Notes For"):
+			title = cell
+		}
+		previous = cell
+	}
+
+	value := func(record []string, field string) string {
+		column, ok := columns[field]
+		if !ok || column >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[column])
+	}
+
+	var highlights []Highlight
+	for i, record := range table.Records[header+1:] {
+		text := value(record, "text")
+		if text == "" {
+			continue
+		}
+		kind := strings.ToLower(value(record, "type"))
+		if strings.HasPrefix(kind, "note") {
+			if n := len(highlights); n > 0 && highlights[n-1].Note == "" {
+				highlights[n-1].Note = text
+			}
+			continue
+		}
+		if strings.HasPrefix(kind, "bookmark") {
+			continue
+		}
+
+		highlight := Highlight{
+			Title:    value(record, "title"),
+			Author:   value(record, "author"),
+			Text:     text,
+			Note:     value(record, "note"),
+			Location: value(record, "location"),
+			LineNum:  table.Lines[header+1+i],
+		}
+		if highlight.Title == "" {
+			highlight.Title = title
+		}
+		if highlight.Author == "" {
+			highlight.Author = author
+		}
+		highlights = append(highlights, highlight)
+	}
+	return highlights, nil
+}
+
+type HighlightImportResult struct {
+	Imported   int
+	Duplicates int
+	Skipped    int // Drafts that were left without a question
+	Drafts     int // Unfinished drafts saved to the inbox
+	Errors     []ParseError
+}
+
+type HighlightImporter struct {
+	cardRepo CardRepository
+	inbox    bool
+}
+
+func NewHighlightImporter(cardRepo CardRepository) *HighlightImporter {
+	return &HighlightImporter{cardRepo: cardRepo}
+}
+
+// SetInbox makes highlights that were left without a question go to the
+// inbox, so their questions can be written later
+func (hi *HighlightImporter) SetInbox(enabled bool) {
+	hi.inbox = enabled
+}
+
+// Import stores the drafts that are ready as cards, and the others in the
+// inbox when that is enabled. Their source is the book the highlight is from.
+func (hi *HighlightImporter) Import(drafts []*HighlightDraft, sourceFile string, tags string) (*HighlightImportResult, error) {
+	result := &HighlightImportResult{}
+	tags = normalizeTags(tags)
+
+	for _, draft := range drafts {
+		if !draft.Ready() && !hi.inbox {
+			result.Skipped++
+			continue
+		}
+		question, answer := draft.Card()
+
+		status := CardStatusActive
+		if !draft.Ready() {
+			status = CardStatusDraft
+		} else {
+			exists, err := hi.cardRepo.CardExists(question, answer)
+			if err != nil {
+				return result, fmt.Errorf("failed to check card existence: %w", err)
+			}
+			if exists {
+				result.Duplicates++
+				continue
+			}
+		}
+
+		source := draft.Highlight.Source()
+		card := &DBCard{
+			Question:      question,
+			Answer:        answer,
+			SourceFile:    sourceFile,
+			SourceLine:    draft.Highlight.LineNum,
+			SourceContext: sql.NullString{String: source, Valid: source != ""},
+			PromptType:    "factual",
+			Tags:          tags,
+			Status:        status,
+		}
+		if err := hi.cardRepo.Create(card); err != nil {
+			result.Errors = append(result.Errors, ParseError{
+				LineNum: draft.Highlight.LineNum,
+				Line:    draft.Highlight.Text,
+				Reason:  fmt.Sprintf("Database import failed: %v", err),
+			})
+			continue
+		}
+		if status == CardStatusDraft {
+			result.Drafts++
+		} else {
+			result.Imported++
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKindleClippings(t *testing.T) {
+	const clippings = "\ufeffThinking, Fast and Slow (Daniel Kahneman)\r\n" +
+		"- Your Highlight on page 20 | Location 300-301 | Added on Monday, 3 June 2024 08:12:44\r\n" +
+		"\r\n" +
+		"Nothing in life is as important as you think it is.\r\n" +
+		"==========\r\n" +
+		"Thinking, Fast and Slow (Daniel Kahneman)\r\n" +
+		"- Your Note on page 20 | Location 301 | Added on Monday, 3 June 2024 08:13:02\r\n" +
+		"\r\n" +
+		"The focusing illusion\r\n" +
+		"==========\r\n" +
+		"Thinking, Fast and Slow (Daniel Kahneman)\r\n" +
+		"- Your Bookmark on page 31 | Location 470 | Added on Monday, 3 June 2024 09:00:00\r\n" +
+		"\r\n" +
+		"\r\n" +
+		"==========\r\n" +
+		"Thinking, Fast and Slow (Daniel Kahneman)\r\n" +
+		"- Your Highlight on page 20 | Location 300-301 | Added on Tuesday, 4 June 2024 10:00:00\r\n" +
+		"\r\n" +
+		"Nothing in life is as important as you think it is.\r\n" +
+		"==========\r\n" +
+		"\ufeffMeditations\r\n" +
+		"- La subrayado en la posición 120-122 | Añadido el lunes\r\n" +
+		"\r\n" +
+		"You have power over your mind.\r\n" +
+		"==========\r\n"
+
+	want := []Highlight{
+		{
+			Title:    "Thinking, Fast and Slow",
+			Author:   "Daniel Kahneman",
+			Text:     "Nothing in life is as important as you think it is.",
+			Note:     "The focusing illusion",
+			Location: "page 20, Location 300-301",
+			LineNum:  4,
+		},
+		{
+			Title:    "Meditations",
+			Text:     "You have power over your mind.",
+			Location: "posición 120-122",
+			LineNum:  24,
+		},
+	}
+
+	got := parseKindleClippings(clippings)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKindleClippings =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseKindleDescription(t *testing.T) {
+	tests := []struct {
+		line, kind, location string
+	}{
+		{"- Your Highlight on page 20 | Location 300-301 | Added on Tuesday, 4 June 2024 10:00:00", "highlight", "page 20, Location 300-301"},
+		{"- Your Highlight on Location 300-301 | Added on Tuesday, 4 June 2024 10:00:00", "highlight", "Location 300-301"},
+		{"- Your Note on Location 301 | Added on Tuesday, 4 June 2024 10:01:00", "note", "Location 301"},
+		{"- Your Bookmark on page 12 | Added on Tuesday, 4 June 2024 10:02:00", "bookmark", "page 12"},
+		{"- Highlight Loc. 1234-35  | Added on Monday, 1 January 2024", "highlight", "Loc. 1234-35"},
+		{"- La subrayado en la posición 120-122 | Añadido el lunes, 3 de junio de 2024 9:00:00", "highlight", "posición 120-122"},
+		{"- La nota en la página 7 | posición 98 | Añadido el lunes", "note", "página 7, posición 98"},
+		{"- Ihre Markierung bei Position 300-301 | Hinzugefügt am Montag, 3. Juni 2024", "highlight", "Position 300-301"},
+		{"- Ihr Lesezeichen auf Seite 5 | Hinzugefügt am Montag, 3. Juni 2024", "bookmark", "Seite 5"},
+		{"- Votre surlignement sur la page 20 | emplacement 300-301 | Ajouté le lundi 3 juin 2024", "highlight", "page 20, emplacement 300-301"},
+	}
+
+	for _, test := range tests {
+		kind, location := parseKindleDescription(test.line)
+		if kind != test.kind || location != test.location {
+			t.Errorf("parseKindleDescription(%q) = %q, %q, want %q, %q", test.line, kind, location, test.kind, test.location)
+		}
+	}
+}
+
+func TestSplitKindleTitle(t *testing.T) {
+	tests := []struct {
+		line, title, author string
+	}{
+		{"Thinking, Fast and Slow (Daniel Kahneman)", "Thinking, Fast and Slow", "Daniel Kahneman"},
+		{"The C Programming Language (2nd Edition) (Kernighan, Brian)", "The C Programming Language (2nd Edition)", "Kernighan, Brian"},
+		{"Meditations", "Meditations", ""},
+		{"(Anonymous)", "(Anonymous)", ""},
+	}
+
+	for _, test := range tests {
+		title, author := splitKindleTitle(test.line)
+		if title != test.title || author != test.author {
+			t.Errorf("splitKindleTitle(%q) = %q, %q, want %q, %q", test.line, title, author, test.title, test.author)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Progress is reported after this many cards or notes, so that a large file
+// does not flood the UI with updates
+const importProgressInterval = 100
+
+// ErrImportCancelled is returned when an import is cancelled. Nothing it
+// wrote to the database is kept.
+var ErrImportCancelled = errors.New("import cancelled")
+
+// ImportProgress describes how far an import has come
+type ImportProgress struct {
+	File      string
+	FileIndex int // 1-based position of File among the files imported
+	FileCount int
+	Done      int // Cards and notes of File written to the database so far
+	Total     int
+}
+
+// Fraction returns the part of the whole import that is done, from 0 to 1
+func (p ImportProgress) Fraction() float64 {
+	if p.FileCount == 0 {
+		return 0
+	}
+	done := float64(p.FileIndex - 1)
+	if p.Total > 0 {
+		done += float64(p.Done) / float64(p.Total)
+	}
+	return done / float64(p.FileCount)
+}
+
+// importRun holds the state of an import running in a transaction
+type importRun struct {
+	ctx       context.Context
+	progress  func(ImportProgress)
+	fileIndex int
+	fileCount int
+}
+
+// ImportFile loads a card file like LoadFromFile, writing to the database in
+// a single transaction. progress, which may be nil, is called as cards are
+// written. When ctx is cancelled the transaction is rolled back, the parser
+// is left as it was and ErrImportCancelled is returned. An import on another
+// goroutine runs on a Copy of the parser in use.
+func (cp *CardParser) ImportFile(ctx context.Context, db *Database, filePath string, progress func(ImportProgress)) error {
+	return cp.runImport(ctx, db, progress, func() error {
+		cp.importRun.fileIndex, cp.importRun.fileCount = 1, 1
+		return cp.LoadFromFile(filePath)
+	})
+}
+
+// ImportDirectory loads a directory tree of card files like LoadDirectory, in
+// a single transaction for all files
+func (cp *CardParser) ImportDirectory(ctx context.Context, db *Database, dir string, progress func(ImportProgress)) error {
+	return cp.runImport(ctx, db, progress, func() error {
+		return cp.LoadDirectory(dir)
+	})
+}
+
+// ReloadFile imports a loaded file again after it changed on disk. When it is
+// one file of a loaded directory, cards added afterwards still do not belong
+// to any one file, as after loading the directory.
+func (cp *CardParser) ReloadFile(ctx context.Context, db *Database, filePath string) error {
+	currentFile := cp.currentFile
+	if err := cp.ImportFile(ctx, db, filePath, nil); err != nil {
+		return err
+	}
+	if cp.currentDir != "" {
+		cp.currentFile = currentFile
+	}
+	return nil
+}
+
+func (cp *CardParser) runImport(ctx context.Context, db *Database, progress func(ImportProgress), load func() error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Keep what the parser held, to go back to when the import is cancelled
+	saved := *cp
+	cp.cards = append([]Card{}, cp.cards...)
+
+	cp.cardRepo = NewSQLiteCardRepository(tx)
+	cp.noteRepo = NewSQLiteNoteRepository(tx)
+	cp.deckRepo = NewSQLiteDeckRepository(tx)
+	cp.media = saved.media.withRepository(NewSQLiteMediaRepository(tx))
+	cp.importRun = &importRun{ctx: ctx, progress: progress}
+
+	loadErr := load()
+
+	cp.cardRepo = saved.cardRepo
+	cp.noteRepo = saved.noteRepo
+	cp.deckRepo = saved.deckRepo
+	cp.media = saved.media
+	cp.importRun = nil
+
+	if loadErr != nil {
+		if err := tx.Rollback(); err != nil {
+			return fmt.Errorf("%v; %w", loadErr, err)
+		}
+		*cp = saved
+		return loadErr
+	}
+	return tx.Commit()
+}
+
+// Copy returns a parser holding cp's cards and settings. An import that runs
+// on another goroutine runs on a copy, so that cp stays in use meanwhile and
+// is left as it was when the import fails.
+func (cp *CardParser) Copy() *CardParser {
+	c := *cp
+	c.cards = append([]Card{}, cp.cards...)
+	c.pending = nil
+	c.importRun = nil
+	return &c
+}
+
+// Replace makes cp hold what an import on a copy of it loaded. cp keeps its
+// own repositories and settings.
+func (cp *CardParser) Replace(imported *CardParser) {
+	cp.cards = imported.cards
+	cp.parseResult = imported.parseResult
+	cp.currentFile = imported.currentFile
+	cp.currentDir = imported.currentDir
+	cp.defaults = imported.defaults
+	cp.separator = imported.separator
+}
+
+// checkImport reports progress and returns ErrImportCancelled once the
+// running import is cancelled. Outside an import it does nothing.
+func (cp *CardParser) checkImport(done, total int) error {
+	run := cp.importRun
+	if run == nil {
+		return nil
+	}
+	if run.ctx.Err() != nil {
+		return ErrImportCancelled
+	}
+	if run.progress != nil && (done%importProgressInterval == 0 || done == total) {
+		run.progress(ImportProgress{
+			File:      cp.currentFile,
+			FileIndex: run.fileIndex,
+			FileCount: run.fileCount,
+			Done:      done,
+			Total:     total,
+		})
+	}
+	return nil
+}
+
+// startImportFile moves the progress of a directory import on to its next
+// file
+func (cp *CardParser) startImportFile(index, count int) {
+	if cp.importRun != nil {
+		cp.importRun.fileIndex, cp.importRun.fileCount = index, count
+	}
+}
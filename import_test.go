@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeManyCards writes a card file with a deck, tags and a cloze note,
+// followed by n cards
+func writeManyCards(t *testing.T, path, deck string, n int) {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ndeck: %s\ntags: [imported]\n---\n", deck)
+	b.WriteString("{{c1::Cloze}} note in {{c2::" + deck + "}}\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%s question %d>>answer %d #tag%d\n", deck, i, i, i%10)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// importedRows counts what an import writes to the database
+func importedRows(t *testing.T, db *Database) map[string]int {
+	t.Helper()
+	rows := make(map[string]int)
+	for _, table := range []string{"cards", "notes", "decks", "tags", "card_tags"} {
+		rows[table] = countRows(t, db, table)
+	}
+	return rows
+}
+
+func TestImportCancelRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	writeManyCards(t, filepath.Join(dir, "a.txt"), "Alpha", 250)
+	writeManyCards(t, filepath.Join(dir, "b.txt"), "Beta", 250)
+
+	tests := []struct {
+		name string
+		run  func(cp *CardParser, ctx context.Context, db *Database, progress func(ImportProgress)) error
+		// cancelAt is the progress after which the import is cancelled
+		cancelAt func(ImportProgress) bool
+	}{
+		{
+			name: "file",
+			run: func(cp *CardParser, ctx context.Context, db *Database, progress func(ImportProgress)) error {
+				return cp.ImportFile(ctx, db, filepath.Join(dir, "a.txt"), progress)
+			},
+			cancelAt: func(p ImportProgress) bool { return p.Done >= 100 },
+		},
+		{
+			name: "second file of a directory",
+			run: func(cp *CardParser, ctx context.Context, db *Database, progress func(ImportProgress)) error {
+				return cp.ImportDirectory(ctx, db, dir, progress)
+			},
+			cancelAt: func(p ImportProgress) bool { return p.FileIndex == 2 && p.Done >= 100 },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			existing := filepath.Join(t.TempDir(), "existing.txt")
+			if err := os.WriteFile(existing, []byte("Kept question>>kept answer #kept\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cp := newTestParser(db)
+			if err := cp.LoadFromFile(existing); err != nil {
+				t.Fatal(err)
+			}
+			before := importedRows(t, db)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var cancelledAt ImportProgress
+			err := test.run(cp, ctx, db, func(p ImportProgress) {
+				if ctx.Err() == nil && test.cancelAt(p) {
+					cancelledAt = p
+					cancel()
+				}
+			})
+			if !errors.Is(err, ErrImportCancelled) {
+				t.Fatalf("import returned %v, want %v", err, ErrImportCancelled)
+			}
+			if cancelledAt.Done == 0 {
+				t.Fatal("import was not cancelled partway through")
+			}
+
+			if after := importedRows(t, db); !reflect.DeepEqual(after, before) {
+				t.Errorf("rows after cancelling = %v, want %v as before the import", after, before)
+			}
+			if cards := cp.GetCards(); len(cards) != 1 || cards[0].Question != "Kept question" {
+				t.Errorf("parser holds %d cards after cancelling, want the one loaded before", len(cards))
+			}
+			if cp.currentFile != existing {
+				t.Errorf("parser has file %q after cancelling, want %q", cp.currentFile, existing)
+			}
+
+			// The database is not left locked, the same import runs to the end
+			if err := test.run(cp, context.Background(), db, nil); err != nil {
+				t.Fatalf("import after cancelling: %v", err)
+			}
+			if cards := countRows(t, db, "cards"); cards <= before["cards"] {
+				t.Errorf("import after cancelling wrote no cards")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// AddDraft saves a half-written card into the inbox. Either side may still
+// be empty. Drafts are not written to the card file until they are promoted.
+func (cp *CardParser) AddDraft(question, answer, source, promptType, tags string) error {
+	if cp.cardRepo == nil {
+		return fmt.Errorf("no database repository available")
+	}
+	if question == "" && answer == "" {
+		return fmt.Errorf("a draft needs a question or an answer")
+	}
+
+	dbCard := &DBCard{
+		Question:      question,
+		Answer:        answer,
+		SourceContext: sql.NullString{String: source, Valid: source != ""},
+		PromptType:    promptType,
+		Tags:          normalizeTags(tags),
+		Status:        CardStatusDraft,
+	}
+	if err := cp.cardRepo.Create(dbCard); err != nil {
+		return fmt.Errorf("failed to save draft: %w", err)
+	}
+	return nil
+}
+
+// GetDrafts returns the cards waiting in the inbox, oldest first
+func (cp *CardParser) GetDrafts() ([]Card, error) {
+	if cp.cardRepo == nil {
+		return nil, nil
+	}
+	dbCards, err := cp.cardRepo.GetByStatus(CardStatusDraft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drafts: %w", err)
+	}
+
+	plainDecks := cp.plainTextDecks()
+	var drafts []Card
+	for _, dbCard := range dbCards {
+		drafts = append(drafts, cardFromDB(dbCard, plainDecks))
+	}
+	return drafts, nil
+}
+
+// getDraft returns the database row of a card in the inbox
+func (cp *CardParser) getDraft(cardID int64) (*DBCard, error) {
+	if cp.cardRepo == nil {
+		return nil, fmt.Errorf("no database repository available")
+	}
+	dbCard, err := cp.cardRepo.GetByID(cardID)
+	if err != nil {
+		return nil, err
+	}
+	if dbCard.Status != CardStatusDraft {
+		return nil, fmt.Errorf("card %d is not a draft", cardID)
+	}
+	return dbCard, nil
+}
+
+// UpdateDraft saves changes to a draft, which stays in the inbox
+func (cp *CardParser) UpdateDraft(cardID int64, question, answer, source, promptType, tags string) error {
+	dbCard, err := cp.getDraft(cardID)
+	if err != nil {
+		return err
+	}
+
+	dbCard.Question = question
+	dbCard.Answer = answer
+	dbCard.SourceContext = sql.NullString{String: source, Valid: source != ""}
+	dbCard.PromptType = promptType
+	dbCard.Tags = normalizeTags(tags)
+	if err := cp.cardRepo.Update(dbCard); err != nil {
+		return fmt.Errorf("failed to save draft: %w", err)
+	}
+	return nil
+}
+
+// PromoteDraft turns a finished draft into a card that is studied. It is
+// added the way a new card is, so with write-back on it is appended to the
+// loaded card file, and cloze text becomes a note with a card per deletion.
+func (cp *CardParser) PromoteDraft(cardID int64, question, answer, source, promptType, tags string) error {
+	if _, err := cp.getDraft(cardID); err != nil {
+		return err
+	}
+
+	question = strings.TrimSpace(question)
+	answer = strings.TrimSpace(answer)
+	if question == "" {
+		return fmt.Errorf("question cannot be empty")
+	}
+
+	var err error
+	if HasCloze(question) {
+		if answer != "" {
+			return fmt.Errorf("cloze cards take their answers from the {{c1::...}} markup, leave the answer empty")
+		}
+		err = cp.AddNoteWithMetadata(NoteTypeCloze, question, source, promptType, tags)
+	} else {
+		if answer == "" {
+			return fmt.Errorf("answer cannot be empty")
+		}
+		err = cp.AddCardWithMetadata(question, answer, source, promptType, tags)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cp.DiscardDraft(cardID)
+}
+
+// DiscardDraft deletes a draft from the inbox
+func (cp *CardParser) DiscardDraft(cardID int64) error {
+	if _, err := cp.getDraft(cardID); err != nil {
+		return err
+	}
+	if err := cp.cardRepo.Delete(cardID); err != nil {
+		return fmt.Errorf("failed to discard draft: %w", err)
+	}
+	return nil
+}
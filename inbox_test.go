@@ -0,0 +1,214 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// draftTexts returns the question and answer of each draft in the inbox
+func draftTexts(t *testing.T, cp *CardParser) [][2]string {
+	t.Helper()
+	drafts, err := cp.GetDrafts()
+	if err != nil {
+		t.Fatalf("GetDrafts: %v", err)
+	}
+	var texts [][2]string
+	for _, draft := range drafts {
+		if draft.Status != CardStatusDraft {
+			t.Errorf("draft %q has status %q", draft.Question, draft.Status)
+		}
+		texts = append(texts, [2]string{draft.Question, draft.Answer})
+	}
+	return texts
+}
+
+func TestInboxDrafts(t *testing.T) {
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+
+	if err := cp.AddDraft("What is Go?", "", "", "", "#golang"); err != nil {
+		t.Fatalf("AddDraft: %v", err)
+	}
+	if err := cp.AddDraft("", "A typed pipe", "Go in Action", "", ""); err != nil {
+		t.Fatalf("AddDraft: %v", err)
+	}
+	if err := cp.AddDraft("", "", "", "", ""); err == nil {
+		t.Error("AddDraft of an empty draft succeeded, want an error")
+	}
+	if want := [][2]string{{"What is Go?", ""}, {"", "A typed pipe"}}; !reflect.DeepEqual(draftTexts(t, cp), want) {
+		t.Errorf("drafts = %q, want %q", draftTexts(t, cp), want)
+	}
+
+	drafts, _ := cp.GetDrafts()
+	if err := cp.UpdateDraft(drafts[1].ID, "What is a channel?", "A typed pipe", "Go in Action", "", "go, channels"); err != nil {
+		t.Fatalf("UpdateDraft: %v", err)
+	}
+	if want := [][2]string{{"What is Go?", ""}, {"What is a channel?", "A typed pipe"}}; !reflect.DeepEqual(draftTexts(t, cp), want) {
+		t.Errorf("drafts after updating = %q, want %q", draftTexts(t, cp), want)
+	}
+
+	// A draft does not stop the same card from being added
+	if err := cp.AddCardWithMetadata("What is a channel?", "A typed pipe", "", "", ""); err != nil {
+		t.Errorf("AddCardWithMetadata of a card like a draft: %v", err)
+	}
+
+	// Drafts are not studied or counted
+	scheduler := NewFSRSManagerWithDatabase(NewSQLiteReviewStateRepository(db), NewSQLiteDeckRepository(db))
+	cards := cp.GetCards()
+	if due := scheduler.GetDueCards(cards); len(due) != 1 || due[0].Status == CardStatusDraft {
+		t.Errorf("due cards = %+v, want only the added card", due)
+	}
+	if total, due, _ := scheduler.GetStats(cards); total != 1 || due != 1 {
+		t.Errorf("GetStats() = %d total, %d due; want 1 and 1", total, due)
+	}
+
+	if err := cp.DiscardDraft(drafts[0].ID); err != nil {
+		t.Fatalf("DiscardDraft: %v", err)
+	}
+	if want := [][2]string{{"What is a channel?", "A typed pipe"}}; !reflect.DeepEqual(draftTexts(t, cp), want) {
+		t.Errorf("drafts after discarding = %q, want %q", draftTexts(t, cp), want)
+	}
+}
+
+func TestPromoteDraft(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	if err := os.WriteFile(path, []byte("What is Go? >> A language\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	cp.SetWriteBack(true)
+
+	for _, question := range []string{"What is a channel?", "{{c1::Goroutines}} are cheap"} {
+		if err := cp.AddDraft(question, "", "", "", ""); err != nil {
+			t.Fatalf("AddDraft: %v", err)
+		}
+	}
+	drafts, _ := cp.GetDrafts()
+	channel, cloze := drafts[0].ID, drafts[1].ID
+
+	errorTests := []struct {
+		id               int64
+		question, answer string
+		err              string
+	}{
+		{channel, " ", "A typed pipe", "question cannot be empty"},
+		{channel, "What is a channel?", "", "answer cannot be empty"},
+		{cloze, "{{c1::Goroutines}} are cheap", "threads", "leave the answer empty"},
+		{cp.GetCards()[0].ID, "What is Go?", "A language", "not a draft"},
+	}
+	for _, test := range errorTests {
+		if err := cp.PromoteDraft(test.id, test.question, test.answer, "", "", ""); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("PromoteDraft(%q, %q) error = %v, want %q", test.question, test.answer, err, test.err)
+		}
+	}
+	if len(draftTexts(t, cp)) != 2 {
+		t.Fatalf("drafts = %q after failed promotions, want both left", draftTexts(t, cp))
+	}
+
+	if err := cp.PromoteDraft(channel, " What is a channel? ", "A typed pipe ", "", "", "#go"); err != nil {
+		t.Fatalf("PromoteDraft: %v", err)
+	}
+	if err := cp.PromoteDraft(cloze, "{{c1::Goroutines}} are cheap", "", "", "", ""); err != nil {
+		t.Fatalf("PromoteDraft of a cloze: %v", err)
+	}
+	if drafts := draftTexts(t, cp); len(drafts) != 0 {
+		t.Errorf("drafts = %q after promoting them, want none", drafts)
+	}
+
+	// Promoted drafts are written to the loaded file and stay when it is reloaded
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"What is a channel? >> A typed pipe #go", "{{c1::Goroutines}} are cheap"} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("card file does not hold %q:\n%s", line, data)
+		}
+	}
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile again: %v", err)
+	}
+	var active []string
+	for question, dbCard := range storedCards(t, db, path) {
+		if dbCard.Status == CardStatusActive {
+			active = append(active, question)
+		}
+	}
+	if len(active) != 3 || len(cp.GetParseResult().Removed) != 0 {
+		t.Errorf("active cards after reloading = %q, removed %+v; want all 3 cards and nothing removed", active, cp.GetParseResult().Removed)
+	}
+}
+
+func TestReloadKeepsDrafts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	if err := os.WriteFile(path, []byte("What is Go? >> A language\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := cp.AddDraft("What is Rust?", "", "", "", ""); err != nil {
+		t.Fatalf("AddDraft: %v", err)
+	}
+
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile again: %v", err)
+	}
+	if removed := cp.GetParseResult().Removed; len(removed) != 0 {
+		t.Errorf("removed = %+v, want drafts not taken for cards gone from the file", removed)
+	}
+	if want := [][2]string{{"What is Rust?", ""}}; !reflect.DeepEqual(draftTexts(t, cp), want) {
+		t.Errorf("drafts after reloading = %q, want %q", draftTexts(t, cp), want)
+	}
+}
+
+func TestHighlightImportToInbox(t *testing.T) {
+	highlight := Highlight{Title: "Meditations", Author: "Marcus Aurelius", Text: "You have power over your mind."}
+	drafts := []*HighlightDraft{
+		{Highlight: highlight, Question: "What do you have power over?"},
+		{Highlight: highlight},
+		{Highlight: highlight, UseAsContext: true, Question: "Who wrote this?"},
+	}
+
+	for _, inbox := range []bool{false, true} {
+		db := newTestDatabase(t)
+		importer := NewHighlightImporter(NewSQLiteCardRepository(db))
+		importer.SetInbox(inbox)
+		result, err := importer.Import(drafts, "My Clippings.txt", "")
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+
+		want := HighlightImportResult{Imported: 1, Skipped: 2}
+		if inbox {
+			want = HighlightImportResult{Imported: 1, Drafts: 2}
+		}
+		if !reflect.DeepEqual(*result, want) {
+			t.Errorf("Import with inbox %t = %+v, want %+v", inbox, *result, want)
+		}
+
+		saved, err := NewSQLiteCardRepository(db).GetByStatus(CardStatusDraft)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, draft := range saved {
+			if draft.SourceContext.String != "Meditations by Marcus Aurelius" {
+				t.Errorf("draft %q has source %q, want the book", draft.Question, draft.SourceContext.String)
+			}
+		}
+		if len(saved) != result.Drafts {
+			t.Errorf("%d drafts saved, want %d", len(saved), result.Drafts)
+		}
+	}
+}
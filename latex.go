@@ -0,0 +1,1137 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"fyne.io/fyne/v2/theme"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Card text can hold TeX math, $...$ inline and $$...$$ on its own. It is
+// drawn to images by a small typesetter that knows the commonly used part of
+// TeX: scripts, fractions, roots, big operators, accents, \left and \right,
+// Greek letters and symbols. Images are cached on disk per expression.
+
+// Changing how math is drawn must change this, so cached images are redrawn
+const mathRenderVersion = 1
+
+// replaceMath replaces the math in Markdown text with what image returns
+// for it. Math in code spans and escaped dollars (\$) are left alone, as is
+// math that image returns nothing for. Like Pandoc, a dollar only opens
+// inline math when it is followed by a non-space, and only closes it when
+// it follows a non-space and is not followed by a digit, so "$5 and $10"
+// stays text.
+func replaceMath(text string, image func(expr string, display bool) string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '\\' && i+1 < len(text):
+			b.WriteString(text[i : i+2])
+			i += 2
+			continue
+		case text[i] == '`':
+			n := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			fence := text[i : i+n]
+			end := strings.Index(text[i+n:], fence)
+			if end < 0 {
+				b.WriteString(fence)
+				i += n
+				continue
+			}
+			b.WriteString(text[i : i+n+end+n])
+			i += n + end + n
+			continue
+		case strings.HasPrefix(text[i:], "$$"):
+			if end := strings.Index(text[i+2:], "$$"); end >= 0 {
+				expr := text[i+2 : i+2+end]
+				if replacement := image(strings.TrimSpace(expr), true); replacement != "" && strings.TrimSpace(expr) != "" {
+					b.WriteString(replacement)
+					i += 2 + end + 2
+					continue
+				}
+			}
+			b.WriteString("$$")
+			i += 2
+			continue
+		case text[i] == '$':
+			if end := inlineMathEnd(text, i); end > 0 {
+				if replacement := image(text[i+1:end], false); replacement != "" {
+					b.WriteString(replacement)
+					i = end + 1
+					continue
+				}
+			}
+		}
+		b.WriteByte(text[i])
+		i++
+	}
+	return b.String()
+}
+
+// inlineMathEnd returns the position of the dollar that closes inline math
+// opened at start, or -1. Inline math does not span paragraphs or code
+// spans.
+func inlineMathEnd(text string, start int) int {
+	if start+1 >= len(text) || unicode.IsSpace(rune(text[start+1])) {
+		return -1
+	}
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '`':
+			return -1
+		case '\n':
+			next, _, _ := strings.Cut(text[i+1:], "\n")
+			if strings.TrimSpace(next) == "" {
+				return -1
+			}
+		case '$':
+			if unicode.IsSpace(rune(text[i-1])) || (i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9') {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// mathImage returns a PNG file showing a TeX expression in the colour fg,
+// with size as the font size in pixels. It is drawn the first time and
+// cached after that.
+func mathImage(expr string, display bool, fg color.Color, size float64) (string, error) {
+	r, g, b, a := fg.RGBA()
+	key := fmt.Sprintf("%d\x00%s\x00%t\x00%02x%02x%02x%02x\x00%g", mathRenderVersion, expr, display, r>>8, g>>8, b>>8, a>>8, size)
+	sum := sha256.Sum256([]byte(key))
+	dir := mathCacheDir()
+	path := filepath.Join(dir, hex.EncodeToString(sum[:16])+".png")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	img, err := renderMath(expr, display, fg, size)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode math image: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create math cache folder: %w", err)
+	}
+	// Written under another name first, so a half written file is never shown
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write math image: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to write math image: %w", err)
+	}
+	return path, nil
+}
+
+func mathCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "spaced-repetition", "math")
+}
+
+// renderMath draws a TeX expression. Display math is set larger, with the
+// limits of sums and the like above and below them.
+func renderMath(expr string, display bool, fg color.Color, size float64) (*image.RGBA, error) {
+	t, err := loadMathTypesetter()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	style := mathStyle{size: size, display: display}
+	node, err := parseMath(expr)
+	if err != nil {
+		return nil, err
+	}
+	box := t.layout(node, style)
+	return t.draw(box, fg, size), nil
+}
+
+// Classes of math atoms, which decide the space between them
+type mathClass int
+
+const (
+	mathOrd   mathClass = iota
+	mathOp              // Big operators and functions, such as \sum and \sin
+	mathBin             // Binary operators, such as + and \times
+	mathRel             // Relations, such as = and \leq
+	mathOpen            // Opening brackets
+	mathClose           // Closing brackets
+	mathPunct           // Commas and semicolons
+)
+
+type mathFont int
+
+const (
+	mathRoman mathFont = iota
+	mathItalic
+	mathBold
+)
+
+type mathNodeKind int
+
+const (
+	mathSymbol mathNodeKind = iota
+	mathGroup
+	mathScripts
+	mathFrac
+	mathSqrt
+	mathAccent
+	mathSpace
+	mathDelimited
+)
+
+// mathNode is a parsed piece of a TeX expression
+type mathNode struct {
+	kind     mathNodeKind
+	class    mathClass
+	text     string // Symbol text, or the accent drawn over children
+	font     mathFont
+	large    bool        // Big operator, drawn larger
+	limits   bool        // Big operator with its scripts above and below in display math
+	children []*mathNode // Group items; the numerator and denominator; radicand and index
+	base     *mathNode
+	sup, sub *mathNode
+	width    float64 // Space width in em
+	noBar    bool    // Fraction without a line, as in \binom
+	open     string  // Delimiters of \left...\right; empty for none
+	close    string
+}
+
+type mathSymbolDef struct {
+	text  string
+	class mathClass
+	font  mathFont
+}
+
+// Commands that stand for a single symbol
+var mathSymbols = map[string]mathSymbolDef{
+	"alpha": {"α", mathOrd, mathItalic}, "beta": {"β", mathOrd, mathItalic}, "gamma": {"γ", mathOrd, mathItalic},
+	"delta": {"δ", mathOrd, mathItalic}, "epsilon": {"ε", mathOrd, mathItalic}, "varepsilon": {"ε", mathOrd, mathItalic},
+	"zeta": {"ζ", mathOrd, mathItalic}, "eta": {"η", mathOrd, mathItalic}, "theta": {"θ", mathOrd, mathItalic},
+	"vartheta": {"θ", mathOrd, mathItalic}, "iota": {"ι", mathOrd, mathItalic}, "kappa": {"κ", mathOrd, mathItalic},
+	"lambda": {"λ", mathOrd, mathItalic}, "mu": {"μ", mathOrd, mathItalic}, "nu": {"ν", mathOrd, mathItalic},
+	"xi": {"ξ", mathOrd, mathItalic}, "pi": {"π", mathOrd, mathItalic}, "varpi": {"π", mathOrd, mathItalic},
+	"rho": {"ρ", mathOrd, mathItalic}, "varrho": {"ρ", mathOrd, mathItalic}, "sigma": {"σ", mathOrd, mathItalic},
+	"varsigma": {"ς", mathOrd, mathItalic}, "tau": {"τ", mathOrd, mathItalic}, "upsilon": {"υ", mathOrd, mathItalic},
+	"phi": {"φ", mathOrd, mathItalic}, "varphi": {"φ", mathOrd, mathItalic}, "chi": {"χ", mathOrd, mathItalic},
+	"psi": {"ψ", mathOrd, mathItalic}, "omega": {"ω", mathOrd, mathItalic},
+	"Gamma": {"Γ", mathOrd, mathRoman}, "Delta": {"Δ", mathOrd, mathRoman}, "Theta": {"Θ", mathOrd, mathRoman},
+	"Lambda": {"Λ", mathOrd, mathRoman}, "Xi": {"Ξ", mathOrd, mathRoman}, "Pi": {"Π", mathOrd, mathRoman},
+	"Sigma": {"Σ", mathOrd, mathRoman}, "Upsilon": {"Υ", mathOrd, mathRoman}, "Phi": {"Φ", mathOrd, mathRoman},
+	"Psi": {"Ψ", mathOrd, mathRoman}, "Omega": {"Ω", mathOrd, mathRoman},
+
+	"infty": {"∞", mathOrd, mathRoman}, "partial": {"∂", mathOrd, mathRoman}, "nabla": {"∇", mathOrd, mathRoman},
+	"ell": {"ℓ", mathOrd, mathRoman}, "hbar": {"ℏ", mathOrd, mathRoman}, "emptyset": {"∅", mathOrd, mathRoman},
+	"varnothing": {"∅", mathOrd, mathRoman}, "forall": {"∀", mathOrd, mathRoman}, "exists": {"∃", mathOrd, mathRoman},
+	"neg": {"¬", mathOrd, mathRoman}, "lnot": {"¬", mathOrd, mathRoman}, "prime": {"′", mathOrd, mathRoman},
+	"angle": {"∠", mathOrd, mathRoman}, "triangle": {"△", mathOrd, mathRoman}, "degree": {"°", mathOrd, mathRoman},
+	"ldots": {"…", mathOrd, mathRoman}, "dots": {"…", mathOrd, mathRoman}, "cdots": {"⋯", mathOrd, mathRoman},
+	"vdots": {"⋮", mathOrd, mathRoman}, "ddots": {"⋱", mathOrd, mathRoman},
+
+	"pm": {"±", mathBin, mathRoman}, "mp": {"∓", mathBin, mathRoman}, "times": {"×", mathBin, mathRoman},
+	"div": {"÷", mathBin, mathRoman}, "cdot": {"·", mathBin, mathRoman}, "ast": {"∗", mathBin, mathRoman},
+	"circ": {"∘", mathBin, mathRoman}, "bullet": {"∙", mathBin, mathRoman}, "cap": {"∩", mathBin, mathRoman},
+	"cup": {"∪", mathBin, mathRoman}, "wedge": {"∧", mathBin, mathRoman}, "land": {"∧", mathBin, mathRoman},
+	"vee": {"∨", mathBin, mathRoman}, "lor": {"∨", mathBin, mathRoman}, "setminus": {"∖", mathBin, mathRoman},
+	"oplus": {"⊕", mathBin, mathRoman}, "otimes": {"⊗", mathBin, mathRoman},
+
+	"leq": {"≤", mathRel, mathRoman}, "le": {"≤", mathRel, mathRoman}, "geq": {"≥", mathRel, mathRoman},
+	"ge": {"≥", mathRel, mathRoman}, "neq": {"≠", mathRel, mathRoman}, "ne": {"≠", mathRel, mathRoman},
+	"approx": {"≈", mathRel, mathRoman}, "equiv": {"≡", mathRel, mathRoman}, "sim": {"∼", mathRel, mathRoman},
+	"simeq": {"≃", mathRel, mathRoman}, "cong": {"≅", mathRel, mathRoman}, "propto": {"∝", mathRel, mathRoman},
+	"ll": {"≪", mathRel, mathRoman}, "gg": {"≫", mathRel, mathRoman}, "in": {"∈", mathRel, mathRoman},
+	"notin": {"∉", mathRel, mathRoman}, "ni": {"∋", mathRel, mathRoman}, "subset": {"⊂", mathRel, mathRoman},
+	"supset": {"⊃", mathRel, mathRoman}, "subseteq": {"⊆", mathRel, mathRoman}, "supseteq": {"⊇", mathRel, mathRoman},
+	"perp": {"⊥", mathRel, mathRoman}, "parallel": {"∥", mathRel, mathRoman}, "mid": {"∣", mathRel, mathRoman},
+	"to": {"→", mathRel, mathRoman}, "rightarrow": {"→", mathRel, mathRoman}, "leftarrow": {"←", mathRel, mathRoman},
+	"gets": {"←", mathRel, mathRoman}, "leftrightarrow": {"↔", mathRel, mathRoman}, "mapsto": {"↦", mathRel, mathRoman},
+	"Rightarrow": {"⇒", mathRel, mathRoman}, "implies": {"⇒", mathRel, mathRoman}, "Leftarrow": {"⇐", mathRel, mathRoman},
+	"Leftrightarrow": {"⇔", mathRel, mathRoman}, "iff": {"⇔", mathRel, mathRoman},
+
+	"langle": {"⟨", mathOpen, mathRoman}, "rangle": {"⟩", mathClose, mathRoman},
+	"lfloor": {"⌊", mathOpen, mathRoman}, "rfloor": {"⌋", mathClose, mathRoman},
+	"lceil": {"⌈", mathOpen, mathRoman}, "rceil": {"⌉", mathClose, mathRoman},
+	"{": {"{", mathOpen, mathRoman}, "}": {"}", mathClose, mathRoman}, "lbrace": {"{", mathOpen, mathRoman},
+	"rbrace": {"}", mathClose, mathRoman}, "|": {"‖", mathOrd, mathRoman}, "Vert": {"‖", mathOrd, mathRoman},
+	"vert": {"|", mathOrd, mathRoman},
+	"$":    {"$", mathOrd, mathRoman}, "%": {"%", mathOrd, mathRoman}, "&": {"&", mathOrd, mathRoman},
+	"#": {"#", mathOrd, mathRoman}, "_": {"_", mathOrd, mathRoman},
+}
+
+// Big operators, which take limits above and below them in display math
+// unless they are integrals
+var mathBigOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⊕", "bigotimes": "⊗",
+	"int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+}
+
+// Functions set upright; the ones true take limits like big operators
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "sec": false, "csc": false, "cot": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false,
+	"log": false, "ln": false, "lg": false, "exp": false, "det": false, "dim": false, "deg": false,
+	"arg": false, "ker": false, "hom": false, "gcd": true, "Pr": true,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true, "sup": true, "inf": true,
+}
+
+// Spaces in em
+var mathSpaces = map[string]float64{
+	",": 3.0 / 18, ":": 4.0 / 18, ">": 4.0 / 18, ";": 5.0 / 18, "!": -3.0 / 18, " ": 1.0 / 3,
+	"quad": 1, "qquad": 2, "thinspace": 3.0 / 18, "enspace": 0.5,
+}
+
+// Accents and the symbol drawn for them; an empty symbol draws a line
+var mathAccents = map[string]string{
+	"hat": "ˆ", "widehat": "ˆ", "tilde": "˜", "widetilde": "˜", "dot": "˙", "ddot": "¨",
+	"vec": "→", "bar": "", "overline": "",
+}
+
+// Letters of \mathbb that have a symbol of their own
+var mathDoubleStruck = map[rune]string{'R': "ℝ", 'N': "ℕ", 'Z': "ℤ", 'Q': "ℚ", 'C': "ℂ", 'P': "ℙ", 'H': "ℍ"}
+
+// mathParser reads a TeX expression. What it does not understand is shown
+// as written, but an expression that is cut off is an error.
+type mathParser struct {
+	src []rune
+	pos int
+	err error // The first error found
+}
+
+func parseMath(expr string) (*mathNode, error) {
+	p := &mathParser{src: []rune(expr)}
+	node := &mathNode{kind: mathGroup, children: p.parseList(0)}
+	if p.err != nil {
+		return nil, fmt.Errorf("failed to parse math %q: %w", expr, p.err)
+	}
+	return node, nil
+}
+
+func (p *mathParser) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Where a list of atoms ends
+const (
+	mathEndInput = iota
+	mathEndBrace
+	mathEndRight
+)
+
+func (p *mathParser) parseList(end int) []*mathNode {
+	var nodes []*mathNode
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case unicode.IsSpace(c):
+			p.pos++
+			continue
+		case c == '}':
+			p.pos++
+			if end == mathEndBrace {
+				return nodes
+			}
+			continue
+		case c == '^' || c == '_' || c == '\'':
+			p.pos++
+			nodes = p.attachScript(nodes, c)
+			continue
+		case end == mathEndRight && p.peekCommand() == "right":
+			return nodes
+		}
+		if node := p.parseAtom(); node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// attachScript puts a superscript, subscript or prime on the last atom
+func (p *mathParser) attachScript(nodes []*mathNode, c rune) []*mathNode {
+	var target *mathNode
+	if n := len(nodes); n > 0 && nodes[n-1].kind == mathScripts {
+		target = nodes[n-1]
+	} else if n > 0 {
+		target = &mathNode{kind: mathScripts, class: nodes[n-1].class, base: nodes[n-1]}
+		nodes[n-1] = target
+	} else {
+		target = &mathNode{kind: mathScripts, base: &mathNode{kind: mathGroup}}
+		nodes = append(nodes, target)
+	}
+
+	switch c {
+	case '^':
+		arg := p.parseArg()
+		if target.sup != nil && target.sup.kind == mathGroup {
+			target.sup.children = append(target.sup.children, arg)
+		} else {
+			target.sup = arg
+		}
+	case '_':
+		target.sub = p.parseArg()
+	case '\'':
+		prime := &mathNode{kind: mathSymbol, text: "′"}
+		if target.sup == nil {
+			target.sup = &mathNode{kind: mathGroup}
+		} else if target.sup.kind != mathGroup {
+			target.sup = &mathNode{kind: mathGroup, children: []*mathNode{target.sup}}
+		}
+		target.sup.children = append(target.sup.children, prime)
+	}
+	return nodes
+}
+
+// parseArg reads the argument of a command or script: a group in braces or
+// a single atom
+func (p *mathParser) parseArg() *mathNode {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return &mathNode{kind: mathGroup}
+	}
+	if p.src[p.pos] == '{' {
+		p.pos++
+		return &mathNode{kind: mathGroup, children: p.parseList(mathEndBrace)}
+	}
+	if node := p.parseAtom(); node != nil {
+		return node
+	}
+	return &mathNode{kind: mathGroup}
+}
+
+// rawArg reads an argument in braces as text, such as the text of \text
+func (p *mathParser) rawArg() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if p.src[p.pos] != '{' {
+		p.pos++
+		return string(p.src[p.pos-1])
+	}
+	depth := 0
+	start := p.pos + 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1])
+			}
+		}
+	}
+	return string(p.src[start:])
+}
+
+func (p *mathParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// peekCommand returns the name of the command at the current position
+// without reading it
+func (p *mathParser) peekCommand() string {
+	pos := p.pos
+	name := p.readCommand()
+	p.pos = pos
+	return name
+}
+
+// readCommand reads a backslash and the command name after it: letters, or
+// a single other character
+func (p *mathParser) readCommand() string {
+	if p.pos >= len(p.src) || p.src[p.pos] != '\\' {
+		return ""
+	}
+	p.pos++
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) && p.src[p.pos] < unicode.MaxASCII {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.src) {
+			p.fail(fmt.Errorf("backslash at the end"))
+			return ""
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *mathParser) parseAtom() *mathNode {
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		p.pos++
+		return &mathNode{kind: mathGroup, children: p.parseList(mathEndBrace)}
+	case c == '\\':
+		return p.parseCommand(p.readCommand())
+	case unicode.IsDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return &mathNode{kind: mathSymbol, text: string(p.src[start:p.pos])}
+	case unicode.IsLetter(c):
+		p.pos++
+		return &mathNode{kind: mathSymbol, text: string(c), font: mathItalic}
+	case c == '~':
+		p.pos++
+		return &mathNode{kind: mathSpace, width: 1.0 / 3}
+	}
+
+	p.pos++
+	node := &mathNode{kind: mathSymbol, text: string(c)}
+	switch c {
+	case '+', '*':
+		node.class = mathBin
+		if c == '*' {
+			node.text = "∗"
+		}
+	case '-':
+		node.class, node.text = mathBin, "−"
+	case '=', '<', '>', ':':
+		node.class = mathRel
+	case ',', ';':
+		node.class = mathPunct
+	case '(', '[':
+		node.class = mathOpen
+	case ')', ']', '!', '?':
+		node.class = mathClose
+	}
+	return node
+}
+
+func (p *mathParser) parseCommand(name string) *mathNode {
+	if def, ok := mathSymbols[name]; ok {
+		return &mathNode{kind: mathSymbol, text: def.text, class: def.class, font: def.font}
+	}
+	if symbol, ok := mathBigOperators[name]; ok {
+		return &mathNode{kind: mathSymbol, text: symbol, class: mathOp, large: true, limits: !strings.Contains(name, "int")}
+	}
+	if limits, ok := mathFunctions[name]; ok {
+		text := name
+		switch name {
+		case "liminf":
+			text = "lim inf"
+		case "limsup":
+			text = "lim sup"
+		}
+		return &mathNode{kind: mathSymbol, text: text, class: mathOp, limits: limits}
+	}
+	if width, ok := mathSpaces[name]; ok {
+		return &mathNode{kind: mathSpace, width: width}
+	}
+	if symbol, ok := mathAccents[name]; ok {
+		return &mathNode{kind: mathAccent, text: symbol, children: []*mathNode{p.parseArg()}}
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		return &mathNode{kind: mathFrac, children: []*mathNode{p.parseArg(), p.parseArg()}}
+	case "binom", "dbinom", "tbinom":
+		frac := &mathNode{kind: mathFrac, noBar: true, children: []*mathNode{p.parseArg(), p.parseArg()}}
+		return &mathNode{kind: mathDelimited, open: "(", close: ")", children: []*mathNode{frac}}
+	case "sqrt":
+		var index *mathNode
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			start := p.pos + 1
+			end := start
+			for end < len(p.src) && p.src[end] != ']' {
+				end++
+			}
+			if end == len(p.src) {
+				p.fail(fmt.Errorf("\\sqrt[ without ]"))
+				p.pos = end
+				return nil
+			}
+			sub := &mathParser{src: p.src[start:end]}
+			index = &mathNode{kind: mathGroup, children: sub.parseList(0)}
+			if sub.err != nil {
+				p.fail(sub.err)
+			}
+			p.pos = end + 1
+		}
+		return &mathNode{kind: mathSqrt, children: []*mathNode{p.parseArg(), index}}
+	case "text", "textrm", "mbox", "mathrm", "operatorname", "textit", "mathit", "textbf", "mathbf", "boldsymbol":
+		text := p.rawArg()
+		f := mathRoman
+		if strings.HasSuffix(name, "it") {
+			f = mathItalic
+		} else if strings.HasSuffix(name, "bf") || name == "boldsymbol" {
+			f = mathBold
+		}
+		class := mathOrd
+		if name == "operatorname" {
+			class = mathOp
+		}
+		if !strings.HasPrefix(name, "text") && name != "mbox" {
+			text = strings.Join(strings.Fields(text), "")
+		}
+		return &mathNode{kind: mathSymbol, text: text, font: f, class: class}
+	case "mathbb":
+		text := p.rawArg()
+		var b strings.Builder
+		for _, r := range text {
+			if s, ok := mathDoubleStruck[r]; ok {
+				b.WriteString(s)
+			} else if !unicode.IsSpace(r) {
+				b.WriteRune(r)
+			}
+		}
+		return &mathNode{kind: mathSymbol, text: b.String(), font: mathBold}
+	case "left":
+		open := p.readDelimiter()
+		children := p.parseList(mathEndRight)
+		if p.readCommand() != "right" {
+			p.fail(fmt.Errorf("\\left%s without \\right", open))
+		}
+		return &mathNode{kind: mathDelimited, open: open, close: p.readDelimiter(), children: children}
+	case "right":
+		p.readDelimiter()
+		return nil
+	case "\\", "displaystyle", "textstyle", "limits", "nolimits", "big", "Big", "bigg", "Bigg",
+		"bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr":
+		return nil
+	case "":
+		return nil
+	}
+	// Unknown commands are shown as written
+	return &mathNode{kind: mathSymbol, text: "\\" + name}
+}
+
+// readDelimiter reads the delimiter after \left or \right. A dot is an
+// empty delimiter.
+func (p *mathParser) readDelimiter() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if p.src[p.pos] == '\\' {
+		name := p.readCommand()
+		if def, ok := mathSymbols[name]; ok {
+			return def.text
+		}
+		return ""
+	}
+	p.pos++
+	if c := p.src[p.pos-1]; c != '.' {
+		return string(c)
+	}
+	return ""
+}
+
+// mathStyle is the size math is set at. Scripts and fractions in running
+// text are set smaller.
+type mathStyle struct {
+	size    float64 // Font size in pixels
+	display bool
+	level   int // 0 for text, 1 for scripts, 2 for scripts of scripts
+}
+
+func (s mathStyle) script() mathStyle {
+	if s.level >= 2 {
+		return s
+	}
+	scale := 0.7
+	if s.level == 1 {
+		scale = 0.5 / 0.7
+	}
+	return mathStyle{size: s.size * scale, level: s.level + 1}
+}
+
+// fraction is the style of a fraction's numerator and denominator
+func (s mathStyle) fraction() mathStyle {
+	if s.display {
+		return mathStyle{size: s.size}
+	}
+	return s.script()
+}
+
+// mathBox is laid out math: its size around the baseline and what to draw,
+// relative to the start of the baseline with y growing downwards
+type mathBox struct {
+	width, ascent, descent float64
+	glyphs                 []mathGlyph
+	shapes                 [][]mathPoint // Filled polygons
+}
+
+type mathGlyph struct {
+	text string
+	face font.Face
+	x, y float64
+}
+
+type mathPoint struct {
+	x, y float64
+}
+
+// place draws child with its baseline starting at x, y
+func (b *mathBox) place(child *mathBox, x, y float64) {
+	for _, g := range child.glyphs {
+		g.x += x
+		g.y += y
+		b.glyphs = append(b.glyphs, g)
+	}
+	for _, shape := range child.shapes {
+		moved := make([]mathPoint, len(shape))
+		for i, pt := range shape {
+			moved[i] = mathPoint{pt.x + x, pt.y + y}
+		}
+		b.shapes = append(b.shapes, moved)
+	}
+	b.width = math.Max(b.width, x+child.width)
+	b.ascent = math.Max(b.ascent, child.ascent-y)
+	b.descent = math.Max(b.descent, child.descent+y)
+}
+
+// rule draws a filled rectangle with its top left corner at x, y
+func (b *mathBox) rule(x, y, width, height float64) {
+	b.shapes = append(b.shapes, []mathPoint{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}})
+	b.width = math.Max(b.width, x+width)
+	b.ascent = math.Max(b.ascent, -y)
+	b.descent = math.Max(b.descent, y+height)
+}
+
+// stroke draws a line of the given thickness
+func (b *mathBox) stroke(from, to mathPoint, thickness float64) {
+	dx, dy := to.x-from.x, to.y-from.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	nx, ny := -dy/length*thickness/2, dx/length*thickness/2
+	b.shapes = append(b.shapes, []mathPoint{
+		{from.x + nx, from.y + ny}, {to.x + nx, to.y + ny}, {to.x - nx, to.y - ny}, {from.x - nx, from.y - ny},
+	})
+}
+
+// mathTypesetter lays out and draws math with the Go fonts, which cover
+// Greek and most mathematical symbols. Symbols they lack are taken from the
+// theme's monospace font.
+type mathTypesetter struct {
+	mu       sync.Mutex
+	fonts    map[mathFont]*opentype.Font
+	fallback *opentype.Font
+	faces    map[mathFaceKey]font.Face
+	buf      sfnt.Buffer
+}
+
+type mathFaceKey struct {
+	font     *opentype.Font
+	size     float64
+	fallback bool
+}
+
+var (
+	mathTypesetterOnce   sync.Once
+	sharedMathTypesetter *mathTypesetter
+	mathTypesetterErr    error
+)
+
+func loadMathTypesetter() (*mathTypesetter, error) {
+	mathTypesetterOnce.Do(func() {
+		t := &mathTypesetter{fonts: make(map[mathFont]*opentype.Font), faces: make(map[mathFaceKey]font.Face)}
+		for f, data := range map[mathFont][]byte{mathRoman: goregular.TTF, mathItalic: goitalic.TTF, mathBold: gobold.TTF} {
+			parsed, err := opentype.Parse(data)
+			if err != nil {
+				mathTypesetterErr = fmt.Errorf("failed to load math font: %w", err)
+				return
+			}
+			t.fonts[f] = parsed
+		}
+		fallback, err := opentype.Parse(theme.DefaultTextMonospaceFont().Content())
+		if err != nil {
+			mathTypesetterErr = fmt.Errorf("failed to load math font: %w", err)
+			return
+		}
+		t.fallback = fallback
+		sharedMathTypesetter = t
+	})
+	return sharedMathTypesetter, mathTypesetterErr
+}
+
+// face returns the face to draw text with, in the fallback font when the
+// wanted one lacks some of its characters
+func (t *mathTypesetter) face(f mathFont, size float64, text string) font.Face {
+	fnt := t.fonts[f]
+	for _, r := range text {
+		if index, err := fnt.GlyphIndex(&t.buf, r); err != nil || index == 0 {
+			fnt = t.fallback
+			break
+		}
+	}
+
+	key := mathFaceKey{font: fnt, size: size}
+	if face, ok := t.faces[key]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		face = nil
+	}
+	t.faces[key] = face
+	return face
+}
+
+func (t *mathTypesetter) text(text string, f mathFont, size float64) *mathBox {
+	face := t.face(f, size, text)
+	if face == nil || text == "" {
+		return &mathBox{}
+	}
+	bounds, advance := font.BoundString(face, text)
+	return &mathBox{
+		width:   fromFixed(advance),
+		ascent:  -fromFixed(bounds.Min.Y),
+		descent: fromFixed(bounds.Max.Y),
+		glyphs:  []mathGlyph{{text: text, face: face}},
+	}
+}
+
+func fromFixed(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
+
+func toFixed(v float64) fixed.Int26_6 {
+	return fixed.Int26_6(math.Round(v * 64))
+}
+
+// Height of the math axis, on which fraction lines and operators are
+// centred, in em
+const mathAxis = 0.25
+
+func (t *mathTypesetter) layout(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	switch n.kind {
+	case mathSymbol:
+		if !n.large {
+			return t.text(n.text, n.font, s.size)
+		}
+		// Big operators are centred on the axis
+		scale := 1.1
+		if s.display {
+			scale = 1.5
+			if strings.ContainsAny(n.text, "∫∬∭∮") {
+				scale = 2
+			}
+		}
+		symbol := t.text(n.text, n.font, s.size*scale)
+		box := &mathBox{}
+		box.place(symbol, 0, (symbol.ascent-symbol.descent)/2-mathAxis*em)
+		return box
+	case mathGroup:
+		return t.layoutList(n.children, s)
+	case mathScripts:
+		return t.layoutScripts(n, s)
+	case mathFrac:
+		return t.layoutFrac(n, s)
+	case mathSqrt:
+		return t.layoutSqrt(n, s)
+	case mathAccent:
+		return t.layoutAccent(n, s)
+	case mathSpace:
+		return &mathBox{width: n.width * em}
+	case mathDelimited:
+		return t.layoutDelimited(n, s)
+	}
+	return &mathBox{}
+}
+
+// layoutList sets atoms next to each other, with the space TeX puts
+// between their classes
+func (t *mathTypesetter) layoutList(nodes []*mathNode, s mathStyle) *mathBox {
+	box := &mathBox{}
+	prev := mathClass(-1)
+	for i, n := range nodes {
+		class := n.class
+		// A binary operator without something on both sides, as in -1
+		if class == mathBin && (prev == -1 || prev == mathBin || prev == mathOp || prev == mathRel ||
+			prev == mathOpen || prev == mathPunct || i == len(nodes)-1) {
+			class = mathOrd
+		}
+		if n.kind == mathSpace {
+			box.width += n.width * s.size
+			continue
+		}
+		// Scripts only keep the thin spaces
+		if space := mathSpacing(prev, class); prev != -1 && (s.level == 0 || space == 3.0/18) {
+			box.width += space * s.size
+		}
+		box.place(t.layout(n, s), box.width, 0)
+		prev = class
+	}
+	return box
+}
+
+// mathSpacing returns the space between two classes of atoms in em
+func mathSpacing(left, right mathClass) float64 {
+	switch {
+	case left == mathRel && right == mathRel:
+		return 0
+	case left == mathOpen || right == mathClose || right == mathPunct:
+		return 0
+	case left == mathRel || right == mathRel:
+		return 5.0 / 18
+	case left == mathBin || right == mathBin:
+		return 4.0 / 18
+	case left == mathPunct:
+		return 3.0 / 18
+	case left == mathOp && right != mathOpen, right == mathOp:
+		return 3.0 / 18
+	}
+	return 0
+}
+
+func (t *mathTypesetter) layoutScripts(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	base := t.layout(n.base, s)
+	small := s.script()
+
+	var sup, sub *mathBox
+	if n.sup != nil {
+		sup = t.layout(n.sup, small)
+	}
+	if n.sub != nil {
+		sub = t.layout(n.sub, small)
+	}
+
+	box := &mathBox{}
+	if n.base.limits && s.display {
+		gap := 0.15 * em
+		width := base.width
+		if sup != nil {
+			width = math.Max(width, sup.width)
+		}
+		if sub != nil {
+			width = math.Max(width, sub.width)
+		}
+		box.place(base, (width-base.width)/2, 0)
+		if sup != nil {
+			box.place(sup, (width-sup.width)/2, -(base.ascent + gap + sup.descent))
+		}
+		if sub != nil {
+			box.place(sub, (width-sub.width)/2, base.descent+gap+sub.ascent)
+		}
+		return box
+	}
+
+	// A single character keeps its scripts at the same height as the next,
+	// bigger things push them further out
+	baseAscent, baseDescent := 0.0, 0.0
+	if n.base.kind != mathSymbol || n.base.large {
+		baseAscent, baseDescent = base.ascent, base.descent
+	}
+	box.place(base, 0, 0)
+
+	x := base.width
+	supShift, subShift := 0.0, 0.0
+	if sup != nil {
+		supShift = math.Max(math.Max(baseAscent-0.386*small.size, 0.413*em), sup.descent+0.11*em)
+	}
+	if sub != nil {
+		subShift = math.Max(math.Max(baseDescent+0.05*small.size, 0.15*em), sub.ascent-0.35*em)
+	}
+	if sup != nil && sub != nil {
+		if gap := (supShift - sup.descent) - (sub.ascent - subShift); gap < 0.16*em {
+			subShift += 0.16*em - gap
+		}
+	}
+
+	width := 0.0
+	if sup != nil {
+		italic := 0.0
+		if n.base.font == mathItalic && n.base.kind == mathSymbol {
+			italic = 0.06 * em
+		}
+		box.place(sup, x+italic, -supShift)
+		width = italic + sup.width
+	}
+	if sub != nil {
+		box.place(sub, x, subShift)
+		width = math.Max(width, sub.width)
+	}
+	box.width = x + width + 0.05*em
+	return box
+}
+
+func (t *mathTypesetter) layoutFrac(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	inner := s.fraction()
+	num := t.layout(n.children[0], inner)
+	den := t.layout(n.children[1], inner)
+
+	rule := math.Max(1, 0.05*em)
+	gap := 0.12 * em
+	if s.display {
+		gap = 0.18 * em
+	}
+	axis := mathAxis * em
+	width := math.Max(num.width, den.width) + 0.24*em
+
+	box := &mathBox{}
+	if !n.noBar {
+		box.rule(0.06*em, -axis-rule/2, width-0.12*em, rule)
+	}
+	box.place(num, (width-num.width)/2, -(axis + rule/2 + gap + num.descent))
+	box.place(den, (width-den.width)/2, -axis+rule/2+gap+den.ascent)
+	box.width = width
+	return box
+}
+
+func (t *mathTypesetter) layoutSqrt(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	body := t.layout(n.children[0], s)
+	// Room for a short radicand, so that \sqrt{x} and \sqrt{b} match
+	body.ascent = math.Max(body.ascent, 0.72*em)
+	body.descent = math.Max(body.descent, 0.05*em)
+
+	rule := math.Max(1, 0.05*em)
+	gap := 0.12 * em
+	top := -(body.ascent + gap + rule/2)
+	bottom := body.descent + 0.05*em
+	signWidth := 0.6 * em
+
+	box := &mathBox{}
+	x := 0.0
+	if index := n.children[1]; index != nil {
+		small := s.script().script()
+		indexBox := t.layout(index, small)
+		box.place(indexBox, 0, bottom-0.5*em-indexBox.descent)
+		x = math.Max(0, indexBox.width-0.3*signWidth)
+	}
+
+	tick := mathPoint{x + 0.05*signWidth, bottom - 0.38*em}
+	knee := mathPoint{x + 0.25*signWidth, bottom - 0.46*em}
+	foot := mathPoint{x + 0.55*signWidth, bottom}
+	peak := mathPoint{x + signWidth, top}
+	box.stroke(tick, knee, rule)
+	box.stroke(knee, foot, 2*rule)
+	box.stroke(foot, peak, rule)
+
+	box.rule(peak.x-rule/4, top-rule/2, body.width+0.1*em+rule/4, rule)
+	box.place(body, peak.x+0.05*em, 0)
+	box.width = peak.x + body.width + 0.15*em
+	return box
+}
+
+func (t *mathTypesetter) layoutAccent(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	body := t.layout(n.children[0], s)
+	box := &mathBox{}
+	box.place(body, 0, 0)
+	top := math.Max(body.ascent, 0.5*em)
+
+	if n.text == "" {
+		rule := math.Max(1, 0.05*em)
+		box.rule(0, -(top + 0.1*em + rule), body.width, rule)
+		return box
+	}
+
+	size := s.size
+	if n.text == "→" {
+		size = s.script().size
+	}
+	accent := t.text(n.text, mathRoman, size)
+	// The lowest point of the accent sits just above the body
+	skew := 0.0
+	if n.children[0].font == mathItalic && n.children[0].kind == mathSymbol {
+		skew = 0.05 * em
+	}
+	box.place(accent, (body.width-accent.width)/2+skew, -(top+0.08*em)-accent.descent)
+	box.width = body.width
+	return box
+}
+
+// layoutDelimited sets brackets around their contents, grown to fit them
+func (t *mathTypesetter) layoutDelimited(n *mathNode, s mathStyle) *mathBox {
+	em := s.size
+	body := t.layoutList(n.children, s)
+	axis := mathAxis * em
+	// The delimiters reach as far above and below the axis as the contents
+	half := math.Max(body.ascent-axis, body.descent+axis) + 0.05*em
+
+	box := &mathBox{}
+	delimiter := func(text string) {
+		if text == "" {
+			box.width += 0.1 * em
+			return
+		}
+		normal := t.text(text, mathRoman, s.size)
+		size := s.size
+		if height := normal.ascent + normal.descent; height > 0 && 2*half > height {
+			size *= 2 * half / height
+		}
+		d := t.text(text, mathRoman, size)
+		box.place(d, box.width, (d.ascent-d.descent)/2-axis)
+	}
+
+	delimiter(n.open)
+	box.place(body, box.width, 0)
+	delimiter(n.close)
+	return box
+}
+
+// draw renders laid out math on a transparent image with a little room
+// around it
+func (t *mathTypesetter) draw(box *mathBox, fg color.Color, size float64) *image.RGBA {
+	pad := math.Ceil(0.1 * size)
+	width := int(math.Ceil(box.width + 2*pad))
+	height := int(math.Ceil(box.ascent + box.descent + 2*pad))
+	img := image.NewRGBA(image.Rect(0, 0, max(width, 1), max(height, 1)))
+	src := image.NewUniform(fg)
+	x, y := pad, pad+box.ascent
+
+	for _, g := range box.glyphs {
+		d := font.Drawer{Dst: img, Src: src, Face: g.face, Dot: fixed.Point26_6{X: toFixed(x + g.x), Y: toFixed(y + g.y)}}
+		d.DrawString(g.text)
+	}
+
+	if len(box.shapes) > 0 {
+		r := vector.NewRasterizer(img.Bounds().Dx(), img.Bounds().Dy())
+		for _, shape := range box.shapes {
+			r.MoveTo(float32(x+shape[0].x), float32(y+shape[0].y))
+			for _, pt := range shape[1:] {
+				r.LineTo(float32(x+pt.x), float32(y+pt.y))
+			}
+			r.ClosePath()
+		}
+		r.Draw(img, img.Bounds(), src, image.Point{})
+	}
+	return img
+}
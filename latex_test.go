@@ -0,0 +1,145 @@
+package main
+
+import "testing"
+
+func TestReplaceMath(t *testing.T) {
+	image := func(expr string, display bool) string {
+		if display {
+			return "[[" + expr + "]]"
+		}
+		return "[" + expr + "]"
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "inline math",
+			text: `The area is $\pi r^2$ here`,
+			want: `The area is [\pi r^2] here`,
+		},
+		{
+			name: "display math",
+			text: "Sum:\n$$ \\sum_i i $$",
+			want: "Sum:\n[[\\sum_i i]]",
+		},
+		{
+			name: "amounts of money",
+			text: "$5 and $10",
+			want: "$5 and $10",
+		},
+		{
+			name: "dollar followed by a space",
+			text: "$ x$ costs",
+			want: "$ x$ costs",
+		},
+		{
+			name: "escaped dollars",
+			text: `\$x$ and \$y\$`,
+			want: `\$x$ and \$y\$`,
+		},
+		{
+			name: "escaped dollar within math",
+			text: `$a\$b$`,
+			want: `[a\$b]`,
+		},
+		{
+			name: "code span",
+			text: "`$x$` and $y$",
+			want: "`$x$` and [y]",
+		},
+		{
+			name: "code span within math",
+			text: "$a `b$` c",
+			want: "$a `b$` c",
+		},
+		{
+			name: "math does not span paragraphs",
+			text: "$a\n\nb$",
+			want: "$a\n\nb$",
+		},
+		{
+			name: "math spans lines",
+			text: "$a +\nb$",
+			want: "[a +\nb]",
+		},
+		{
+			name: "empty display math",
+			text: "$$ $$",
+			want: "$$ $$",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := replaceMath(test.text, image); got != test.want {
+				t.Errorf("replaceMath(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceMathKeepsWhatCannotBeDrawn(t *testing.T) {
+	text := "$x$ and $$y$$"
+	got := replaceMath(text, func(string, bool) string { return "" })
+	if got != text {
+		t.Errorf("replaceMath(%q) = %q, want it unchanged", text, got)
+	}
+}
+
+func TestInlineMathEnd(t *testing.T) {
+	tests := []struct {
+		text  string
+		start int
+		want  int
+	}{
+		{"$x$", 0, 2},
+		{"a $x + y$ b", 2, 8},
+		{"$5 and $10", 0, -1},
+		{"$5 and $10", 7, -1},
+		{"$x $ y$", 0, 6},
+		{`$a\$b$`, 0, 5},
+		{"$a `b` c$", 0, -1},
+		{"$", 0, -1},
+	}
+
+	for _, test := range tests {
+		if got := inlineMathEnd(test.text, test.start); got != test.want {
+			t.Errorf("inlineMathEnd(%q, %d) = %d, want %d", test.text, test.start, got, test.want)
+		}
+	}
+}
+
+func TestParseMath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: `\frac{1}{2} + x^2_i`},
+		{expr: `\sqrt[3]{x}`},
+		{expr: `\left( x \right)`},
+		{expr: `\left. x \right|`},
+		{expr: `\unknown{x}`},
+		{expr: `\$ \{ \}`},
+		{expr: `x}`},
+		{expr: `\sqrt[3{x}`, wantErr: true},
+		{expr: `\sqrt[`, wantErr: true},
+		{expr: `\sqrt[\]{x}`, wantErr: true},
+		{expr: `\left( x`, wantErr: true},
+		{expr: `\left( \frac{1}{2}`, wantErr: true},
+		{expr: `x + \`, wantErr: true},
+		{expr: `\frac{1}{\`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		node, err := parseMath(test.expr)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseMath(%q) error = %v, want error %t", test.expr, err, test.wantErr)
+		}
+		if err == nil && node == nil {
+			t.Errorf("parseMath(%q) returned no node", test.expr)
+		}
+	}
+}
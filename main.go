@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -24,23 +29,40 @@ type SpacedRepetitionApp struct {
 	fsrsManager  *FSRSManager
 	statsManager *StatisticsManager
 	database     *Database
+	media        *MediaStore
+	watcher      *CardFileWatcher
+
+	lastReloadReport string   // Parse report last shown after an automatic reload
+	importing        bool     // A card import or reload is running in the background
+	pendingReloads   []string // Card files that changed while importing
+	plainText        bool     // Card text of every deck is shown as written
 
 	currentCard          *Card
+	currentImages        func(string) string // Finds the images of the current card
+	cardShownAt          time.Time           // When the current card's question was shown
 	currentIndex         int
 	dueCards             []Card
+	studyDeckID          int64            // Deck being studied with its nested decks, 0 for all cards
+	deckIDs              map[string]int64 // Deck picker option -> deck ID
 	sessionCardsReviewed int
 	initialDueCount      int
 
 	questionLabel   *widget.Label
+	questionText    *cardTextView
 	answerLabel     *widget.Label
+	answerText      *cardTextView
 	showAnswerBtn   *widget.Button
 	ratingContainer *fyne.Container
 	statsLabel      *widget.Label
+	deckSelect      *widget.Select
 
 	showingAnswer  bool
 	sessionStarted bool
 }
 
+// Deck picker option for studying the cards of every deck
+const allDecksOption = "All Decks"
+
 func NewSpacedRepetitionApp() *SpacedRepetitionApp {
 	myApp := app.New()
 	myApp.SetIcon(nil)
@@ -51,22 +73,27 @@ func NewSpacedRepetitionApp() *SpacedRepetitionApp {
 	window.CenterOnScreen()
 
 	// Initialize database (required for operation)
-	database, err := NewDatabase("./spaced_repetition.db")
+	database, err := NewDatabase(defaultDatabasePath)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize database: %v", err))
 	}
 
 	// Create repositories
 	cardRepo := NewSQLiteCardRepository(database)
+	noteRepo := NewSQLiteNoteRepository(database)
+	deckRepo := NewSQLiteDeckRepository(database)
 	reviewRepo := NewSQLiteReviewStateRepository(database)
+	reviewLogRepo := NewSQLiteReviewLogRepository(database)
 	sessionRepo := NewSQLiteSessionRepository(database)
 	dailyStatsRepo := NewSQLiteDailyStatsRepository(database)
+	media := NewMediaStore(mediaDir(defaultDatabasePath), NewSQLiteMediaRepository(database))
 
 	sra := &SpacedRepetitionApp{
 		app:                  myApp,
 		window:               window,
-		parser:               NewCardParserWithDatabase(cardRepo),
-		fsrsManager:          NewFSRSManagerWithDatabase(reviewRepo),
+		parser:               NewCardParserWithDatabase(cardRepo, noteRepo, deckRepo),
+		media:                media,
+		fsrsManager:          NewFSRSManagerWithDatabase(reviewRepo, deckRepo),
 		statsManager:         NewStatisticsManagerWithDatabase(sessionRepo, dailyStatsRepo),
 		database:             database,
 		currentIndex:         -1,
@@ -75,6 +102,20 @@ func NewSpacedRepetitionApp() *SpacedRepetitionApp {
 		sessionStarted:       false,
 	}
 
+	// Reload card files when they are edited outside the app. Events arrive
+	// on the watcher's goroutine, the reload touches the UI.
+	watcher, err := NewCardFileWatcher(func(path string) {
+		fyne.Do(func() {
+			sra.reloadCardFile(path)
+		})
+	})
+	if err != nil {
+		log.Printf("Card files will not reload automatically: %v", err)
+	}
+	sra.watcher = watcher
+	sra.parser.SetMediaStore(media)
+	sra.fsrsManager.SetReviewLog(reviewLogRepo)
+
 	// Setup menu bar
 	sra.setupMenuBar()
 
@@ -87,18 +128,68 @@ func (sra *SpacedRepetitionApp) setupMenuBar() {
 		sra.loadCards()
 	})
 
+	openFolder := fyne.NewMenuItem("Open Card Folder...", func() {
+		sra.loadCardDirectory()
+	})
+
+	importAnki := fyne.NewMenuItem("Import Anki Deck...", func() {
+		sra.importAnkiDeck()
+	})
+
+	importCSV := fyne.NewMenuItem("Import CSV/TSV...", func() {
+		sra.importCSV()
+	})
+
+	importHighlights := fyne.NewMenuItem("Import Reading Highlights...", func() {
+		sra.importHighlights()
+	})
+
 	addCard := fyne.NewMenuItem("Add New Card...", func() {
 		sra.showAddCardDialog()
 	})
 
+	inbox := fyne.NewMenuItem("Inbox...", func() {
+		sra.showInboxDialog()
+	})
+
 	manageCards := fyne.NewMenuItem("Manage Cards...", func() {
 		sra.showCardManagementDialog()
 	})
 
+	manageTags := fyne.NewMenuItem("Manage Tags...", func() {
+		sra.showTagManagementDialog()
+	})
+
+	// Cards added or edited in the app go into the card file as well
+	var writeBack *fyne.MenuItem
+	writeBack = fyne.NewMenuItem("Write Card Changes to File", func() {
+		writeBack.Checked = !writeBack.Checked
+		sra.parser.SetWriteBack(writeBack.Checked)
+		sra.window.MainMenu().Refresh()
+	})
+
+	// Card text of every deck is shown as written, not only of the decks
+	// whose front matter asks for it
+	var plainText *fyne.MenuItem
+	plainText = fyne.NewMenuItem("Show Card Text as Plain Text", func() {
+		plainText.Checked = !plainText.Checked
+		sra.plainText = plainText.Checked
+		sra.window.MainMenu().Refresh()
+		sra.refreshCardText()
+	})
+
+	exportCards := fyne.NewMenuItem("Export Cards...", func() {
+		sra.exportCards()
+	})
+
 	exportStats := fyne.NewMenuItem("Export Statistics...", func() {
 		sra.exportStatistics()
 	})
 
+	cleanMedia := fyne.NewMenuItem("Clean Up Unused Images...", func() {
+		sra.cleanMedia()
+	})
+
 	quitApp := fyne.NewMenuItem("Quit", func() {
 		sra.quit()
 	})
@@ -106,10 +197,20 @@ func (sra *SpacedRepetitionApp) setupMenuBar() {
 	// Create menu items
 	fileMenu := fyne.NewMenu("File",
 		openCards,
+		openFolder,
+		importAnki,
+		importCSV,
+		importHighlights,
 		fyne.NewMenuItemSeparator(),
 		addCard,
+		inbox,
 		manageCards,
+		manageTags,
+		writeBack,
+		plainText,
+		cleanMedia,
 		fyne.NewMenuItemSeparator(),
+		exportCards,
 		exportStats,
 		fyne.NewMenuItemSeparator(),
 		quitApp,
@@ -155,6 +256,10 @@ func (sra *SpacedRepetitionApp) setupUI() {
 		fyne.TextAlignCenter, fyne.TextStyle{})
 	sra.answerLabel.Wrapping = fyne.TextWrapWord
 
+	// Card text, rendered as Markdown unless the card's deck uses plain text
+	sra.questionText = newCardText("", true, nil)
+	sra.answerText = newCardText("", true, nil)
+
 	// Show answer button - prominent
 	sra.showAnswerBtn = widget.NewButton("👁️ Show Answer (S)", sra.showAnswer)
 	sra.showAnswerBtn.Importance = widget.HighImportance
@@ -190,13 +295,20 @@ func (sra *SpacedRepetitionApp) setupUI() {
 	sra.statsLabel = widget.NewLabelWithStyle("No cards loaded",
 		fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
 
+	// Deck picker, studying a deck leaves out the due cards of other decks
+	sra.deckSelect = widget.NewSelect([]string{allDecksOption}, sra.selectDeck)
+	sra.deckSelect.SetSelected(allDecksOption)
+	deckBar := container.NewBorder(nil, nil, widget.NewLabel("📂 Deck:"), nil, sra.deckSelect)
+
 	// Create card-like containers for better visual separation
 	statsCard := container.NewPadded(sra.statsLabel)
 
 	// Fixed-height container for question and answer to prevent jumping
 	questionCard := container.NewVBox(
 		container.NewPadded(sra.questionLabel),
+		container.NewPadded(sra.questionText),
 		container.NewPadded(sra.answerLabel),
+		container.NewPadded(sra.answerText),
 	)
 
 	// Create a fixed container where both show answer button and rating buttons will appear
@@ -206,6 +318,7 @@ func (sra *SpacedRepetitionApp) setupUI() {
 
 	// Main content with better spacing - no load button needed
 	content := container.NewVBox(
+		deckBar,
 		statsCard,
 		widget.NewSeparator(),
 		questionCard,
@@ -271,41 +384,755 @@ func (sra *SpacedRepetitionApp) loadCards() {
 
 		filePath := reader.URI().Path()
 
-		sra.parser.Clear()
-		if err := sra.parser.LoadFromFile(filePath); err != nil {
+		importer := sra.parser.Copy()
+		importer.Clear()
+		sra.runImport("Loading Cards", importer, func(ctx context.Context, progress func(ImportProgress)) error {
+			return importer.ImportFile(ctx, sra.database, filePath, progress)
+		}, func() {
+			sra.watchCardFile(filePath)
+			sra.finishLoadingCards()
+		})
+	}, sra.window)
+
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".txt"}))
+	fileDialog.Show()
+}
+
+func (sra *SpacedRepetitionApp) loadCardDirectory() {
+	folderDialog := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+		if uri == nil {
+			return
+		}
+
+		dirPath := uri.Path()
+
+		importer := sra.parser.Copy()
+		importer.Clear()
+		sra.runImport("Loading Card Folder", importer, func(ctx context.Context, progress func(ImportProgress)) error {
+			return importer.ImportDirectory(ctx, sra.database, dirPath, progress)
+		}, func() {
+			if sra.watcher != nil {
+				sra.watcher.Clear()
+				sra.lastReloadReport = ""
+				if err := sra.watcher.WatchDir(dirPath); err != nil {
+					log.Printf("Failed to watch %s: %v", dirPath, err)
+				}
+			}
+			sra.finishLoadingCards()
+		})
+	}, sra.window)
+
+	folderDialog.Show()
+}
+
+// runImport runs an import into importer, a copy of the parser, in the
+// background while a progress dialog is shown. Cancelling the dialog rolls
+// the import back. Once the import has succeeded the parser takes over the
+// imported cards and done is called on the UI goroutine.
+func (sra *SpacedRepetitionApp) runImport(title string, importer *CardParser, importCards func(ctx context.Context, progress func(ImportProgress)) error, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	status := widget.NewLabel("Reading cards...")
+	progressBar := widget.NewProgressBar()
+	progressDialog := dialog.NewCustom(title, "Cancel", container.NewVBox(status, progressBar), sra.window)
+	progressDialog.SetOnClosed(cancel)
+	progressDialog.Resize(fyne.NewSize(400, 150))
+	progressDialog.Show()
+
+	// Study shortcuts are off until the import is over, as the dialog does
+	// not catch key presses
+	sra.importing = true
+	sra.window.Canvas().SetOnTypedKey(func(*fyne.KeyEvent) {})
+
+	go func() {
+		err := importCards(ctx, func(progress ImportProgress) {
+			fyne.Do(func() {
+				status.SetText(fmt.Sprintf("Saving %s (%d of %d)", filepath.Base(progress.File), progress.Done, progress.Total))
+				progressBar.SetValue(progress.Fraction())
+			})
+		})
+
+		fyne.Do(func() {
+			sra.importing = false
+			sra.setupKeyboardShortcuts()
+			progressDialog.Hide()
+
+			switch {
+			case errors.Is(err, ErrImportCancelled):
+				dialog.ShowInformation(title, "The import was cancelled, no cards were imported.", sra.window)
+			case err != nil:
+				dialog.ShowError(err, sra.window)
+			default:
+				sra.parser.Replace(importer)
+				done()
+			}
+			sra.reloadPendingFiles()
+		})
+	}()
+}
+
+// finishLoadingCards reports on a file or directory that was just loaded and
+// starts a new session with its due cards
+func (sra *SpacedRepetitionApp) finishLoadingCards() {
+	// Show parse report if there were issues or earlier cards changed
+	result := sra.parser.GetParseResult()
+	if len(result.Removed) > 0 {
+		sra.confirmArchiveRemoved(result.Removed)
+	} else if sra.parser.HasParseErrors() || result.Updated > 0 {
+		parseReport := sra.parser.GetParseReport()
+		dialog.ShowInformation("File Parse Report", parseReport, sra.window)
+	} else if sra.parser.GetCardCount() > 0 {
+		// Show success message for clean parse
+		successMsg := fmt.Sprintf("✅ Successfully loaded %d cards from %d lines.",
+			result.ValidCards, result.TotalLines)
+		if len(result.Files) > 0 {
+			successMsg = fmt.Sprintf("✅ Successfully loaded %d cards from %d files.",
+				result.ValidCards, len(result.Files))
+		}
+		dialog.ShowInformation("Cards Loaded", successMsg, sra.window)
+	}
+
+	if err := sra.fsrsManager.LoadState(); err != nil {
+		dialog.ShowError(err, sra.window)
+		return
+	}
+
+	sra.updateDueCards()
+	sra.resetSession()
+	sra.updateStats()
+	sra.nextCard()
+}
+
+// confirmArchiveRemoved shows the parse report and offers to archive the cards
+// that were deleted from the file since it was last loaded
+func (sra *SpacedRepetitionApp) confirmArchiveRemoved(removed []Card) {
+	message := sra.parser.GetParseReport()
+	message += fmt.Sprintf("\nArchive the %d cards that are no longer in the file?\n", len(removed))
+	message += "Archived cards keep their review history but are no longer studied."
+
+	confirm := dialog.NewConfirm("File Parse Report", message, func(archive bool) {
+		if !archive {
+			return
+		}
+
+		cardIDs := make([]int64, len(removed))
+		for i, card := range removed {
+			cardIDs[i] = card.ID
+		}
+		if err := sra.parser.ArchiveCards(cardIDs); err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+
+		sra.updateDueCards()
+		sra.updateStats()
+		sra.nextCard()
+	}, sra.window)
+	confirm.SetConfirmText("Archive")
+	confirm.SetDismissText("Keep")
+	confirm.Show()
+}
+
+// watchCardFile makes the loaded file the one that is reloaded on change
+func (sra *SpacedRepetitionApp) watchCardFile(filePath string) {
+	if sra.watcher == nil {
+		return
+	}
+
+	sra.watcher.Clear()
+	sra.lastReloadReport = ""
+	if err := sra.watcher.WatchFile(filePath); err != nil {
+		log.Printf("Failed to watch %s: %v", filePath, err)
+	}
+}
+
+// reloadCardFile re-parses a watched file after it changed on disk. Like an
+// import it runs in the background on a copy of the parser. Edited cards are
+// updated in place, and the card on screen stays there so the reload does not
+// interrupt a review.
+func (sra *SpacedRepetitionApp) reloadCardFile(filePath string) {
+	// Wait for the running import or reload, it may have read the file
+	// before it changed
+	if sra.importing {
+		if !slices.Contains(sra.pendingReloads, filePath) {
+			sra.pendingReloads = append(sra.pendingReloads, filePath)
+		}
+		return
+	}
+
+	sra.importing = true
+	reloader := sra.parser.Copy()
+	go func() {
+		err := reloader.ReloadFile(context.Background(), sra.database, filePath)
+
+		fyne.Do(func() {
+			sra.importing = false
+			if err != nil {
+				log.Printf("Failed to reload %s: %v", filePath, err)
+			} else {
+				sra.parser.Replace(reloader)
+				sra.showReloadedCards()
+			}
+			sra.reloadPendingFiles()
+		})
+	}()
+}
+
+// reloadPendingFiles reloads the next card file that changed while cards
+// were being imported
+func (sra *SpacedRepetitionApp) reloadPendingFiles() {
+	if len(sra.pendingReloads) == 0 {
+		return
+	}
+	filePath := sra.pendingReloads[0]
+	sra.pendingReloads = sra.pendingReloads[1:]
+	sra.reloadCardFile(filePath)
+}
+
+// showReloadedCards brings the study view up to date after a reload
+func (sra *SpacedRepetitionApp) showReloadedCards() {
+	// Only bring up parse problems that were not already shown, so saving a
+	// file repeatedly while fixing it does not open a dialog every time
+	if sra.parser.HasParseErrors() {
+		report := sra.parser.GetParseReport()
+		if report != sra.lastReloadReport {
+			dialog.ShowInformation("File Parse Report", report, sra.window)
+		}
+		sra.lastReloadReport = report
+	} else {
+		sra.lastReloadReport = ""
+	}
+
+	allCards := sra.studyCards()
+	sra.dueCards = sra.fsrsManager.GetDueCards(allCards)
+	sra.currentIndex = -1
+	sra.updateStats()
+
+	if sra.currentCard == nil {
+		// Nothing was on screen, show newly due cards right away
+		sra.nextCard()
+		return
+	}
+
+	for i, card := range sra.dueCards {
+		if card.ID == sra.currentCard.ID {
+			sra.currentIndex = i
+			return
+		}
+	}
+	for _, card := range allCards {
+		if card.ID == sra.currentCard.ID {
+			// No longer due, but still exists; let the review finish
+			return
+		}
+	}
+
+	// The card on screen no longer exists, e.g. its cloze deletion was removed
+	sra.nextCard()
+}
+
+func (sra *SpacedRepetitionApp) importAnkiDeck() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
 			dialog.ShowError(err, sra.window)
 			return
 		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		filePath := reader.URI().Path()
+
+		historyCheck := widget.NewCheck("Keep review history (convert Anki reviews to FSRS state)", nil)
+		historyCheck.SetChecked(true)
+
+		content := container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("Import cards from %s?", filepath.Base(filePath))),
+			historyCheck,
+		)
+
+		dialog.ShowCustomConfirm("Import Anki Deck", "Import", "Cancel", content, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			importer := NewAnkiImporter(
+				NewSQLiteCardRepository(sra.database),
+				NewSQLiteNoteRepository(sra.database),
+				NewSQLiteReviewStateRepository(sra.database),
+			)
+			result, err := importer.ImportFile(filePath, AnkiImportOptions{
+				ImportReviewHistory: historyCheck.Checked,
+			})
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to import Anki deck: %w", err), sra.window)
+				return
+			}
+
+			report := fmt.Sprintf("Import Summary:\n- Notes read: %d\n- Cards created: %d\n- Duplicates skipped: %d\n- Review histories imported: %d\n",
+				result.NotesRead, result.CardsCreated, result.Duplicates, result.ReviewStates)
+			if len(result.Errors) > 0 {
+				report += fmt.Sprintf("\nImport Issues (%d):\n", len(result.Errors))
+				for i, issue := range result.Errors {
+					if i >= 10 { // Limit to first 10 errors
+						report += fmt.Sprintf("... and %d more errors\n", len(result.Errors)-10)
+						break
+					}
+					report += fmt.Sprintf("  %s\n", issue)
+				}
+			}
+			dialog.ShowInformation("Anki Deck Imported", report, sra.window)
 
-		// Show parse report if there were issues
-		if sra.parser.HasParseErrors() {
-			parseReport := sra.parser.GetParseReport()
-			dialog.ShowInformation("File Parse Report", parseReport, sra.window)
-		} else if sra.parser.GetCardCount() > 0 {
-			// Show success message for clean parse
-			result := sra.parser.GetParseResult()
-			successMsg := fmt.Sprintf("✅ Successfully loaded %d cards from %d lines.",
-				result.ValidCards, result.TotalLines)
-			dialog.ShowInformation("Cards Loaded", successMsg, sra.window)
+			sra.updateDueCards()
+			sra.resetSession()
+			sra.updateStats()
+			sra.nextCard()
+		}, sra.window)
+	}, sra.window)
+
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".apkg", ".colpkg"}))
+	fileDialog.Show()
+}
+
+func (sra *SpacedRepetitionApp) importCSV() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+		if reader == nil {
+			return
 		}
+		defer reader.Close()
+
+		filePath := reader.URI().Path()
 
-		if err := sra.fsrsManager.LoadState(); err != nil {
+		table, err := ReadCSVFile(filePath)
+		if err != nil {
 			dialog.ShowError(err, sra.window)
 			return
 		}
 
+		sra.showCSVMappingDialog(filePath, table)
+	}, sra.window)
+
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".tsv"}))
+	fileDialog.Show()
+}
+
+func (sra *SpacedRepetitionApp) showCSVMappingDialog(filePath string, table *CSVTable) {
+	const notMapped = "(not mapped)"
+	const previewRows = 5
+
+	columnOptions := func() []string {
+		options := []string{notMapped}
+		for column := 0; column < table.Columns; column++ {
+			options = append(options, table.ColumnName(column))
+		}
+		return options
+	}
+
+	// One column picker per card field, preselected from the header
+	guessed := table.GuessMapping()
+	selects := make(map[string]*widget.Select)
+	mappingForm := container.NewGridWithColumns(2)
+	for _, field := range csvFields {
+		fieldSelect := widget.NewSelect(columnOptions(), nil)
+		if column, ok := guessed[field]; ok {
+			fieldSelect.SetSelectedIndex(column + 1)
+		} else {
+			fieldSelect.SetSelectedIndex(0)
+		}
+		selects[field] = fieldSelect
+		mappingForm.Add(widget.NewLabel(field + ":"))
+		mappingForm.Add(fieldSelect)
+	}
+
+	preview := container.NewVBox()
+	updatePreview := func() {
+		preview.RemoveAll()
+
+		grid := container.NewGridWithColumns(table.Columns)
+		for column := 0; column < table.Columns; column++ {
+			grid.Add(widget.NewLabelWithStyle(table.ColumnName(column), fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		}
+
+		rows, _ := table.DataRows()
+		for i, record := range rows {
+			if i >= previewRows {
+				break
+			}
+			for column := 0; column < table.Columns; column++ {
+				cell := ""
+				if column < len(record) {
+					cell = record[column]
+				}
+				if len(cell) > 40 {
+					cell = cell[:37] + "..."
+				}
+				grid.Add(widget.NewLabel(cell))
+			}
+		}
+
+		rowCount := len(rows)
+		preview.Add(widget.NewLabel(fmt.Sprintf("Preview (first %d of %d rows):", min(rowCount, previewRows), rowCount)))
+		preview.Add(grid)
+		preview.Refresh()
+	}
+
+	headerCheck := widget.NewCheck("First row is a header", func(checked bool) {
+		table.HasHeader = checked
+		for _, fieldSelect := range selects {
+			selected := fieldSelect.SelectedIndex()
+			fieldSelect.SetOptions(columnOptions())
+			fieldSelect.SetSelectedIndex(selected)
+		}
+		updatePreview()
+	})
+	headerCheck.SetChecked(table.HasHeader)
+	updatePreview()
+
+	previewScroll := container.NewScroll(preview)
+	previewScroll.SetMinSize(fyne.NewSize(650, 200))
+
+	inboxCheck := widget.NewCheck("Save rows missing a question or answer to the Inbox", nil)
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Map the columns of %s to card fields:", filepath.Base(filePath))),
+		headerCheck,
+		mappingForm,
+		inboxCheck,
+		widget.NewSeparator(),
+		previewScroll,
+	)
+
+	dialog.ShowCustomConfirm("Import CSV/TSV", "Import", "Cancel", content, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		mapping := make(CSVColumnMapping)
+		for field, fieldSelect := range selects {
+			if index := fieldSelect.SelectedIndex(); index > 0 {
+				mapping[field] = index - 1
+			}
+		}
+
+		importer := NewCSVImporter(NewSQLiteCardRepository(sra.database))
+		importer.SetInbox(inboxCheck.Checked)
+		result, err := importer.Import(table, mapping, filePath)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to import file: %w", err), sra.window)
+			return
+		}
+
+		report := fmt.Sprintf("Import Summary:\n- Cards imported: %d\n- Duplicates skipped: %d\n", result.Imported, result.Duplicates)
+		if result.Drafts > 0 {
+			report += fmt.Sprintf("- Saved to the Inbox: %d\n", result.Drafts)
+		}
+		if len(result.Errors) > 0 {
+			report += fmt.Sprintf("\nImport Issues (%d):\n", len(result.Errors))
+			for i, issue := range result.Errors {
+				if i >= 10 { // Limit to first 10 errors
+					report += fmt.Sprintf("... and %d more errors\n", len(result.Errors)-10)
+					break
+				}
+				line := issue.Line
+				if len(line) > 50 {
+					line = line[:47] + "..."
+				}
+				report += fmt.Sprintf("  Line %d: %s - %s\n", issue.LineNum, line, issue.Reason)
+			}
+		}
+		dialog.ShowInformation("CSV Imported", report, sra.window)
+
 		sra.updateDueCards()
 		sra.resetSession()
 		sra.updateStats()
 		sra.nextCard()
 	}, sra.window)
+}
 
-	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".txt"}))
+func (sra *SpacedRepetitionApp) importHighlights() {
+	fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		filePath := reader.URI().Path()
+
+		drafts, err := ReadHighlights(filePath)
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+		if len(drafts) == 0 {
+			dialog.ShowInformation("Import Highlights", fmt.Sprintf("No highlights found in %s.", filepath.Base(filePath)), sra.window)
+			return
+		}
+
+		sra.showHighlightReviewDialog(filePath, drafts)
+	}, sra.window)
+
+	fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".txt", ".csv", ".tsv"}))
 	fileDialog.Show()
 }
 
+// showHighlightReviewDialog goes through highlights one at a time so a
+// question can be written for each. Highlights left without a question are
+// skipped, or saved to the inbox to be finished later.
+func (sra *SpacedRepetitionApp) showHighlightReviewDialog(filePath string, drafts []*HighlightDraft) {
+	const useAsAnswer = "Answer"
+	const useAsContext = "Context above the question"
+
+	index := 0
+
+	positionLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	sourceLabel := widget.NewLabel("")
+	sourceLabel.Wrapping = fyne.TextWrapWord
+	highlightLabel := widget.NewLabel("")
+	highlightLabel.Wrapping = fyne.TextWrapWord
+	noteLabel := widget.NewLabel("")
+	noteLabel.Wrapping = fyne.TextWrapWord
+
+	highlightScroll := container.NewVScroll(container.NewVBox(highlightLabel, noteLabel))
+	highlightScroll.SetMinSize(fyne.NewSize(0, 150))
+
+	questionEntry := widget.NewMultiLineEntry()
+	questionEntry.SetPlaceHolder("Write the question this highlight answers...")
+	questionEntry.Wrapping = fyne.TextWrapWord
+	questionEntry.SetMinRowsVisible(3)
+
+	answerEntry := widget.NewMultiLineEntry()
+	answerEntry.SetPlaceHolder("Enter the answer...")
+	answerEntry.Wrapping = fyne.TextWrapWord
+	answerEntry.SetMinRowsVisible(3)
+
+	useGroup := widget.NewRadioGroup([]string{useAsAnswer, useAsContext}, func(value string) {
+		if value == useAsContext {
+			answerEntry.Enable()
+		} else {
+			answerEntry.Disable()
+		}
+	})
+	useGroup.Horizontal = true
+	useGroup.Required = true
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("e.g., #reading (optional, added to every card)")
+
+	inboxCheck := widget.NewCheck("Save highlights without a question to the Inbox", nil)
+
+	readyLabel := widget.NewLabel("")
+
+	// Keep what was typed for the draft on screen
+	saveDraft := func() {
+		draft := drafts[index]
+		draft.Question = questionEntry.Text
+		draft.Answer = answerEntry.Text
+		draft.UseAsContext = useGroup.Selected == useAsContext
+	}
+
+	var previousButton, nextButton *widget.Button
+	showDraft := func() {
+		draft := drafts[index]
+		ready := 0
+		for _, d := range drafts {
+			if d.Ready() {
+				ready++
+			}
+		}
+
+		positionLabel.SetText(fmt.Sprintf("Highlight %d of %d", index+1, len(drafts)))
+		readyLabel.SetText(fmt.Sprintf("%d of %d ready to import", ready, len(drafts)))
+		source := draft.Highlight.Source()
+		if draft.Highlight.Location != "" {
+			source += " — " + draft.Highlight.Location
+		}
+		sourceLabel.SetText("📖 " + source)
+		highlightLabel.SetText(draft.Highlight.Text)
+		if draft.Highlight.Note != "" {
+			noteLabel.SetText("📝 Your note: " + draft.Highlight.Note)
+			noteLabel.Show()
+		} else {
+			noteLabel.Hide()
+		}
+		highlightScroll.ScrollToTop()
+
+		questionEntry.SetText(draft.Question)
+		answerEntry.SetText(draft.Answer)
+		if draft.UseAsContext {
+			useGroup.SetSelected(useAsContext)
+		} else {
+			useGroup.SetSelected(useAsAnswer)
+		}
+
+		if index == 0 {
+			previousButton.Disable()
+		} else {
+			previousButton.Enable()
+		}
+		if index == len(drafts)-1 {
+			nextButton.Disable()
+		} else {
+			nextButton.Enable()
+		}
+		sra.window.Canvas().Focus(questionEntry)
+	}
+
+	previousButton = widget.NewButton("← Previous", func() {
+		saveDraft()
+		index--
+		showDraft()
+	})
+	nextButton = widget.NewButton("Next →", func() {
+		saveDraft()
+		index++
+		showDraft()
+	})
+
+	importButton := widget.NewButton("Import Cards", nil)
+	importButton.Importance = widget.HighImportance
+	cancelButton := widget.NewButton("Cancel", nil)
+
+	form := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Write a question for the highlights from %s:", filepath.Base(filePath))),
+		widget.NewSeparator(),
+		positionLabel,
+		sourceLabel,
+		highlightScroll,
+		widget.NewSeparator(),
+		widget.NewLabel("Use the highlight as:"),
+		useGroup,
+		widget.NewLabel("Question:"),
+		questionEntry,
+		widget.NewLabel("Answer:"),
+		answerEntry,
+		widget.NewLabel("Tags (optional):"),
+		tagsEntry,
+		inboxCheck,
+		widget.NewSeparator(),
+		container.NewBorder(nil, nil, container.NewHBox(previousButton, nextButton), readyLabel),
+		container.NewHBox(importButton, cancelButton),
+	)
+
+	reviewDialog := dialog.NewCustomWithoutButtons("Import Highlights", container.NewVScroll(form), sra.window)
+
+	importButton.OnTapped = func() {
+		saveDraft()
+
+		importer := NewHighlightImporter(NewSQLiteCardRepository(sra.database))
+		importer.SetInbox(inboxCheck.Checked)
+		result, err := importer.Import(drafts, filePath, tagsEntry.Text)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to import highlights: %w", err), sra.window)
+			return
+		}
+		reviewDialog.Hide()
+
+		report := fmt.Sprintf("Import Summary:\n- Cards imported: %d\n- Duplicates skipped: %d\n- Highlights without a question: %d\n",
+			result.Imported, result.Duplicates, result.Skipped+result.Drafts)
+		if result.Drafts > 0 {
+			report += fmt.Sprintf("- Saved to the Inbox: %d\n", result.Drafts)
+		}
+		if len(result.Errors) > 0 {
+			report += fmt.Sprintf("\nImport Issues (%d):\n", len(result.Errors))
+			for i, issue := range result.Errors {
+				if i >= 10 { // Limit to first 10 errors
+					report += fmt.Sprintf("... and %d more errors\n", len(result.Errors)-10)
+					break
+				}
+				line := issue.Line
+				if len(line) > 50 {
+					line = line[:47] + "..."
+				}
+				report += fmt.Sprintf("  Line %d: %s - %s\n", issue.LineNum, line, issue.Reason)
+			}
+		}
+		dialog.ShowInformation("Highlights Imported", report, sra.window)
+
+		sra.updateDueCards()
+		sra.resetSession()
+		sra.updateStats()
+		sra.nextCard()
+	}
+	cancelButton.OnTapped = func() {
+		reviewDialog.Hide()
+	}
+
+	// Study shortcuts are off while writing questions
+	originalSetup := sra.setupKeyboardShortcuts
+	sra.window.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
+		if key.Name == fyne.KeyEscape {
+			reviewDialog.Hide()
+		}
+	})
+	reviewDialog.SetOnClosed(func() {
+		originalSetup()
+	})
+
+	showDraft()
+	reviewDialog.Resize(fyne.NewSize(650, 750))
+	reviewDialog.Show()
+}
+
+// studyCards returns the cards of the deck picked in the deck picker, or all
+// cards when no deck is picked
+func (sra *SpacedRepetitionApp) studyCards() []Card {
+	if sra.studyDeckID == 0 {
+		return sra.parser.GetCards()
+	}
+	return sra.parser.GetDeckCards(sra.studyDeckID)
+}
+
+// selectDeck starts studying the deck picked in the deck picker
+func (sra *SpacedRepetitionApp) selectDeck(option string) {
+	deckID := sra.deckIDs[option]
+	if deckID == sra.studyDeckID {
+		return
+	}
+	sra.studyDeckID = deckID
+
+	sra.updateDueCards()
+	sra.resetSession()
+	sra.updateStats()
+	sra.nextCard()
+}
+
+// updateDeckPicker lists the current decks in the deck picker
+func (sra *SpacedRepetitionApp) updateDeckPicker() {
+	decks, err := sra.parser.GetDecks()
+	if err != nil {
+		log.Printf("Failed to list decks: %v", err)
+		return
+	}
+
+	options := []string{allDecksOption}
+	sra.deckIDs = make(map[string]int64)
+	selected := allDecksOption
+	for _, deck := range decks {
+		options = append(options, deck.Name)
+		sra.deckIDs[deck.Name] = deck.ID
+		if deck.ID == sra.studyDeckID {
+			selected = deck.Name
+		}
+	}
+	sra.deckSelect.SetOptions(options)
+	sra.deckSelect.SetSelected(selected)
+}
+
 func (sra *SpacedRepetitionApp) updateDueCards() {
-	allCards := sra.parser.GetCards()
+	allCards := sra.studyCards()
 	sra.dueCards = sra.fsrsManager.GetDueCards(allCards)
 	sra.currentIndex = -1
 }
@@ -318,17 +1145,19 @@ func (sra *SpacedRepetitionApp) resetSession() {
 }
 
 func (sra *SpacedRepetitionApp) updateDueCardsKeepSession() {
-	allCards := sra.parser.GetCards()
+	allCards := sra.studyCards()
 	sra.dueCards = sra.fsrsManager.GetDueCards(allCards)
 	sra.currentIndex = -1
 }
 
 func (sra *SpacedRepetitionApp) updateStats() {
-	allCards := sra.parser.GetCards()
+	sra.updateDeckPicker()
+
+	allCards := sra.studyCards()
 	total, due, reviewed := sra.fsrsManager.GetStats(allCards)
 
 	if total == 0 {
-		sra.statsLabel.SetText("📚 No cards loaded - Use File → Open Cards... to get started!\n💡 Supports formats: question>>answer, question::answer, question|answer")
+		sra.statsLabel.SetText("📚 No cards loaded - Use File → Open Cards... to get started!\n💡 Supports formats: question>>answer, question::answer, question|answer, front<>back (both directions), Q:/A: blocks and {{c1::cloze}} text")
 		return
 	}
 
@@ -373,6 +1202,16 @@ func (sra *SpacedRepetitionApp) updateStats() {
 		progressEmoji, reviewed, total, progressPercent, dueEmoji, due, sessionInfo,
 		streak.CurrentStreak, todayStats.CardsReviewed)
 
+	drafts := 0
+	for _, card := range allCards {
+		if card.Status == CardStatusDraft {
+			drafts++
+		}
+	}
+	if drafts > 0 {
+		statsText += fmt.Sprintf(" | 📥 Inbox: %d", drafts)
+	}
+
 	sra.statsLabel.SetText(statsText)
 }
 
@@ -384,7 +1223,9 @@ func (sra *SpacedRepetitionApp) nextCard() {
 		} else {
 			sra.questionLabel.SetText("🎉 Congratulations!\n\nAll cards reviewed for today. Come back later for more practice!")
 		}
+		sra.questionText.SetText("", true, nil)
 		sra.answerLabel.SetText("")
+		sra.answerText.SetText("", true, nil)
 		sra.showAnswerBtn.Hide()
 		sra.ratingContainer.Hide()
 		sra.currentCard = nil
@@ -405,19 +1246,22 @@ func (sra *SpacedRepetitionApp) nextCard() {
 			dateStr := sra.currentCard.CreatedAt.Format("2006-01-02")
 			contextParts = append(contextParts, fmt.Sprintf("Added %s", dateStr))
 		}
-		contextInfo = fmt.Sprintf("[%s]\n\n", strings.Join(contextParts, " • "))
+		contextInfo = fmt.Sprintf("\n\n[%s]", strings.Join(contextParts, " • "))
 	}
 
-	// Display remaining cards and question with context
+	// Display remaining cards and context above the question
 	remaining := len(sra.dueCards)
-	cardPosition := fmt.Sprintf("📊 %d cards remaining\n\n%s%s",
-		remaining, contextInfo, sra.currentCard.Question)
+	cardPosition := fmt.Sprintf("📊 %d cards remaining%s", remaining, contextInfo)
 
 	sra.questionLabel.SetText(cardPosition)
+	sra.currentImages = sra.parser.ImagePath(*sra.currentCard)
+	sra.questionText.SetText(sra.currentCard.Question, sra.showsPlainText(*sra.currentCard), sra.currentImages)
 	sra.answerLabel.SetText("") // Clear answer text but keep label visible
+	sra.answerText.SetText("", true, nil)
 	sra.showAnswerBtn.Show()
 	sra.ratingContainer.Hide()
 	sra.showingAnswer = false
+	sra.cardShownAt = time.Now()
 }
 
 func (sra *SpacedRepetitionApp) showAnswer() {
@@ -443,17 +1287,37 @@ func (sra *SpacedRepetitionApp) showAnswer() {
 		answerHeader = fmt.Sprintf("💡 Answer (%s)", promptTypeIndicator)
 	}
 
-	answerText := fmt.Sprintf("%s:\n\n%s", answerHeader, sra.currentCard.Answer)
-	sra.answerLabel.SetText(answerText)
+	sra.answerLabel.SetText(answerHeader + ":")
+	sra.answerText.SetText(sra.currentCard.Answer, sra.showsPlainText(*sra.currentCard), sra.currentImages)
 	sra.showAnswerBtn.Hide()
 	sra.ratingContainer.Show()
 	sra.showingAnswer = true
 }
 
-func (sra *SpacedRepetitionApp) rateCard(rating fsrs.Rating) {
+// showsPlainText reports whether a card's text is shown as written instead
+// of as Markdown, as its deck or the app's setting asks
+func (sra *SpacedRepetitionApp) showsPlainText(card Card) bool {
+	return card.PlainText || sra.plainText
+}
+
+// refreshCardText shows the card being studied again after the way card
+// text is shown changed
+func (sra *SpacedRepetitionApp) refreshCardText() {
 	if sra.currentCard == nil {
 		return
 	}
+	plain := sra.showsPlainText(*sra.currentCard)
+	sra.questionText.SetText(sra.currentCard.Question, plain, sra.currentImages)
+	if sra.showingAnswer {
+		sra.answerText.SetText(sra.currentCard.Answer, plain, sra.currentImages)
+	}
+}
+
+func (sra *SpacedRepetitionApp) rateCard(rating fsrs.Rating) {
+	// Reviews wait until a running import is committed or rolled back
+	if sra.currentCard == nil || sra.importing {
+		return
+	}
 
 	// Start session if not started
 	if !sra.statsManager.HasActiveSession() {
@@ -466,7 +1330,7 @@ func (sra *SpacedRepetitionApp) rateCard(rating fsrs.Rating) {
 	isNewCard := cardState.ReviewCount == 0
 
 	// Record the review in FSRS
-	if err := sra.fsrsManager.ReviewCard(*sra.currentCard, rating); err != nil {
+	if err := sra.fsrsManager.ReviewCard(*sra.currentCard, rating, time.Since(sra.cardShownAt)); err != nil {
 		dialog.ShowError(err, sra.window)
 		return
 	}
@@ -544,18 +1408,100 @@ func (sra *SpacedRepetitionApp) exportStatistics() {
 		}
 		defer writer.Close()
 
-		filePath := writer.URI().Path()
-		if err := sra.statsManager.ExportToCSV(filePath); err != nil {
-			dialog.ShowError(fmt.Errorf("Failed to export statistics: %w", err), sra.window)
-			return
-		}
+		filePath := writer.URI().Path()
+		if err := sra.statsManager.ExportToCSV(filePath); err != nil {
+			dialog.ShowError(fmt.Errorf("Failed to export statistics: %w", err), sra.window)
+			return
+		}
+
+		dialog.ShowInformation("Export Complete",
+			fmt.Sprintf("Statistics exported to:\n%s", filePath), sra.window)
+	}, sra.window)
+
+	saveDialog.SetFileName("spaced_repetition_stats.csv")
+	saveDialog.Show()
+}
+
+// exportCards writes the cards in the database, or those of one deck or tag,
+// to a card file
+func (sra *SpacedRepetitionApp) exportCards() {
+	const allDecks = "(all decks)"
+
+	deckOptions := []string{allDecks}
+	decks, err := NewSQLiteDeckRepository(sra.database).GetAll()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to load decks: %w", err), sra.window)
+		return
+	}
+	for _, deck := range decks {
+		deckOptions = append(deckOptions, deck.Name)
+	}
+
+	deckSelect := widget.NewSelect(deckOptions, nil)
+	deckSelect.SetSelectedIndex(0)
+	tagEntry := widget.NewEntry()
+	tagEntry.SetPlaceHolder("Only cards with this tag (optional)")
+
+	form := container.NewGridWithColumns(2,
+		widget.NewLabel("Deck:"), deckSelect,
+		widget.NewLabel("Tag:"), tagEntry,
+	)
+
+	dialog.ShowCustomConfirm("Export Cards", "Export...", "Cancel", form, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		options := CardExportOptions{Tag: tagEntry.Text}
+		fileName := "cards.txt"
+		if deckSelect.Selected != allDecks {
+			options.Deck = deckSelect.Selected
+			fileName = strings.ReplaceAll(options.Deck, deckPathSeparator, "-") + ".txt"
+		}
+
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, sra.window)
+				return
+			}
+			if writer == nil {
+				return
+			}
+			writer.Close()
+
+			filePath := writer.URI().Path()
+			exporter := NewCardExporter(
+				NewSQLiteCardRepository(sra.database),
+				NewSQLiteNoteRepository(sra.database),
+				NewSQLiteDeckRepository(sra.database),
+				sra.media,
+			)
+			result, err := exporter.ExportFile(filePath, options)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to export cards: %w", err), sra.window)
+				return
+			}
+
+			report := fmt.Sprintf("Exported %d cards (%d notes) to:\n%s\n", result.Cards, result.Notes, filePath)
+			if result.Images > 0 {
+				report += fmt.Sprintf("\nCopied %d images next to it.\n", result.Images)
+			}
+			if len(result.Skipped) > 0 {
+				report += fmt.Sprintf("\nNot Exported (%d):\n", len(result.Skipped))
+				for i, issue := range result.Skipped {
+					if i >= 10 { // Limit to first 10 errors
+						report += fmt.Sprintf("... and %d more\n", len(result.Skipped)-10)
+						break
+					}
+					report += fmt.Sprintf("  %s\n", issue)
+				}
+			}
+			dialog.ShowInformation("Export Complete", report, sra.window)
+		}, sra.window)
 
-		dialog.ShowInformation("Export Complete",
-			fmt.Sprintf("Statistics exported to:\n%s", filePath), sra.window)
+		saveDialog.SetFileName(fileName)
+		saveDialog.Show()
 	}, sra.window)
-
-	saveDialog.SetFileName("spaced_repetition_stats.csv")
-	saveDialog.Show()
 }
 
 func (sra *SpacedRepetitionApp) resetStatistics() {
@@ -573,6 +1519,24 @@ func (sra *SpacedRepetitionApp) resetStatistics() {
 		}, sra.window)
 }
 
+// cleanMedia deletes the images in the media folder that no card refers to
+// any more
+func (sra *SpacedRepetitionApp) cleanMedia() {
+	dialog.ShowConfirm("Clean Up Unused Images",
+		"Delete the copies of images that no card refers to any more? Card files are not changed.",
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			removed, err := sra.parser.CleanMedia()
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("failed to clean up images: %w", err), sra.window)
+				return
+			}
+			dialog.ShowInformation("Images Cleaned Up", fmt.Sprintf("Deleted %d unused images.", len(removed)), sra.window)
+		}, sra.window)
+}
+
 func (sra *SpacedRepetitionApp) showAddCardDialog() {
 	if !sra.parser.HasFile() {
 		dialog.ShowInformation("No File Loaded",
@@ -582,12 +1546,12 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 
 	// Create input fields
 	questionEntry := widget.NewMultiLineEntry()
-	questionEntry.SetPlaceHolder("Enter your question...")
+	questionEntry.SetPlaceHolder("Enter your question, or text with {{c1::cloze}} deletions...")
 	questionEntry.Wrapping = fyne.TextWrapWord
 	questionEntry.SetMinRowsVisible(3)
 
 	answerEntry := widget.NewMultiLineEntry()
-	answerEntry.SetPlaceHolder("Enter the answer...")
+	answerEntry.SetPlaceHolder("Enter the answer (leave empty for cloze text)...")
 	answerEntry.Wrapping = fyne.TextWrapWord
 	answerEntry.SetMinRowsVisible(3)
 
@@ -597,6 +1561,8 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 	tagsEntry := widget.NewEntry()
 	tagsEntry.SetPlaceHolder("e.g., #golang #algorithms (optional)")
 
+	reverseCheck := widget.NewCheck("Also create reverse card (answer → question)", nil)
+
 	// Prompt type radio buttons
 	var promptType string = "conceptual"
 	promptTypeGroup := widget.NewRadioGroup([]string{
@@ -633,6 +1599,7 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 	addButton.Importance = widget.HighImportance
 
 	addAnotherButton := widget.NewButton("Add & Create Another", nil)
+	inboxButton := widget.NewButton("Save to Inbox", nil)
 	cancelButton := widget.NewButton("Cancel", nil)
 
 	// Create form layout
@@ -645,6 +1612,7 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 
 		widget.NewLabel("Answer:"),
 		answerEntry,
+		reverseCheck,
 
 		widget.NewSeparator(),
 		widget.NewLabel("Prompt Type:"),
@@ -659,44 +1627,68 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 		tagsEntry,
 
 		widget.NewSeparator(),
-		container.NewHBox(addButton, addAnotherButton, cancelButton),
+		container.NewHBox(addButton, addAnotherButton, inboxButton, cancelButton),
 	)
 
 	// Create custom dialog window without the framework's close button
 	addDialog := dialog.NewCustomWithoutButtons("Add Card", form, sra.window)
 
+	// Map prompt type display name to internal value
+	selectedPromptType := func() string {
+		switch promptType {
+		case "Conceptual":
+			return "conceptual"
+		case "Application":
+			return "application"
+		case "Comparison":
+			return "comparison"
+		}
+		return "factual"
+	}
+
 	// Function to add the card
 	addCard := func(closeDialog bool) {
 		question := strings.TrimSpace(questionEntry.Text)
 		answer := strings.TrimSpace(answerEntry.Text)
 
+		isCloze := HasCloze(question)
+
 		if question == "" {
 			dialog.ShowError(fmt.Errorf("question cannot be empty"), sra.window)
 			return
 		}
-		if answer == "" {
+		if isCloze && answer != "" {
+			dialog.ShowError(fmt.Errorf("cloze cards take their answers from the {{c1::...}} markup, leave the answer empty"), sra.window)
+			return
+		}
+		if !isCloze && answer == "" {
 			dialog.ShowError(fmt.Errorf("answer cannot be empty"), sra.window)
 			return
 		}
+		if reverseCheck.Checked && isCloze {
+			dialog.ShowError(fmt.Errorf("reverse cards cannot be created from cloze text"), sra.window)
+			return
+		}
+		if reverseCheck.Checked && (strings.Contains(question, reverseSeparator) || strings.Contains(answer, reverseSeparator)) {
+			dialog.ShowError(fmt.Errorf("reverse cards cannot contain %q", reverseSeparator), sra.window)
+			return
+		}
 
 		source := strings.TrimSpace(sourceEntry.Text)
 		tags := strings.TrimSpace(tagsEntry.Text)
-
-		// Map prompt type display name to internal value
-		promptTypeValue := "factual"
-		switch promptType {
-		case "Factual Recall":
-			promptTypeValue = "factual"
-		case "Conceptual":
-			promptTypeValue = "conceptual"
-		case "Application":
-			promptTypeValue = "application"
-		case "Comparison":
-			promptTypeValue = "comparison"
+		promptTypeValue := selectedPromptType()
+
+		// Add the card with new fields; cloze text expands into one card per
+		// deletion and reverse cards into one card per direction
+		var err error
+		if isCloze {
+			err = sra.parser.AddNoteWithMetadata(NoteTypeCloze, question, source, promptTypeValue, tags)
+		} else if reverseCheck.Checked {
+			err = sra.parser.AddNoteWithMetadata(NoteTypeReverse, ReverseNoteContent(question, answer), source, promptTypeValue, tags)
+		} else {
+			err = sra.parser.AddCardWithMetadata(question, answer, source, promptTypeValue, tags)
 		}
-
-		// Add the card with new fields
-		if err := sra.parser.AddCardWithMetadata(question, answer, source, promptTypeValue, tags); err != nil {
+		if err != nil {
 			dialog.ShowError(fmt.Errorf("failed to add card: %w", err), sra.window)
 			return
 		}
@@ -727,6 +1719,17 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 	addAnotherButton.OnTapped = func() {
 		addCard(false)
 	}
+	inboxButton.OnTapped = func() {
+		// A draft may be half-written, it is finished later from the inbox
+		err := sra.parser.AddDraft(strings.TrimSpace(questionEntry.Text), strings.TrimSpace(answerEntry.Text),
+			strings.TrimSpace(sourceEntry.Text), selectedPromptType(), strings.TrimSpace(tagsEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to save draft: %w", err), sra.window)
+			return
+		}
+		sra.updateStats()
+		addDialog.Hide()
+	}
 	cancelButton.OnTapped = func() {
 		addDialog.Hide()
 	}
@@ -763,6 +1766,320 @@ func (sra *SpacedRepetitionApp) showAddCardDialog() {
 	sra.window.Canvas().Focus(questionEntry)
 }
 
+// showInboxDialog lists the drafts in the inbox. Each can be finished and
+// saved, promoted to a card that is studied, or discarded.
+func (sra *SpacedRepetitionApp) showInboxDialog() {
+	drafts, err := sra.parser.GetDrafts()
+	if err != nil {
+		dialog.ShowError(err, sra.window)
+		return
+	}
+	if len(drafts) == 0 {
+		dialog.ShowInformation("Inbox",
+			"The inbox is empty.\n\nUse Save to Inbox when adding a card to finish it later, or send incomplete cards here when importing.", sra.window)
+		return
+	}
+
+	selected := -1
+
+	headerLabel := widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+
+	draftList := widget.NewList(
+		func() int {
+			return len(drafts)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			draft := drafts[id]
+			summary := strings.TrimSpace(draft.Question)
+			if summary == "" {
+				summary = "(no question yet) " + strings.TrimSpace(draft.Answer)
+			}
+			summary, _, _ = strings.Cut(summary, "\n")
+			item.(*widget.Label).SetText(summary)
+		},
+	)
+
+	questionEntry := widget.NewMultiLineEntry()
+	questionEntry.SetPlaceHolder("Enter your question, or text with {{c1::cloze}} deletions...")
+	questionEntry.Wrapping = fyne.TextWrapWord
+	questionEntry.SetMinRowsVisible(4)
+
+	answerEntry := widget.NewMultiLineEntry()
+	answerEntry.SetPlaceHolder("Enter the answer (leave empty for cloze text)...")
+	answerEntry.Wrapping = fyne.TextWrapWord
+	answerEntry.SetMinRowsVisible(4)
+
+	sourceEntry := widget.NewEntry()
+	sourceEntry.SetPlaceHolder("Book, article, or project (optional)")
+
+	tagsEntry := widget.NewEntry()
+	tagsEntry.SetPlaceHolder("e.g., #golang #algorithms (optional)")
+
+	saveButton := widget.NewButton("Save Draft", nil)
+	promoteButton := widget.NewButton("Promote to Card", nil)
+	promoteButton.Importance = widget.HighImportance
+	discardButton := widget.NewButton("Discard", nil)
+	discardButton.Importance = widget.DangerImportance
+
+	editor := container.NewVBox(
+		widget.NewLabel("Question:"),
+		questionEntry,
+		widget.NewLabel("Answer:"),
+		answerEntry,
+		widget.NewLabel("Source (optional):"),
+		sourceEntry,
+		widget.NewLabel("Tags (optional):"),
+		tagsEntry,
+		widget.NewSeparator(),
+		container.NewHBox(promoteButton, saveButton, discardButton),
+	)
+
+	showDraft := func() {
+		headerLabel.SetText(fmt.Sprintf("Inbox - %d drafts", len(drafts)))
+		if selected < 0 || selected >= len(drafts) {
+			questionEntry.SetText("")
+			answerEntry.SetText("")
+			sourceEntry.SetText("")
+			tagsEntry.SetText("")
+			editor.Hide()
+			return
+		}
+		draft := drafts[selected]
+		questionEntry.SetText(draft.Question)
+		answerEntry.SetText(draft.Answer)
+		sourceEntry.SetText(draft.SourceContext)
+		tagsEntry.SetText(draft.Tags)
+		editor.Show()
+	}
+
+	// Reload the drafts after one was changed, keeping the selection where it was
+	reload := func() {
+		var err error
+		if drafts, err = sra.parser.GetDrafts(); err != nil {
+			dialog.ShowError(err, sra.window)
+		}
+		if selected >= len(drafts) {
+			selected = len(drafts) - 1
+		}
+		draftList.UnselectAll()
+		draftList.Refresh()
+		if selected >= 0 {
+			draftList.Select(selected)
+		}
+		showDraft()
+	}
+
+	draftList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		showDraft()
+	}
+
+	saveButton.OnTapped = func() {
+		draft := drafts[selected]
+		err := sra.parser.UpdateDraft(draft.ID, strings.TrimSpace(questionEntry.Text), strings.TrimSpace(answerEntry.Text),
+			strings.TrimSpace(sourceEntry.Text), draft.PromptType, strings.TrimSpace(tagsEntry.Text))
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+			return
+		}
+		reload()
+	}
+	promoteButton.OnTapped = func() {
+		draft := drafts[selected]
+		err := sra.parser.PromoteDraft(draft.ID, questionEntry.Text, answerEntry.Text,
+			strings.TrimSpace(sourceEntry.Text), draft.PromptType, strings.TrimSpace(tagsEntry.Text))
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to promote draft: %w", err), sra.window)
+			return
+		}
+		sra.updateDueCards()
+		sra.updateStats()
+		reload()
+	}
+	discardButton.OnTapped = func() {
+		draft := drafts[selected]
+		dialog.ShowConfirm("Discard Draft", "Discard this draft? It cannot be recovered.", func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := sra.parser.DiscardDraft(draft.ID); err != nil {
+				dialog.ShowError(err, sra.window)
+				return
+			}
+			sra.updateStats()
+			reload()
+		}, sra.window)
+	}
+
+	split := container.NewHSplit(draftList, container.NewVScroll(editor))
+	split.Offset = 0.35
+
+	content := container.NewBorder(
+		container.NewVBox(headerLabel, widget.NewSeparator()),
+		nil, nil, nil,
+		split,
+	)
+
+	showDraft()
+	draftList.Select(0)
+
+	inboxDialog := dialog.NewCustom("Inbox", "Close", content, sra.window)
+	inboxDialog.Resize(fyne.NewSize(900, 600))
+	inboxDialog.Show()
+}
+
+// showTagManagementDialog lists the tags, nested tags under their parents,
+// and renames, merges or deletes the selected one
+func (sra *SpacedRepetitionApp) showTagManagementDialog() {
+	tagManager := NewTagManager(NewSQLiteTagRepository(sra.database), NewSQLiteCardRepository(sra.database))
+	tagManager.SetParser(sra.parser)
+
+	tags, err := tagManager.GetTags()
+	if err != nil {
+		dialog.ShowError(err, sra.window)
+		return
+	}
+	if len(tags) == 0 {
+		dialog.ShowInformation("Manage Tags",
+			"No cards have tags yet.\n\nTag a card by writing #golang after its answer. Tags nest with \"::\", as in #lang::go::generics.", sra.window)
+		return
+	}
+
+	selected := -1
+
+	tagList := widget.NewList(
+		func() int {
+			return len(tags)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			tag := tags[id]
+			// Nested tags are indented under their parent and show their last level
+			leaf := strings.TrimPrefix(tag.Name[len(parentTagName(tag.Name)):], tagPathSeparator)
+			item.(*widget.Label).SetText(fmt.Sprintf("%s#%s (%d cards)", strings.Repeat("    ", tag.Depth), leaf, tag.Cards))
+		},
+	)
+
+	statusLabel := widget.NewLabel("")
+	statusLabel.Wrapping = fyne.TextWrapWord
+
+	renameButton := widget.NewButton("Rename...", nil)
+	mergeButton := widget.NewButton("Merge Into...", nil)
+	deleteButton := widget.NewButton("Delete", nil)
+	deleteButton.Importance = widget.DangerImportance
+
+	updateButtons := func() {
+		for _, button := range []*widget.Button{renameButton, mergeButton, deleteButton} {
+			if selected < 0 {
+				button.Disable()
+			} else {
+				button.Enable()
+			}
+		}
+	}
+
+	// Show the outcome of a change and list the tags as they are now
+	finish := func(done string, changed int, err error) {
+		if err != nil {
+			dialog.ShowError(err, sra.window)
+		} else {
+			statusLabel.SetText(fmt.Sprintf("%s, %d cards changed.", done, changed))
+		}
+		if tags, err = tagManager.GetTags(); err != nil {
+			dialog.ShowError(err, sra.window)
+		}
+		selected = -1
+		tagList.UnselectAll()
+		tagList.Refresh()
+		updateButtons()
+	}
+
+	tagList.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		updateButtons()
+	}
+
+	renameButton.OnTapped = func() {
+		tag := tags[selected]
+		nameEntry := widget.NewEntry()
+		nameEntry.SetText(tag.Name)
+		dialog.ShowForm("Rename Tag", "Rename", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("New name", nameEntry)},
+			func(confirmed bool) {
+				if !confirmed {
+					return
+				}
+				changed, err := tagManager.RenameTag(tag.Name, nameEntry.Text)
+				finish(fmt.Sprintf("Renamed #%s", tag.Name), changed, err)
+			}, sra.window)
+	}
+
+	mergeButton.OnTapped = func() {
+		tag := tags[selected]
+		var targets []string
+		for _, other := range tags {
+			if !tagWithin(other.Name, tag.Name) {
+				targets = append(targets, other.Name)
+			}
+		}
+		if len(targets) == 0 {
+			dialog.ShowInformation("Merge Tag", "There is no other tag to merge into.", sra.window)
+			return
+		}
+		targetSelect := widget.NewSelect(targets, nil)
+		dialog.ShowForm("Merge Tag", "Merge", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem(fmt.Sprintf("Merge #%s into", tag.Name), targetSelect)},
+			func(confirmed bool) {
+				if !confirmed || targetSelect.Selected == "" {
+					return
+				}
+				changed, err := tagManager.MergeTags(tag.Name, targetSelect.Selected)
+				finish(fmt.Sprintf("Merged #%s into #%s", tag.Name, targetSelect.Selected), changed, err)
+			}, sra.window)
+	}
+
+	deleteButton.OnTapped = func() {
+		tag := tags[selected]
+		message := fmt.Sprintf("Remove the tag #%s and the tags nested in it from %d cards?", tag.Name, tag.Cards)
+		dialog.ShowConfirm("Delete Tag", message, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			changed, err := tagManager.DeleteTag(tag.Name)
+			finish(fmt.Sprintf("Deleted #%s", tag.Name), changed, err)
+		}, sra.window)
+	}
+
+	updateButtons()
+
+	hint := widget.NewLabel("Tags are changed on the cards and in the card files they came from.")
+	if !tagManager.WritesBack() {
+		hint.SetText("Write-back is off, so tags are only changed in the database. Loading a card file again brings back the tags written in it.")
+		hint.Importance = widget.WarningImportance
+	}
+	hint.Wrapping = fyne.TextWrapWord
+
+	content := container.NewBorder(
+		nil,
+		container.NewVBox(widget.NewSeparator(), statusLabel, container.NewHBox(renameButton, mergeButton, deleteButton), hint),
+		nil, nil,
+		tagList,
+	)
+
+	tagsDialog := dialog.NewCustom("Manage Tags", "Close", content, sra.window)
+	tagsDialog.Resize(fyne.NewSize(600, 550))
+	tagsDialog.Show()
+}
+
 func (sra *SpacedRepetitionApp) showCardManagementDialog() {
 	// Get all cards from database
 	var allCards []Card
@@ -773,7 +2090,13 @@ func (sra *SpacedRepetitionApp) showCardManagementDialog() {
 
 	refreshCards := func() {
 		oldCount := len(allCards)
-		allCards = sra.parser.GetCards()
+		// Drafts are managed in the inbox
+		allCards = nil
+		for _, card := range sra.parser.GetCards() {
+			if card.Status != CardStatusDraft {
+				allCards = append(allCards, card)
+			}
+		}
 		filteredCards = allCards
 		fmt.Printf("DEBUG: refreshCards - old count: %d, new count: %d\n", oldCount, len(allCards))
 	}
@@ -875,14 +2198,6 @@ func (sra *SpacedRepetitionApp) showCardManagementDialog() {
 }
 
 func (sra *SpacedRepetitionApp) createCardWidget(card Card, refreshCallback func()) fyne.CanvasObject {
-	// Create larger, more prominent labels
-	questionLabel := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
-	questionLabel.Wrapping = fyne.TextWrapWord
-
-	answerLabel := widget.NewLabel("")
-	answerLabel.TextStyle.Italic = true
-	answerLabel.Wrapping = fyne.TextWrapWord
-
 	// Show more text with better formatting - increase character limits
 	question := card.Question
 	if len(question) > 200 {
@@ -893,12 +2208,24 @@ func (sra *SpacedRepetitionApp) createCardWidget(card Card, refreshCallback func
 		answer = answer[:197] + "..."
 	}
 
-	questionLabel.SetText(fmt.Sprintf("📝 %s", question))
-	answerLabel.SetText(fmt.Sprintf("💡 %s", answer))
+	// Card text is rendered like in the study view, next to its icon
+	questionIcon := widget.NewLabel("📝")
+	if card.Status == CardStatusArchived {
+		questionIcon.SetText("🗄️ (archived)")
+	}
+	images := sra.parser.ImagePath(card)
+	plain := sra.showsPlainText(card)
+	questionRow := container.NewBorder(nil, nil, questionIcon, nil, newCardText(question, plain, images))
+	answerRow := container.NewBorder(nil, nil, widget.NewLabel("💡"), nil, newCardText(answer, plain, images))
 
 	// Create more prominent buttons
 	editBtn := widget.NewButtonWithIcon("✏️ Edit", nil, func() {
-		sra.showEditCardDialog(card.ID, card.Question, card.Answer)
+		if card.NoteID != 0 {
+			// Cards generated from a note are edited through the note
+			sra.showEditNoteDialog(card.NoteID)
+			return
+		}
+		sra.showEditCardDialog(card)
 	})
 	editBtn.Importance = widget.MediumImportance
 
@@ -920,8 +2247,8 @@ func (sra *SpacedRepetitionApp) createCardWidget(card Card, refreshCallback func
 
 	// Create a padded container for better spacing
 	cardWidget := container.NewVBox(
-		container.NewPadded(questionLabel),
-		container.NewPadded(answerLabel),
+		container.NewPadded(questionRow),
+		container.NewPadded(answerRow),
 		container.NewPadded(buttonContainer),
 		widget.NewSeparator(),
 	)
@@ -929,7 +2256,9 @@ func (sra *SpacedRepetitionApp) createCardWidget(card Card, refreshCallback func
 	return cardWidget
 }
 
-func (sra *SpacedRepetitionApp) showEditCardDialog(cardID int64, currentQuestion, currentAnswer string) {
+func (sra *SpacedRepetitionApp) showEditCardDialog(card Card) {
+	cardID, currentQuestion, currentAnswer := card.ID, card.Question, card.Answer
+
 	// Create multiline entry widgets for question and answer
 	questionEntry := widget.NewMultiLineEntry()
 	questionEntry.SetText(currentQuestion)
@@ -943,12 +2272,28 @@ func (sra *SpacedRepetitionApp) showEditCardDialog(cardID int64, currentQuestion
 	questionCount := widget.NewLabel(fmt.Sprintf("Characters: %d", len(currentQuestion)))
 	answerCount := widget.NewLabel(fmt.Sprintf("Characters: %d", len(currentAnswer)))
 
-	// Update character counts on text change
+	// Preview of the card as it is shown when studying
+	images := sra.parser.ImagePath(card)
+	plain := sra.showsPlainText(card)
+	questionPreview := newCardText(currentQuestion, plain, images)
+	answerPreview := newCardText(currentAnswer, plain, images)
+	previewTitle := "Preview:"
+	if card.PlainText {
+		previewTitle = "Preview (plain text, set by the deck):"
+	} else if plain {
+		previewTitle = "Preview (plain text):"
+	}
+	preview := container.NewVScroll(container.NewVBox(questionPreview, widget.NewSeparator(), answerPreview))
+	preview.SetMinSize(fyne.NewSize(0, 120))
+
+	// Update character counts and preview on text change
 	questionEntry.OnChanged = func(text string) {
 		questionCount.SetText(fmt.Sprintf("Characters: %d", len(text)))
+		questionPreview.SetText(text, plain, images)
 	}
 	answerEntry.OnChanged = func(text string) {
 		answerCount.SetText(fmt.Sprintf("Characters: %d", len(text)))
+		answerPreview.SetText(text, plain, images)
 	}
 
 	// Create buttons
@@ -972,6 +2317,10 @@ func (sra *SpacedRepetitionApp) showEditCardDialog(cardID int64, currentQuestion
 		answerEntry,
 		answerCount,
 
+		widget.NewSeparator(),
+		widget.NewLabel(previewTitle),
+		preview,
+
 		widget.NewSeparator(),
 		container.NewHBox(saveButton, cancelButton),
 	)
@@ -1053,13 +2402,132 @@ func (sra *SpacedRepetitionApp) showEditCardDialog(cardID int64, currentQuestion
 		originalSetup()
 	})
 
-	editDialog.Resize(fyne.NewSize(500, 600))
+	editDialog.Resize(fyne.NewSize(500, 750))
 	editDialog.Show()
 
 	// Focus on question field
 	sra.window.Canvas().Focus(questionEntry)
 }
 
+func (sra *SpacedRepetitionApp) showEditNoteDialog(noteID int64) {
+	note, err := sra.parser.GetNote(noteID)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to load note: %w", err), sra.window)
+		return
+	}
+
+	var title string
+	var fields *fyne.Container
+	var firstEntry *widget.Entry
+	var noteContent func() string
+
+	switch note.NoteType {
+	case NoteTypeReverse:
+		front, back, _ := SplitReverseNote(note.Content)
+
+		frontEntry := widget.NewMultiLineEntry()
+		frontEntry.SetText(front)
+		frontEntry.Wrapping = fyne.TextWrapWord
+
+		backEntry := widget.NewMultiLineEntry()
+		backEntry.SetText(back)
+		backEntry.Wrapping = fyne.TextWrapWord
+
+		title = "Edit Reverse Card"
+		fields = container.NewVBox(
+			widget.NewLabel("Front:"),
+			frontEntry,
+			widget.NewLabel("Back:"),
+			backEntry,
+		)
+		firstEntry = frontEntry
+		noteContent = func() string {
+			return ReverseNoteContent(strings.TrimSpace(frontEntry.Text), strings.TrimSpace(backEntry.Text))
+		}
+
+	default:
+		contentEntry := widget.NewMultiLineEntry()
+		contentEntry.SetText(note.Content)
+		contentEntry.Wrapping = fyne.TextWrapWord
+		contentEntry.SetMinRowsVisible(6)
+
+		siblingCount := widget.NewLabel(fmt.Sprintf("Cards generated: %d", len(ClozeNumbers(note.Content))))
+		contentEntry.OnChanged = func(text string) {
+			siblingCount.SetText(fmt.Sprintf("Cards generated: %d", len(ClozeNumbers(text))))
+		}
+
+		title = "Edit Cloze Note"
+		fields = container.NewVBox(
+			widget.NewLabel("Text (mark deletions with {{c1::term}}):"),
+			contentEntry,
+			siblingCount,
+		)
+		firstEntry = contentEntry
+		noteContent = func() string {
+			return strings.TrimSpace(contentEntry.Text)
+		}
+	}
+
+	saveButton := widget.NewButton("Save Changes", nil)
+	saveButton.Importance = widget.HighImportance
+
+	cancelButton := widget.NewButton("Cancel", nil)
+
+	form := container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewSeparator(),
+
+		fields,
+
+		widget.NewSeparator(),
+		container.NewHBox(saveButton, cancelButton),
+	)
+
+	editDialog := dialog.NewCustomWithoutButtons("Edit Note", form, sra.window)
+
+	saveButton.OnTapped = func() {
+		// Editing the note updates every sibling card generated from it
+		removed, err := sra.parser.UpdateNote(noteID, noteContent())
+		for _, cardID := range removed {
+			if err := sra.fsrsManager.DeleteCardState(cardID); err != nil {
+				fmt.Printf("Warning: Failed to delete review state for card %d: %v\n", cardID, err)
+			}
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("failed to update note: %w", err), sra.window)
+			return
+		}
+
+		// Refresh the UI
+		sra.updateDueCards()
+		sra.updateStats()
+
+		dialog.ShowInformation("Note Updated", "All cards of this note have been updated.", sra.window)
+		editDialog.Hide()
+	}
+	cancelButton.OnTapped = func() {
+		editDialog.Hide()
+	}
+
+	// Set up keyboard shortcuts
+	originalSetup := sra.setupKeyboardShortcuts
+	sra.window.Canvas().SetOnTypedKey(func(key *fyne.KeyEvent) {
+		if key.Name == fyne.KeyEscape {
+			editDialog.Hide()
+		}
+	})
+
+	// Restore original key handler when dialog closes
+	editDialog.SetOnClosed(func() {
+		originalSetup()
+	})
+
+	editDialog.Resize(fyne.NewSize(500, 450))
+	editDialog.Show()
+
+	sra.window.Canvas().Focus(firstEntry)
+}
+
 func (sra *SpacedRepetitionApp) confirmDeleteCard(cardID int64, question string) {
 	// Truncate question for display in confirmation
 	displayQuestion := question
@@ -1141,6 +2609,9 @@ func (sra *SpacedRepetitionApp) quit() {
 		fmt.Println("Ending session from quit method")
 		sra.statsManager.EndSession()
 	}
+	if sra.watcher != nil {
+		sra.watcher.Close()
+	}
 	// Close database if initialized
 	if sra.database != nil {
 		sra.database.Close()
@@ -1165,6 +2636,11 @@ func (sra *SpacedRepetitionApp) Run() {
 }
 
 func main() {
+	// Commands such as "spaced lint" run without opening the window
+	if exitCode, ran := runCommand(os.Args[1:], os.Stdout, os.Stderr); ran {
+		os.Exit(exitCode)
+	}
+
 	app := NewSpacedRepetitionApp()
 	app.setupUI()
 
@@ -1207,9 +2683,10 @@ func main() {
 	}
 
 	// Load sample cards if available
-	if err := app.parser.LoadFromFile("sample_cards.txt"); err != nil {
+	if err := app.parser.ImportFile(context.Background(), app.database, "sample_cards.txt", nil); err != nil {
 		log.Printf("Failed to load sample cards: %v", err)
 	} else {
+		app.watchCardFile("sample_cards.txt")
 		app.updateDueCards()
 		app.updateStats()
 		app.nextCard()
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Code blocks indent with spaces, as RichText does not lay out tabs
+const codeTabWidth = 4
+
+// cardTextView shows card text as word-wrapped Markdown. Fenced code blocks
+// are set apart in monospace with syntax highlighting, and scroll sideways
+// instead of wrapping so that their indentation stays intact.
+type cardTextView struct {
+	widget.BaseWidget
+	content *fyne.Container
+}
+
+func newCardText(text string, plain bool, imagePath func(string) string) *cardTextView {
+	view := &cardTextView{content: container.NewVBox()}
+	view.ExtendBaseWidget(view)
+	view.SetText(text, plain, imagePath)
+	return view
+}
+
+func (v *cardTextView) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(v.content)
+}
+
+// SetText shows card text as Markdown, or exactly as it is written when the
+// card's deck uses plain text. imagePath, which may be nil, finds the files
+// of the images the text refers to. Math is drawn within the text.
+func (v *cardTextView) SetText(text string, plain bool, imagePath func(string) string) {
+	if plain {
+		v.content.Objects = []fyne.CanvasObject{newWrappedText(&widget.TextSegment{Style: widget.RichTextStyleParagraph, Text: text})}
+	} else {
+		v.content.Objects = nil
+		for _, block := range splitCodeBlocks(text) {
+			if block.code {
+				v.content.Add(newCodeBlock(block.text, block.language))
+			} else if strings.TrimSpace(block.text) != "" {
+				var math []*mathSegment
+				block.text = replaceMath(block.text, func(expr string, display bool) string {
+					math = append(math, newMathSegment(expr, display))
+					return mathPlaceholder(len(math) - 1)
+				})
+				if imagePath != nil {
+					block.text = replaceImageReferences(block.text, imagePath)
+				}
+				markdown := widget.NewRichTextFromMarkdown(cardMarkdown(block.text))
+				if len(math) > 0 {
+					markdown.Segments = insertMath(markdown.Segments, math)
+				}
+				markdown.Wrapping = fyne.TextWrapWord
+				v.content.Add(markdown)
+			}
+		}
+	}
+	v.content.Refresh()
+	v.Refresh()
+}
+
+func newWrappedText(segments ...widget.RichTextSegment) *widget.RichText {
+	richText := widget.NewRichText(segments...)
+	richText.Wrapping = fyne.TextWrapWord
+	return richText
+}
+
+// newCodeBlock shows code in monospace on a shaded background, coloured by
+// the language named after its fence
+func newCodeBlock(code, language string) fyne.CanvasObject {
+	code = strings.ReplaceAll(code, "\t", strings.Repeat(" ", codeTabWidth))
+
+	var segments []widget.RichTextSegment
+	for _, token := range highlightCode(code, language) {
+		segments = append(segments, &widget.TextSegment{Style: codeTokenStyle(token.kind), Text: token.text})
+	}
+	// Code does not wrap, long lines scroll instead
+	richText := widget.NewRichText(segments...)
+
+	background := canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
+	background.CornerRadius = theme.InputRadiusSize()
+	return container.NewStack(background, container.NewHScroll(richText))
+}
+
+func codeTokenStyle(kind codeTokenKind) widget.RichTextStyle {
+	style := widget.RichTextStyle{
+		Inline:    true,
+		SizeName:  theme.SizeNameText,
+		TextStyle: fyne.TextStyle{Monospace: true},
+		ColorName: theme.ColorNameForeground,
+	}
+	switch kind {
+	case codeKeyword:
+		style.ColorName = colorNameCodeKeyword
+		style.TextStyle.Bold = true
+	case codeString:
+		style.ColorName = colorNameCodeString
+	case codeComment:
+		style.ColorName = colorNameCodeComment
+		style.TextStyle.Italic = true
+	case codeNumber:
+		style.ColorName = colorNameCodeNumber
+	}
+	return style
+}
+
+// Math is taken out of the Markdown before it is parsed, leaving a
+// placeholder of private use characters, and put back in as segments of its
+// own afterwards
+const (
+	mathPlaceholderStart = '\ue000'
+	mathPlaceholderEnd   = '\ue001'
+)
+
+func mathPlaceholder(i int) string {
+	return fmt.Sprintf("%c%d%c", mathPlaceholderStart, i, mathPlaceholderEnd)
+}
+
+// insertMath replaces the math placeholders in parsed Markdown with math.
+// Text split around inline math stays on its line.
+func insertMath(segments []widget.RichTextSegment, math []*mathSegment) []widget.RichTextSegment {
+	var result []widget.RichTextSegment
+	for _, segment := range segments {
+		switch segment := segment.(type) {
+		case *widget.ParagraphSegment:
+			segment.Texts = insertMath(segment.Texts, math)
+		case *widget.ListSegment:
+			segment.Items = insertMath(segment.Items, math)
+		case *widget.HyperlinkSegment:
+			var text strings.Builder
+			for _, part := range splitMath(&widget.TextSegment{Text: segment.Text}, math) {
+				text.WriteString(part.Textual())
+			}
+			segment.Text = text.String()
+		case *widget.TextSegment:
+			result = append(result, splitMath(segment, math)...)
+			continue
+		}
+		result = append(result, segment)
+	}
+	return result
+}
+
+// splitMath splits text at its math placeholders. Math that could not be
+// drawn is shown as it was written.
+func splitMath(text *widget.TextSegment, math []*mathSegment) []widget.RichTextSegment {
+	var parts []widget.RichTextSegment
+	addText := func(s string) {
+		if s != "" {
+			parts = append(parts, &widget.TextSegment{Style: text.Style, Text: s})
+		}
+	}
+
+	rest := text.Text
+	for {
+		start := strings.IndexRune(rest, mathPlaceholderStart)
+		if start < 0 {
+			break
+		}
+		end := strings.IndexRune(rest[start:], mathPlaceholderEnd)
+		if end < 0 {
+			break
+		}
+		end += start
+		i, err := strconv.Atoi(rest[start+len(string(mathPlaceholderStart)) : end])
+		if err != nil || i < 0 || i >= len(math) {
+			break
+		}
+		addText(rest[:start])
+		if math[i].path == "" {
+			addText(math[i].source)
+		} else {
+			parts = append(parts, math[i])
+		}
+		rest = rest[end+len(string(mathPlaceholderEnd)):]
+	}
+	if len(parts) == 0 {
+		return []widget.RichTextSegment{text}
+	}
+
+	// Only the last part may end the line the text was on
+	for _, part := range parts {
+		if part, ok := part.(*widget.TextSegment); ok {
+			part.Style.Inline = true
+		}
+	}
+	if rest != "" || !text.Style.Inline {
+		parts = append(parts, &widget.TextSegment{Style: text.Style, Text: rest})
+	}
+	return parts
+}
+
+// mathSegment shows math in rich text. Inline math flows with the text
+// around it, display math takes a line of its own.
+type mathSegment struct {
+	source  string // As written, with its dollars
+	path    string // The drawn image, empty when it could not be drawn
+	display bool
+	size    fyne.Size
+}
+
+// newMathSegment draws math in the theme's text colour. Images are shown at
+// half their size, so math is drawn at twice the text size to stay sharp.
+func newMathSegment(expr string, display bool) *mathSegment {
+	segment := &mathSegment{source: "$" + expr + "$", display: display}
+	if display {
+		segment.source = "$$" + expr + "$$"
+	}
+
+	path, err := mathImage(expr, display, theme.Color(theme.ColorNameForeground), float64(2*theme.TextSize()))
+	if err != nil {
+		return segment
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return segment
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return segment
+	}
+	segment.path = path
+	segment.size = fyne.NewSize(float32(config.Width)/2, float32(config.Height)/2)
+	return segment
+}
+
+func (m *mathSegment) Inline() bool {
+	return !m.display
+}
+
+func (m *mathSegment) Textual() string {
+	return m.source
+}
+
+func (m *mathSegment) Visual() fyne.CanvasObject {
+	img := canvas.NewImageFromFile(m.path)
+	img.FillMode = canvas.ImageFillContain
+	img.SetMinSize(m.size)
+	return img
+}
+
+func (m *mathSegment) Update(o fyne.CanvasObject) {
+	img := o.(*canvas.Image)
+	img.File = m.path
+	img.SetMinSize(m.size)
+	img.Refresh()
+}
+
+func (m *mathSegment) Select(begin, end fyne.Position) {}
+
+func (m *mathSegment) SelectedText() string {
+	return ""
+}
+
+func (m *mathSegment) Unselect() {}
+
+// cardMarkdown keeps the line breaks of card text, which Markdown would join
+// into one paragraph, by putting each line in a paragraph of its own
+func cardMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+			if strings.TrimSpace(line) != "" && strings.TrimSpace(lines[i-1]) != "" {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// imagePattern matches a Markdown image, ![alt](path) or ![alt](<path>),
+// capturing its path
+var imagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*(<[^>\n]*>|[^)\s]+)`)
+
+// imageReferences returns the local files the images in card text refer to.
+// Images on the web and inside code blocks are left out.
+func imageReferences(text string) []string {
+	var references []string
+	seen := make(map[string]bool)
+	for _, block := range splitCodeBlocks(text) {
+		if block.code {
+			continue
+		}
+		for _, match := range imagePattern.FindAllStringSubmatch(block.text, -1) {
+			reference := strings.Trim(match[1], "<>")
+			if isLocalImage(reference) && !seen[reference] {
+				seen[reference] = true
+				references = append(references, reference)
+			}
+		}
+	}
+	return references
+}
+
+// replaceImageReferences rewrites the path of every local image in Markdown
+// text with the one path returns for it
+func replaceImageReferences(text string, path func(reference string) string) string {
+	return imagePattern.ReplaceAllStringFunc(text, func(image string) string {
+		match := imagePattern.FindStringSubmatchIndex(image)
+		reference := strings.Trim(image[match[2]:match[3]], "<>")
+		if !isLocalImage(reference) {
+			return image
+		}
+		return image[:match[2]] + "<" + path(reference) + ">"
+	})
+}
+
+func isLocalImage(reference string) bool {
+	return reference != "" && !strings.Contains(reference, "://") && !strings.HasPrefix(reference, "data:")
+}
+
+// resolveReference returns the file a reference in a card file points to
+func resolveReference(cardFile, reference string) string {
+	path := filepath.FromSlash(reference)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(cardFile), path)
+}
+
+// MediaStore keeps copies of the images cards refer to in a folder next to
+// the database, so that cards still show them when the card file moves or
+// is deleted. Files are named after their content, so a file used by many
+// cards is stored once.
+type MediaStore struct {
+	dir  string
+	repo MediaRepository
+}
+
+func NewMediaStore(dir string, repo MediaRepository) *MediaStore {
+	return &MediaStore{dir: dir, repo: repo}
+}
+
+// mediaDir returns the media folder that belongs to a database file
+func mediaDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "media")
+}
+
+// withRepository returns the store using another repository, such as one
+// running in a transaction
+func (s *MediaStore) withRepository(repo MediaRepository) *MediaStore {
+	if s == nil {
+		return nil
+	}
+	return &MediaStore{dir: s.dir, repo: repo}
+}
+
+// attach copies the images a card's text refers to into the media folder.
+// References are resolved relative to cardFile. Images that cannot be read
+// are skipped, as parsing the file already reported them.
+func (s *MediaStore) attach(card *DBCard, cardFile string) error {
+	references := imageReferences(card.Question + "\n" + card.Answer)
+
+	existing, err := s.repo.GetByCardID(card.ID)
+	if err != nil {
+		return err
+	}
+	for _, media := range existing {
+		if !containsString(references, media.Reference) {
+			if err := s.repo.Delete(media.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, reference := range references {
+		fileName, err := s.store(resolveReference(cardFile, reference))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.repo.Save(&DBMedia{CardID: card.ID, Reference: reference, FileName: fileName}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// store copies a file into the media folder, unless a file with the same
+// content is there already, and returns its name in the folder
+func (s *MediaStore) store(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	fileName := hex.EncodeToString(sum[:16]) + strings.ToLower(filepath.Ext(path))
+	target := filepath.Join(s.dir, fileName)
+	if _, err := os.Stat(target); err == nil {
+		return fileName, nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create media folder: %w", err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to copy %s to the media folder: %w", path, err)
+	}
+	return fileName, nil
+}
+
+// paths returns the stored copies of a card's images by reference
+func (s *MediaStore) paths(cardID int64) map[string]string {
+	paths := make(map[string]string)
+	media, err := s.repo.GetByCardID(cardID)
+	if err != nil {
+		return paths
+	}
+	for _, m := range media {
+		paths[m.Reference] = filepath.Join(s.dir, m.FileName)
+	}
+	return paths
+}
+
+// Clean deletes the files in the media folder that no card refers to any
+// more and returns their names
+func (s *MediaStore) Clean() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media folder: %w", err)
+	}
+
+	media, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, m := range media {
+		used[m.FileName] = true
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if entry.IsDir() || used[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+			return removed, fmt.Errorf("failed to delete %s: %w", entry.Name(), err)
+		}
+		removed = append(removed, entry.Name())
+	}
+	return removed, nil
+}
+
+// exportFile copies a card's image to where its reference points from the
+// exported card file. A different file already there is left alone.
+func (s *MediaStore) exportFile(media *DBMedia, exportPath string) error {
+	if filepath.IsAbs(filepath.FromSlash(media.Reference)) {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, media.FileName))
+	if err != nil {
+		return fmt.Errorf("failed to read %s from the media folder: %w", media.Reference, err)
+	}
+
+	target := resolveReference(exportPath, media.Reference)
+	if existing, err := os.ReadFile(target); err == nil {
+		if bytes.Equal(existing, data) {
+			return nil
+		}
+		return fmt.Errorf("%s already exists with other content", target)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %w", target, err)
+	}
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+// SetMediaStore makes imports copy the images cards refer to into store
+func (cp *CardParser) SetMediaStore(store *MediaStore) {
+	cp.media = store
+}
+
+// importMedia copies the images of the current file's cards into the media
+// store
+func (cp *CardParser) importMedia() error {
+	if cp.media == nil {
+		return nil
+	}
+	dbCards, err := cp.cardRepo.GetBySourceFile(cp.currentFile)
+	if err != nil {
+		return fmt.Errorf("failed to get cards of %s: %w", cp.currentFile, err)
+	}
+	for _, dbCard := range dbCards {
+		if err := cp.media.attach(dbCard, cp.currentFile); err != nil {
+			return fmt.Errorf("card %d: %w", dbCard.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkImages reports images that parsed cards refer to but that do not
+// exist next to the card file
+func (cp *CardParser) checkImages(entries []*parsedEntry) {
+	if cp.currentFile == "" {
+		return
+	}
+	for _, entry := range entries {
+		text := entry.content
+		if entry.noteType == "" {
+			text = entry.cards[0].Question + "\n" + entry.cards[0].Answer
+		}
+		for _, reference := range imageReferences(text) {
+			if _, err := os.Stat(resolveReference(cp.currentFile, reference)); err != nil {
+				cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+					LineNum: entry.lineNum,
+					Line:    entry.line,
+					Reason:  fmt.Sprintf("Image %s not found", reference),
+				})
+			}
+		}
+	}
+}
+
+// ImagePath returns a function that finds the file an image in a card's
+// text refers to: the copy in the media folder, or else the file next to
+// the card file
+func (cp *CardParser) ImagePath(card Card) func(reference string) string {
+	var stored map[string]string
+	if cp.media != nil && card.ID != 0 {
+		stored = cp.media.paths(card.ID)
+	}
+	return func(reference string) string {
+		if path, ok := stored[reference]; ok {
+			return path
+		}
+		if card.FilePath != "" {
+			return resolveReference(card.FilePath, reference)
+		}
+		return reference
+	}
+}
+
+// CleanMedia deletes media files that no card refers to any more
+func (cp *CardParser) CleanMedia() ([]string, error) {
+	if cp.media == nil {
+		return nil, fmt.Errorf("no media folder available")
+	}
+	return cp.media.Clean()
+}
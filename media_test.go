@@ -0,0 +1,227 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImageReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "local images",
+			text: "A cat ![cat](img/cat.png) and a ![](../dog.jpg \"Dog\")",
+			want: []string{"img/cat.png", "../dog.jpg"},
+		},
+		{
+			name: "path with spaces",
+			text: "![map](<maps/old map.png>)",
+			want: []string{"maps/old map.png"},
+		},
+		{
+			name: "each image once",
+			text: "![a](cat.png)\n![b](cat.png)",
+			want: []string{"cat.png"},
+		},
+		{
+			name: "images on the web and inline",
+			text: "![a](https://example.com/cat.png) ![b](data:image/png;base64,AAAA)",
+		},
+		{
+			name: "images in code",
+			text: "```md\n![a](code.png)\n```\n![b](shown.png)",
+			want: []string{"shown.png"},
+		},
+		{
+			name: "links are not images",
+			text: "[cat](cat.png)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := imageReferences(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("imageReferences(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReplaceImageReferences(t *testing.T) {
+	text := "![cat](img/cat.png) ![web](https://example.com/dog.png) ![map](<old map.png>)"
+	got := replaceImageReferences(text, func(reference string) string {
+		return "/media/" + reference
+	})
+	want := "![cat](</media/img/cat.png>) ![web](https://example.com/dog.png) ![map](</media/old map.png>)"
+	if got != want {
+		t.Errorf("replaceImageReferences() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	cardFile := filepath.Join("/cards", "go", "basics.txt")
+	tests := []struct {
+		reference string
+		want      string
+	}{
+		{"gopher.png", filepath.Join("/cards", "go", "gopher.png")},
+		{"img/gopher.png", filepath.Join("/cards", "go", "img", "gopher.png")},
+		{"../shared/gopher.png", filepath.Join("/cards", "shared", "gopher.png")},
+		{"/images/gopher.png", filepath.FromSlash("/images/gopher.png")},
+	}
+
+	for _, test := range tests {
+		if got := resolveReference(cardFile, test.reference); got != test.want {
+			t.Errorf("resolveReference(%q) = %q, want %q", test.reference, got, test.want)
+		}
+	}
+}
+
+// mediaFiles lists the files in the media folder
+func mediaFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestImportMedia(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{
+		"cards/go.txt":         "Gopher?>>![gopher](img/gopher.png)\nSame gopher?>>![copy](../shared/gopher.png)\nLost?>>![lost](missing.png)\n",
+		"cards/img/gopher.png": "gopher",
+		"shared/gopher.png":    "gopher",
+	})
+	cardPath := filepath.Join(dir, "cards", "go.txt")
+
+	db := newTestDatabase(t)
+	media := NewMediaStore(filepath.Join(dir, "media"), NewSQLiteMediaRepository(db))
+	cp := newTestParser(db)
+	cp.SetMediaStore(media)
+	if err := cp.LoadFromFile(cardPath); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+
+	errors := cp.GetParseResult().Errors
+	if len(errors) != 1 || errors[0].LineNum != 3 || errors[0].Reason != "Image missing.png not found" {
+		t.Errorf("parse errors = %+v, want missing.png reported on line 3", errors)
+	}
+
+	// Both references are to the same content, which is stored once
+	files := mediaFiles(t, media.dir)
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".png") {
+		t.Fatalf("media folder holds %q, want one copy of the gopher", files)
+	}
+	if n := countRows(t, db, "media"); n != 2 {
+		t.Errorf("%d media rows, want 2", n)
+	}
+
+	// Cards show the copy, so the original can go
+	if err := os.RemoveAll(filepath.Join(dir, "cards", "img")); err != nil {
+		t.Fatal(err)
+	}
+	stored := storedCards(t, db, cardPath)
+	card := Card{ID: stored["Gopher?"].ID, FilePath: cardPath}
+	if got, want := cp.ImagePath(card)("img/gopher.png"), filepath.Join(media.dir, files[0]); got != want {
+		t.Errorf("image path = %q, want the copy %q", got, want)
+	}
+	if got, want := cp.ImagePath(card)("other.png"), filepath.Join(dir, "cards", "other.png"); got != want {
+		t.Errorf("path of an image without a copy = %q, want %q", got, want)
+	}
+
+	// Images no card refers to any more are cleaned up
+	if err := os.WriteFile(cardPath, []byte("Gopher?>>gone\nSame gopher?>>![copy](../shared/gopher.png)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.LoadFromFile(cardPath); err != nil {
+		t.Fatalf("LoadFromFile again: %v", err)
+	}
+	if removed, err := cp.CleanMedia(); err != nil || len(removed) != 0 {
+		t.Errorf("CleanMedia() = %q, %v; want the used copy kept", removed, err)
+	}
+	if err := os.WriteFile(cardPath, []byte("Same gopher?>>gone too\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.LoadFromFile(cardPath); err != nil {
+		t.Fatalf("LoadFromFile again: %v", err)
+	}
+	if n := countRows(t, db, "media"); n != 0 {
+		t.Errorf("%d media rows after the images were taken out, want 0", n)
+	}
+	if removed, err := cp.CleanMedia(); err != nil || !reflect.DeepEqual(removed, files) {
+		t.Errorf("CleanMedia() = %q, %v; want %q", removed, err, files)
+	}
+	if files := mediaFiles(t, media.dir); len(files) != 0 {
+		t.Errorf("media folder holds %q after cleaning, want nothing", files)
+	}
+}
+
+func TestCleanMediaWithoutFolder(t *testing.T) {
+	media := NewMediaStore(filepath.Join(t.TempDir(), "media"), NewSQLiteMediaRepository(newTestDatabase(t)))
+	if removed, err := media.Clean(); err != nil || len(removed) != 0 {
+		t.Errorf("Clean() = %q, %v; want nothing to do", removed, err)
+	}
+	if _, err := NewCardParser().CleanMedia(); err == nil {
+		t.Error("CleanMedia without a media store succeeded, want an error")
+	}
+}
+
+func TestExportMedia(t *testing.T) {
+	dir := writeCardTree(t, map[string]string{
+		"cards/go.txt":         "---\ndeck: Go\n---\nGopher?>>![gopher](img/gopher.png)\nGlenda?>>![glenda](glenda.png)\n",
+		"cards/img/gopher.png": "gopher",
+		"cards/glenda.png":     "glenda",
+		"export/glenda.png":    "another glenda",
+	})
+
+	db := newTestDatabase(t)
+	media := NewMediaStore(filepath.Join(dir, "media"), NewSQLiteMediaRepository(db))
+	cp := newTestParser(db)
+	cp.SetMediaStore(media)
+	if err := cp.LoadFromFile(filepath.Join(dir, "cards", "go.txt")); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+
+	exportPath := filepath.Join(dir, "export", "go.txt")
+	exporter := NewCardExporter(NewSQLiteCardRepository(db), NewSQLiteNoteRepository(db), NewSQLiteDeckRepository(db), media)
+	result, err := exporter.ExportFile(exportPath, CardExportOptions{Deck: "Go"})
+	if err != nil {
+		t.Fatalf("ExportFile: %v", err)
+	}
+
+	// A different file where an image goes is left alone
+	if result.Cards != 2 || result.Images != 1 || len(result.Skipped) != 1 || !strings.Contains(result.Skipped[0], "already exists") {
+		t.Errorf("result = %+v, want 2 cards, 1 image and glenda.png skipped", result)
+	}
+	contents := make(map[string]string)
+	for _, name := range []string{"img/gopher.png", "glenda.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, "export", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[name] = string(data)
+	}
+	if want := map[string]string{"img/gopher.png": "gopher", "glenda.png": "another glenda"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("exported images = %q, want %q", contents, want)
+	}
+
+	// Exporting again finds the same images in place
+	result, err = exporter.ExportFile(exportPath, CardExportOptions{Deck: "Go"})
+	if err != nil {
+		t.Fatalf("ExportFile again: %v", err)
+	}
+	if result.Images != 1 || len(result.Skipped) != 1 {
+		t.Errorf("result of exporting again = %+v, want the same image exported", result)
+	}
+}
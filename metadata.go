@@ -0,0 +1,193 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cardMetadata holds the optional fields a card file can set for a card:
+//
+//	What is a goroutine? >> lightweight thread #golang #concurrency @"Go in Action" [conceptual]
+//
+// Tags are written as #tag, the source as @word or @"quoted source" and the
+// prompt type in square brackets. Metadata is only recognised at the end of a
+// line, so a "#" or "@" inside the answer text is left alone. Block cards
+// carry their metadata on the closing line: --- #golang [conceptual]
+type cardMetadata struct {
+	SourceContext string
+	PromptType    string
+	Tags          string // Comma-separated, as stored on cards
+	DeckID        int64  // Only set from front matter
+}
+
+func (m cardMetadata) apply(card *Card) {
+	card.SourceContext = m.SourceContext
+	card.PromptType = m.PromptType
+	card.Tags = m.Tags
+	card.DeckID = m.DeckID
+}
+
+// withDefaults fills in the fields a card did not set from the file's
+// defaults. Inline tags are added to the file's tags rather than replacing
+// them.
+func (m cardMetadata) withDefaults(defaults cardMetadata) cardMetadata {
+	if m.SourceContext == "" {
+		m.SourceContext = defaults.SourceContext
+	}
+	if m.PromptType == "" {
+		m.PromptType = defaults.PromptType
+	}
+	if m.DeckID == 0 {
+		m.DeckID = defaults.DeckID
+	}
+	m.Tags = mergeTags(defaults.Tags, m.Tags)
+	return m
+}
+
+// withoutDefaults drops what the file's defaults already supply, which is
+// the metadata a card written to the file needs inline
+func (m cardMetadata) withoutDefaults(defaults cardMetadata) cardMetadata {
+	if m.SourceContext == defaults.SourceContext {
+		m.SourceContext = ""
+	}
+	defaultPromptType := defaults.PromptType
+	if defaultPromptType == "" {
+		defaultPromptType = "factual"
+	}
+	if m.PromptType == defaultPromptType {
+		m.PromptType = ""
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(m.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !hasTag(defaults.Tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	m.Tags = strings.Join(tags, ", ")
+	m.DeckID = 0
+	return m
+}
+
+// mergeTags combines two comma-separated tag lists, dropping duplicates
+func mergeTags(a, b string) string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, tag := range strings.Split(a+","+b, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return strings.Join(merged, ", ")
+}
+
+// formatInlineMetadata writes metadata the way extractInlineMetadata reads
+// it, with a leading space
+func formatInlineMetadata(meta cardMetadata) string {
+	var b strings.Builder
+	for _, tag := range strings.Split(meta.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			b.WriteString(" #" + tag)
+		}
+	}
+	if meta.SourceContext != "" {
+		b.WriteString(` @"` + meta.SourceContext + `"`)
+	}
+	if meta.PromptType != "" {
+		b.WriteString(" [" + meta.PromptType + "]")
+	}
+	return b.String()
+}
+
+// extractInlineMetadata strips trailing metadata tokens from text and returns
+// the remaining text together with the metadata found
+func extractInlineMetadata(text string) (string, cardMetadata) {
+	var meta cardMetadata
+	var tags []string
+
+	rest := strings.TrimRightFunc(text, unicode.IsSpace)
+	for rest != "" {
+		token, remaining, ok := lastMetadataToken(rest)
+		// Metadata only follows card text, a lone "#include" is the text
+		if !ok || strings.TrimSpace(remaining) == "" {
+			break
+		}
+
+		switch {
+		case strings.HasPrefix(token, "#"):
+			tags = append([]string{token[1:]}, tags...)
+		case strings.HasPrefix(token, "@"):
+			// The last source written wins, which is the first one found here
+			if meta.SourceContext == "" {
+				meta.SourceContext = strings.Trim(token[1:], `"`)
+			}
+		case strings.HasPrefix(token, "["):
+			if meta.PromptType == "" {
+				meta.PromptType, _ = NormalizePromptType(token[1 : len(token)-1])
+			}
+		}
+
+		rest = strings.TrimRightFunc(remaining, unicode.IsSpace)
+	}
+
+	meta.Tags = normalizeTags(strings.Join(tags, " "))
+	return rest, meta
+}
+
+// lastMetadataToken splits the last metadata token off text. It reports false
+// when text does not end in a tag, source or known prompt type.
+func lastMetadataToken(text string) (token, rest string, ok bool) {
+	// Quoted source: @"Go in Action"
+	if strings.HasSuffix(text, `"`) {
+		start := strings.LastIndex(text[:len(text)-1], `@"`)
+		if start < 0 || !startsToken(text, start) || start+2 > len(text)-1 {
+			return "", "", false
+		}
+		return text[start:], text[:start], true
+	}
+
+	// Prompt type: [conceptual]
+	if strings.HasSuffix(text, "]") {
+		start := strings.LastIndex(text, "[")
+		if start < 0 || !startsToken(text, start) {
+			return "", "", false
+		}
+		if _, valid := NormalizePromptType(text[start+1 : len(text)-1]); !valid {
+			return "", "", false
+		}
+		return text[start:], text[:start], true
+	}
+
+	// Tag or unquoted source: #golang, @gopl
+	start := strings.LastIndexFunc(text, unicode.IsSpace) + 1
+	token = text[start:]
+	if len(token) < 2 || (token[0] != '#' && token[0] != '@') {
+		return "", "", false
+	}
+	return token, text[:start], true
+}
+
+// parseBlockDelimiter reports whether line closes a card block, returning the
+// metadata written after the "---"
+func parseBlockDelimiter(line string) (cardMetadata, bool) {
+	if !strings.HasPrefix(line, blockDelimiter) {
+		return cardMetadata{}, false
+	}
+
+	rest, meta := extractInlineMetadata(line)
+	if rest != blockDelimiter {
+		return cardMetadata{}, false
+	}
+	return meta, true
+}
+
+// startsToken reports whether position idx in text starts a new token, i.e.
+// is at the beginning of text or follows whitespace
+func startsToken(text string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	return unicode.IsSpace(rune(text[idx-1]))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractInlineMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		rest string
+		meta cardMetadata
+	}{
+		{
+			name: "all kinds",
+			text: `lightweight thread #golang #concurrency @"Go in Action" [conceptual]`,
+			rest: "lightweight thread",
+			meta: cardMetadata{SourceContext: "Go in Action", PromptType: "conceptual", Tags: "golang, concurrency"},
+		},
+		{
+			name: "in any order with trailing space",
+			text: "thread [Factual Recall] @gopl #go  ",
+			rest: "thread",
+			meta: cardMetadata{SourceContext: "gopl", PromptType: "factual", Tags: "go"},
+		},
+		{
+			name: "last source written wins",
+			text: "thread @first @second",
+			rest: "thread",
+			meta: cardMetadata{SourceContext: "second"},
+		},
+		{
+			name: "no metadata",
+			text: "a plain answer",
+			rest: "a plain answer",
+		},
+		{
+			name: "text that is only a tag",
+			text: "#include",
+			rest: "#include",
+		},
+		{
+			name: "metadata before the text ends",
+			text: "#1 in sales",
+			rest: "#1 in sales",
+		},
+		{
+			name: "hash within a word",
+			text: "use C#",
+			rest: "use C#",
+		},
+		{
+			name: "lone symbols",
+			text: "reply # or @",
+			rest: "reply # or @",
+		},
+		{
+			name: "unknown prompt type",
+			text: "an array [guess]",
+			rest: "an array [guess]",
+		},
+		{
+			name: "brackets within a word",
+			text: "a[conceptual]",
+			rest: "a[conceptual]",
+		},
+		{
+			name: "quotes without a source",
+			text: `he said "hi"`,
+			rest: `he said "hi"`,
+		},
+		{
+			name: "metadata stops at text",
+			text: "#a text #b",
+			rest: "#a text",
+			meta: cardMetadata{Tags: "b"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rest, meta := extractInlineMetadata(test.text)
+			if rest != test.rest || meta != test.meta {
+				t.Errorf("extractInlineMetadata(%q) = %q, %+v; want %q, %+v", test.text, rest, meta, test.rest, test.meta)
+			}
+		})
+	}
+}
+
+func TestFormatInlineMetadata(t *testing.T) {
+	meta := cardMetadata{SourceContext: "Go in Action", PromptType: "conceptual", Tags: "golang, concurrency"}
+	formatted := formatInlineMetadata(meta)
+	if want := ` #golang #concurrency @"Go in Action" [conceptual]`; formatted != want {
+		t.Errorf("formatInlineMetadata() = %q, want %q", formatted, want)
+	}
+	if rest, parsed := extractInlineMetadata("answer" + formatted); rest != "answer" || parsed != meta {
+		t.Errorf("reading %q back = %q, %+v; want %q, %+v", formatted, rest, parsed, "answer", meta)
+	}
+	if formatted := formatInlineMetadata(cardMetadata{}); formatted != "" {
+		t.Errorf("formatInlineMetadata of nothing = %q, want \"\"", formatted)
+	}
+}
+
+func TestParseBlockDelimiter(t *testing.T) {
+	tests := []struct {
+		line string
+		meta cardMetadata
+		ok   bool
+	}{
+		{"---", cardMetadata{}, true},
+		{"--- #go [application]", cardMetadata{PromptType: "application", Tags: "go"}, true},
+		{"---   ", cardMetadata{}, true},
+		{"----", cardMetadata{}, false},
+		{"--- more text", cardMetadata{}, false},
+		{"--- text #go", cardMetadata{}, false},
+		{"- #go", cardMetadata{}, false},
+	}
+
+	for _, test := range tests {
+		meta, ok := parseBlockDelimiter(test.line)
+		if meta != test.meta || ok != test.ok {
+			t.Errorf("parseBlockDelimiter(%q) = %+v, %t; want %+v, %t", test.line, meta, ok, test.meta, test.ok)
+		}
+	}
+}
+
+func TestCardMetadataDefaults(t *testing.T) {
+	defaults := cardMetadata{SourceContext: "Go in Action", PromptType: "conceptual", Tags: "golang, book", DeckID: 3}
+
+	card := cardMetadata{SourceContext: "The Go Blog", Tags: "arrays, golang"}
+	withDefaults := card.withDefaults(defaults)
+	want := cardMetadata{SourceContext: "The Go Blog", PromptType: "conceptual", Tags: "golang, book, arrays", DeckID: 3}
+	if withDefaults != want {
+		t.Errorf("withDefaults() = %+v, want %+v", withDefaults, want)
+	}
+
+	// What the file already supplies is not written on the card again
+	if got, want := withDefaults.withoutDefaults(defaults), (cardMetadata{SourceContext: "The Go Blog", Tags: "arrays"}); got != want {
+		t.Errorf("withoutDefaults() = %+v, want %+v", got, want)
+	}
+
+	// Cards are factual unless the file says otherwise
+	if got := (cardMetadata{PromptType: "factual"}).withoutDefaults(cardMetadata{}); got != (cardMetadata{}) {
+		t.Errorf("withoutDefaults() of a factual card = %+v, want no metadata", got)
+	}
+}
+
+func TestLoadFromFileInlineMetadata(t *testing.T) {
+	content := `What is a goroutine? >> lightweight thread #golang #concurrency @"Go in Action" [conceptual]
+Tea <> thé #french
+The {{c1::Sun}} is a star #astronomy @Sagan
+Q: Block question
+A: Block answer #not-a-tag
+--- #go [application]
+Use C# >> yes
+`
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if cp.HasParseErrors() {
+		t.Fatalf("parse errors: %+v", cp.parseResult.Errors)
+	}
+
+	type cardMeta struct{ Q, A, Source, PromptType, Tags string }
+	want := []cardMeta{
+		{"What is a goroutine?", "lightweight thread", "Go in Action", "conceptual", "golang, concurrency"},
+		{"Tea", "thé", "", "factual", "french"},
+		{"thé", "Tea", "", "factual", "french"},
+		{"The [...] is a star", "Sun", "Sagan", "factual", "astronomy"},
+		{"Block question", "Block answer #not-a-tag", "", "application", "go"},
+		{"Use C#", "yes", "", "factual", ""},
+	}
+
+	var loaded []cardMeta
+	for _, card := range cp.GetCards() {
+		promptType := card.PromptType
+		if promptType == "" {
+			promptType = "factual"
+		}
+		loaded = append(loaded, cardMeta{card.Question, card.Answer, card.SourceContext, promptType, card.Tags})
+	}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("loaded cards = %q, want %q", loaded, want)
+	}
+
+	dbCards, err := NewSQLiteCardRepository(db).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []cardMeta
+	for _, card := range dbCards {
+		stored = append(stored, cardMeta{card.Question, card.Answer, card.SourceContext.String, card.PromptType, card.Tags})
+	}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored cards = %q, want %q", stored, want)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Note types. A note stores the text its sibling cards are generated from,
+// so editing the note regenerates every sibling.
+const (
+	NoteTypeCloze   = "cloze"
+	NoteTypeReverse = "reverse"
+)
+
+// reverseSeparator splits a reverse note into its two sides, both in card
+// files ("word<>translation") and in the stored note content
+const reverseSeparator = "<>"
+
+// ReverseNoteContent joins the two sides of a reverse note
+func ReverseNoteContent(front, back string) string {
+	return front + " " + reverseSeparator + " " + back
+}
+
+// SplitReverseNote returns the two sides of a reverse note's content
+func SplitReverseNote(content string) (front, back string, ok bool) {
+	parts := strings.Split(content, reverseSeparator)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// buildNoteCards generates the sibling cards for a note's content
+func buildNoteCards(noteType, content string) ([]Card, error) {
+	var cards []Card
+	switch noteType {
+	case NoteTypeCloze:
+		cards = BuildClozeCards(content)
+		if len(cards) == 0 {
+			return nil, fmt.Errorf("no cloze deletions found, expected markup like {{c1::term}}")
+		}
+	case NoteTypeReverse:
+		front, back, ok := SplitReverseNote(content)
+		if !ok {
+			return nil, fmt.Errorf("expected exactly one %q between the two sides", reverseSeparator)
+		}
+		// Ordinal 1 asks front -> back, ordinal 2 asks back -> front
+		cards = []Card{
+			{Question: front, Answer: back, Ordinal: 1},
+			{Question: back, Answer: front, Ordinal: 2},
+		}
+	default:
+		return nil, fmt.Errorf("unknown note type %q", noteType)
+	}
+
+	for _, card := range cards {
+		if card.Question == "" || card.Answer == "" {
+			return nil, fmt.Errorf("card %d of the note has an empty question or answer", card.Ordinal)
+		}
+	}
+
+	return cards, nil
+}
+
+// storeNote records the cards generated from a note found in a card file.
+// The note is written to the database once the whole file has been read.
+func (cp *CardParser) storeNote(noteType, content string, meta cardMetadata, lineNum int, line string) {
+	meta = meta.withDefaults(cp.defaults)
+
+	cards, err := buildNoteCards(noteType, content)
+	if err != nil {
+		cp.skipLine(lineNum, line, fmt.Sprintf("Invalid %s note: %v", noteType, err))
+		return
+	}
+
+	for i := range cards {
+		cards[i].FilePath = cp.currentFile
+		cards[i].LineNum = lineNum
+		meta.apply(&cards[i])
+	}
+
+	// Store in memory for immediate access
+	cp.cards = append(cp.cards, cards...)
+	cp.parseResult.Cards = append(cp.parseResult.Cards, cards...)
+	cp.parseResult.ValidCards += len(cards)
+
+	cp.pending = append(cp.pending, &parsedEntry{
+		lineNum:  lineNum,
+		line:     line,
+		noteType: noteType,
+		content:  content,
+		cards:    cards,
+		meta:     meta,
+	})
+}
+
+// createNoteCards stores a note and its generated cards in the database and
+// fills in the database ID of each card
+func createNoteCards(cardRepo CardRepository, noteRepo NoteRepository, noteType, content string, cards []Card) (*DBNote, error) {
+	note := &DBNote{
+		NoteType: noteType,
+		Content:  content,
+	}
+	if err := noteRepo.Create(note); err != nil {
+		return nil, err
+	}
+
+	for i, card := range cards {
+		dbCard := &DBCard{
+			Question:      card.Question,
+			Answer:        card.Answer,
+			SourceFile:    card.FilePath,
+			SourceLine:    card.LineNum,
+			SourceContext: sql.NullString{String: card.SourceContext, Valid: card.SourceContext != ""},
+			PromptType:    card.PromptType,
+			Tags:          card.Tags,
+			NoteID:        sql.NullInt64{Int64: note.ID, Valid: true},
+			Ordinal:       card.Ordinal,
+			DeckID:        sql.NullInt64{Int64: card.DeckID, Valid: card.DeckID != 0},
+		}
+		if err := cardRepo.Create(dbCard); err != nil {
+			return nil, fmt.Errorf("failed to create card %d of note: %w", card.Ordinal, err)
+		}
+		cards[i].ID = dbCard.ID
+		cards[i].NoteID = note.ID
+	}
+
+	return note, nil
+}
+
+func (cp *CardParser) AddNoteWithMetadata(noteType, content, source, promptType, tags string) error {
+	if content == "" {
+		return fmt.Errorf("note content cannot be empty")
+	}
+
+	// Store tags the same way card files do, whether typed as "#go #cli" or "go, cli"
+	tags = normalizeTags(tags)
+
+	cards, err := buildNoteCards(noteType, content)
+	if err != nil {
+		return err
+	}
+
+	if cp.cardRepo != nil && cp.noteRepo != nil {
+		exists, err := cp.noteRepo.NoteExists(noteType, content)
+		if err != nil {
+			return fmt.Errorf("failed to check if note exists: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("note with this content already exists")
+		}
+	}
+
+	meta := cardMetadata{SourceContext: source, PromptType: promptType, Tags: tags}
+	sourceLine := len(cp.cards) + 1
+	if cp.writeBackFile() != "" {
+		text, err := noteCardText(noteType, content, meta)
+		if err == nil {
+			sourceLine, err = cp.appendCardText(text)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write note to %s: %w", cp.currentFile, err)
+		}
+		// The cards get the file's defaults, as they will when the file is reloaded
+		meta = meta.withDefaults(cp.defaults)
+	}
+
+	now := time.Now()
+	for i := range cards {
+		cards[i].FilePath = cp.currentFile
+		cards[i].LineNum = sourceLine
+		cards[i].CreatedAt = now
+		meta.apply(&cards[i])
+	}
+
+	if cp.cardRepo != nil && cp.noteRepo != nil {
+		if _, err := createNoteCards(cp.cardRepo, cp.noteRepo, noteType, content, cards); err != nil {
+			return fmt.Errorf("failed to add note to database: %w", err)
+		}
+	}
+
+	// Add to memory
+	cp.cards = append(cp.cards, cards...)
+
+	return nil
+}
+
+func (cp *CardParser) GetNote(noteID int64) (*DBNote, error) {
+	if cp.noteRepo == nil {
+		return nil, fmt.Errorf("no database repository available")
+	}
+
+	return cp.noteRepo.GetByID(noteID)
+}
+
+// UpdateNote replaces a note's content and regenerates its sibling cards.
+// Siblings that still exist keep their review state; the IDs of siblings that
+// no longer exist are deleted and returned so their state can be cleaned up.
+func (cp *CardParser) UpdateNote(noteID int64, content string) ([]int64, error) {
+	if content == "" {
+		return nil, fmt.Errorf("note content cannot be empty")
+	}
+
+	if cp.cardRepo == nil || cp.noteRepo == nil {
+		return nil, fmt.Errorf("no database repository available")
+	}
+
+	note, err := cp.noteRepo.GetByID(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	if _, err := buildNoteCards(note.NoteType, content); err != nil {
+		return nil, err
+	}
+
+	siblings, err := cp.cardRepo.GetByNoteID(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards of note: %w", err)
+	}
+
+	if len(siblings) > 0 && cp.canWriteBack(siblings[0].SourceFile) {
+		before, err := noteCardText(note.NoteType, note.Content, cardMetadata{})
+		if err != nil {
+			return nil, err
+		}
+		after, err := noteCardText(note.NoteType, content, cardMetadata{})
+		if err != nil {
+			return nil, err
+		}
+		if err := cp.rewriteCardText(siblings[0].SourceFile, siblings[0].SourceLine, before, after); err != nil {
+			return nil, fmt.Errorf("failed to write note to %s: %w", siblings[0].SourceFile, err)
+		}
+	}
+
+	return cp.updateNote(noteID, content)
+}
+
+// updateNote changes a note in the database only, as when its card file was
+// edited
+func (cp *CardParser) updateNote(noteID int64, content string) ([]int64, error) {
+	note, err := cp.noteRepo.GetByID(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	cards, err := buildNoteCards(note.NoteType, content)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings, err := cp.cardRepo.GetByNoteID(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards of note: %w", err)
+	}
+
+	existing := make(map[int]*DBCard)
+	for _, sibling := range siblings {
+		existing[sibling.Ordinal] = sibling
+	}
+
+	for _, card := range cards {
+		if sibling, ok := existing[card.Ordinal]; ok {
+			sibling.Question = card.Question
+			sibling.Answer = card.Answer
+			if err := cp.cardRepo.Update(sibling); err != nil {
+				return nil, fmt.Errorf("failed to update card %d of note: %w", card.Ordinal, err)
+			}
+			delete(existing, card.Ordinal)
+			continue
+		}
+
+		// New sibling, inherit metadata from the note's other cards
+		dbCard := &DBCard{
+			Question: card.Question,
+			Answer:   card.Answer,
+			NoteID:   sql.NullInt64{Int64: noteID, Valid: true},
+			Ordinal:  card.Ordinal,
+		}
+		if len(siblings) > 0 {
+			dbCard.SourceFile = siblings[0].SourceFile
+			dbCard.SourceLine = siblings[0].SourceLine
+			dbCard.SourceContext = siblings[0].SourceContext
+			dbCard.PromptType = siblings[0].PromptType
+			dbCard.Tags = siblings[0].Tags
+			dbCard.DeckID = siblings[0].DeckID
+		}
+		if err := cp.cardRepo.Create(dbCard); err != nil {
+			return nil, fmt.Errorf("failed to create card %d of note: %w", card.Ordinal, err)
+		}
+	}
+
+	var removed []int64
+	for _, sibling := range existing {
+		if err := cp.cardRepo.Delete(sibling.ID); err != nil {
+			return removed, fmt.Errorf("failed to delete card %d of note: %w", sibling.Ordinal, err)
+		}
+		removed = append(removed, sibling.ID)
+	}
+
+	note.Content = content
+	if err := cp.noteRepo.Update(note); err != nil {
+		return removed, fmt.Errorf("failed to update note: %w", err)
+	}
+
+	return removed, nil
+}
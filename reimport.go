@@ -0,0 +1,333 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// parsedEntry is a card or note read from a card file, waiting to be written
+// to the database
+type parsedEntry struct {
+	lineNum  int
+	line     string
+	noteType string // Empty for a standalone card
+	content  string // Note content
+	cards    []Card // The card, or the cards generated from the note
+	meta     cardMetadata
+	match    *importedEntry
+}
+
+// importedEntry is a card or note that an earlier import of the same file
+// stored in the database
+type importedEntry struct {
+	cards   []*DBCard
+	note    *DBNote // nil for a standalone card
+	matched bool
+}
+
+// importedIndex looks up imported entries by a matching key
+type importedIndex map[string][]*importedEntry
+
+func (idx importedIndex) add(key string, entry *importedEntry) {
+	idx[key] = append(idx[key], entry)
+}
+
+// take returns the first entry under key that has not been matched yet and
+// marks it as matched
+func (idx importedIndex) take(key string) *importedEntry {
+	for _, entry := range idx[key] {
+		if !entry.matched {
+			entry.matched = true
+			return entry
+		}
+	}
+	return nil
+}
+
+func contentKey(noteType, first, second string) string {
+	return noteType + "\x00" + first + "\x00" + second
+}
+
+func lineKey(noteType string, line int) string {
+	return noteType + "\x00" + strconv.Itoa(line)
+}
+
+// syncPending writes the cards parsed from the current file to the database.
+//
+// Cards that an earlier import of the same file created are matched and
+// updated in place so they keep their review history: first by identical
+// content, then (for single cards) by an unchanged question or answer, and
+// finally by the line they are on. This makes fixing a typo or moving a card
+// around an edit rather than a new card. Imported cards that match nothing
+// are reported as removed. Database errors are reported per card; the only
+// error returned is ErrImportCancelled.
+func (cp *CardParser) syncPending() error {
+	pending := cp.pending
+	cp.pending = nil
+
+	cp.checkImages(pending)
+
+	if cp.cardRepo == nil {
+		return nil
+	}
+
+	imported, err := cp.loadImported()
+	if err != nil {
+		cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+			Reason: fmt.Sprintf("Failed to load previously imported cards: %v", err),
+		})
+		imported = nil
+	}
+
+	byContent := make(importedIndex)
+	byQuestion := make(importedIndex)
+	byAnswer := make(importedIndex)
+	byLine := make(importedIndex)
+	for _, entry := range imported {
+		first := entry.cards[0]
+		if entry.note != nil {
+			byContent.add(contentKey(entry.note.NoteType, entry.note.Content, ""), entry)
+			byLine.add(lineKey(entry.note.NoteType, first.SourceLine), entry)
+			continue
+		}
+		byContent.add(contentKey("", first.Question, first.Answer), entry)
+		byQuestion.add(first.Question, entry)
+		byAnswer.add(first.Answer, entry)
+		byLine.add(lineKey("", first.SourceLine), entry)
+	}
+
+	for _, p := range pending {
+		if p.noteType != "" {
+			p.match = byContent.take(contentKey(p.noteType, p.content, ""))
+		} else {
+			p.match = byContent.take(contentKey("", p.cards[0].Question, p.cards[0].Answer))
+		}
+	}
+	for _, p := range pending {
+		if p.match == nil && p.noteType == "" {
+			if p.match = byQuestion.take(p.cards[0].Question); p.match == nil {
+				p.match = byAnswer.take(p.cards[0].Answer)
+			}
+		}
+	}
+	for _, p := range pending {
+		if p.match == nil {
+			p.match = byLine.take(lineKey(p.noteType, p.lineNum))
+		}
+	}
+
+	for i, p := range pending {
+		if err := cp.checkImport(i, len(pending)); err != nil {
+			return err
+		}
+
+		var err error
+		if p.match != nil {
+			err = cp.updateImported(p)
+		} else {
+			err = cp.createImported(p)
+		}
+		if err != nil {
+			cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+				LineNum: p.lineNum,
+				Line:    p.line,
+				Reason:  fmt.Sprintf("Database import failed: %v", err),
+			})
+		}
+	}
+
+	if err := cp.importMedia(); err != nil {
+		cp.parseResult.Errors = append(cp.parseResult.Errors, ParseError{
+			Reason: fmt.Sprintf("Failed to copy images: %v", err),
+		})
+	}
+
+	for _, entry := range imported {
+		if entry.matched {
+			continue
+		}
+		for _, dbCard := range entry.cards {
+			// Already archived on an earlier reload
+			if dbCard.Status == CardStatusArchived {
+				continue
+			}
+			card := Card{
+				ID:       dbCard.ID,
+				Question: dbCard.Question,
+				Answer:   dbCard.Answer,
+				FilePath: dbCard.SourceFile,
+				LineNum:  dbCard.SourceLine,
+				NoteID:   dbCard.NoteID.Int64,
+				Ordinal:  dbCard.Ordinal,
+				Status:   dbCard.Status,
+			}
+			cp.parseResult.Removed = append(cp.parseResult.Removed, card)
+		}
+	}
+
+	return cp.checkImport(len(pending), len(pending))
+}
+
+// loadImported returns the cards and notes earlier imports of the current
+// file stored, grouping the cards of each note together
+func (cp *CardParser) loadImported() ([]*importedEntry, error) {
+	dbCards, err := cp.cardRepo.GetBySourceFile(cp.currentFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*importedEntry
+	notes := make(map[int64]*importedEntry)
+	for _, dbCard := range dbCards {
+		// Drafts in the inbox were never part of the file
+		if dbCard.Status == CardStatusDraft {
+			continue
+		}
+		if !dbCard.NoteID.Valid {
+			entries = append(entries, &importedEntry{cards: []*DBCard{dbCard}})
+			continue
+		}
+
+		if entry, ok := notes[dbCard.NoteID.Int64]; ok {
+			entry.cards = append(entry.cards, dbCard)
+			continue
+		}
+		if cp.noteRepo == nil {
+			continue
+		}
+		note, err := cp.noteRepo.GetByID(dbCard.NoteID.Int64)
+		if err != nil {
+			return nil, err
+		}
+		entry := &importedEntry{cards: []*DBCard{dbCard}, note: note}
+		notes[note.ID] = entry
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// createImported stores an entry that matched no earlier import, unless the
+// same card or note already exists (for example from another file)
+func (cp *CardParser) createImported(p *parsedEntry) error {
+	if p.noteType != "" {
+		if cp.noteRepo == nil {
+			return nil
+		}
+		exists, err := cp.noteRepo.NoteExists(p.noteType, p.content)
+		if err != nil {
+			return fmt.Errorf("failed to check note existence: %w", err)
+		}
+		if exists {
+			return nil
+		}
+		_, err = createNoteCards(cp.cardRepo, cp.noteRepo, p.noteType, p.content, p.cards)
+		return err
+	}
+
+	card := p.cards[0]
+	exists, err := cp.cardRepo.CardExists(card.Question, card.Answer)
+	if err != nil {
+		return fmt.Errorf("failed to check card existence: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	dbCard := &DBCard{
+		Question:      card.Question,
+		Answer:        card.Answer,
+		SourceFile:    card.FilePath,
+		SourceLine:    card.LineNum,
+		SourceContext: sql.NullString{String: card.SourceContext, Valid: card.SourceContext != ""},
+		PromptType:    card.PromptType,
+		Tags:          card.Tags,
+		DeckID:        sql.NullInt64{Int64: card.DeckID, Valid: card.DeckID != 0},
+	}
+	return cp.cardRepo.Create(dbCard)
+}
+
+// updateImported brings the matched cards in line with the file, keeping
+// their IDs and review state
+func (cp *CardParser) updateImported(p *parsedEntry) error {
+	dbCards := p.match.cards
+	edited := false
+
+	if p.match.note != nil && p.match.note.Content != p.content {
+		// Regenerates the siblings; ones that no longer exist are deleted
+		// together with their review state
+		if _, err := cp.updateNote(p.match.note.ID, p.content); err != nil {
+			return err
+		}
+		var err error
+		if dbCards, err = cp.cardRepo.GetByNoteID(p.match.note.ID); err != nil {
+			return fmt.Errorf("failed to get cards of note: %w", err)
+		}
+		edited = true
+	}
+
+	if p.match.note == nil {
+		card := p.cards[0]
+		if dbCards[0].Question != card.Question || dbCards[0].Answer != card.Answer {
+			dbCards[0].Question = card.Question
+			dbCards[0].Answer = card.Answer
+			edited = true
+		}
+	}
+
+	source := sql.NullString{String: p.meta.SourceContext, Valid: p.meta.SourceContext != ""}
+	deckID := sql.NullInt64{Int64: p.meta.DeckID, Valid: p.meta.DeckID != 0}
+	promptType := p.meta.PromptType
+	if promptType == "" {
+		promptType = "factual"
+	}
+
+	for _, dbCard := range dbCards {
+		metaChanged := dbCard.SourceContext != source || dbCard.PromptType != promptType ||
+			dbCard.Tags != cleanTags(p.meta.Tags) || dbCard.DeckID != deckID || dbCard.Status != CardStatusActive
+		if metaChanged {
+			edited = true
+		}
+		if !metaChanged && !edited && dbCard.SourceLine == p.lineNum {
+			continue
+		}
+
+		dbCard.SourceLine = p.lineNum
+		dbCard.SourceContext = source
+		dbCard.PromptType = promptType
+		dbCard.Tags = p.meta.Tags
+		dbCard.DeckID = deckID
+		// A card that was archived comes back when it reappears in the file
+		dbCard.Status = CardStatusActive
+		if err := cp.cardRepo.Update(dbCard); err != nil {
+			return err
+		}
+	}
+
+	if edited {
+		cp.parseResult.Updated++
+	}
+	return nil
+}
+
+// ArchiveCards marks cards as archived. They stay in the database with their
+// review history but are no longer due.
+func (cp *CardParser) ArchiveCards(cardIDs []int64) error {
+	if cp.cardRepo == nil {
+		return fmt.Errorf("no database repository available")
+	}
+
+	for _, cardID := range cardIDs {
+		card, err := cp.cardRepo.GetByID(cardID)
+		if err != nil {
+			return fmt.Errorf("failed to get card: %w", err)
+		}
+		card.Status = CardStatusArchived
+		if err := cp.cardRepo.Update(card); err != nil {
+			return fmt.Errorf("failed to archive card: %w", err)
+		}
+	}
+
+	return nil
+}
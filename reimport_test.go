@@ -0,0 +1,221 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// reloadCards writes content to a card file and loads it with cp
+func reloadCards(t *testing.T, cp *CardParser, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if cp.HasParseErrors() {
+		t.Fatalf("parse errors: %s", cp.GetParseReport())
+	}
+}
+
+// storedCards returns the cards stored for a file by question
+func storedCards(t *testing.T, db *Database, path string) map[string]*DBCard {
+	t.Helper()
+	dbCards, err := NewSQLiteCardRepository(db).GetBySourceFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards := make(map[string]*DBCard)
+	for _, dbCard := range dbCards {
+		cards[dbCard.Question] = dbCard
+	}
+	return cards
+}
+
+func TestReimportMatching(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		// Active cards after the reload by question, with the question the
+		// card had before or "" for a new card. Removed cards stay active
+		// until they are archived.
+		kept    map[string]string
+		removed []string
+		updated int
+	}{
+		{
+			name:    "edit question",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Q1 fixed>>A1\nQ2>>A2\n",
+			kept:    map[string]string{"Q1 fixed": "Q1", "Q2": "Q2"},
+			updated: 1,
+		},
+		{
+			name:    "edit answer",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Q1>>A1 fixed\nQ2>>A2\n",
+			kept:    map[string]string{"Q1": "Q1", "Q2": "Q2"},
+			updated: 1,
+		},
+		{
+			name:   "reorder lines",
+			before: "Q1>>A1\nQ2>>A2\nQ3>>A3\n",
+			after:  "Q3>>A3\nQ1>>A1\nQ2>>A2\n",
+			kept:   map[string]string{"Q1": "Q1", "Q2": "Q2", "Q3": "Q3"},
+		},
+		{
+			name:    "delete card",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Q2>>A2\n",
+			kept:    map[string]string{"Q1": "Q1", "Q2": "Q2"},
+			removed: []string{"Q1"},
+		},
+		{
+			name:   "add card",
+			before: "Q1>>A1\n",
+			after:  "Q0>>A0\nQ1>>A1\n",
+			kept:   map[string]string{"Q0": "", "Q1": "Q1"},
+		},
+		{
+			name:    "content is matched before question",
+			before:  "Same>>first\nOther>>second\n",
+			after:   "Other>>second\nSame>>first, edited\n",
+			kept:    map[string]string{"Other": "Other", "Same": "Same"},
+			updated: 1,
+		},
+		{
+			name:    "question is matched before line",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Q2>>B2\nQ1>>B1\n",
+			kept:    map[string]string{"Q1": "Q1", "Q2": "Q2"},
+			updated: 2,
+		},
+		{
+			name:    "answer is matched before line",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "R2>>A2\nR1>>A1\n",
+			kept:    map[string]string{"R1": "Q1", "R2": "Q2"},
+			updated: 2,
+		},
+		{
+			name:    "line is matched last",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Rewritten>>entirely\nQ2>>A2\n",
+			kept:    map[string]string{"Rewritten": "Q1", "Q2": "Q2"},
+			updated: 1,
+		},
+		{
+			name:    "rewritten and moved card is new",
+			before:  "Q1>>A1\nQ2>>A2\n",
+			after:   "Q2>>A2\n\nRewritten>>entirely\n",
+			kept:    map[string]string{"Q1": "Q1", "Q2": "Q2", "Rewritten": ""},
+			removed: []string{"Q1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			path := filepath.Join(t.TempDir(), "cards.txt")
+			cp := newTestParser(db)
+			reloadCards(t, cp, path, test.before)
+
+			questions := make(map[int64]string)
+			for question, dbCard := range storedCards(t, db, path) {
+				questions[dbCard.ID] = question
+			}
+
+			reloadCards(t, cp, path, test.after)
+
+			kept := make(map[string]string)
+			for question, dbCard := range storedCards(t, db, path) {
+				if dbCard.Status == CardStatusActive {
+					kept[question] = questions[dbCard.ID]
+				}
+			}
+			if !reflect.DeepEqual(kept, test.kept) {
+				t.Errorf("cards after reload = %q, want %q", kept, test.kept)
+			}
+
+			var removed []string
+			for _, card := range cp.GetParseResult().Removed {
+				removed = append(removed, card.Question)
+			}
+			if !reflect.DeepEqual(removed, test.removed) {
+				t.Errorf("removed = %q, want %q", removed, test.removed)
+			}
+			if updated := cp.GetParseResult().Updated; updated != test.updated {
+				t.Errorf("updated = %d, want %d", updated, test.updated)
+			}
+		})
+	}
+}
+
+func TestReimportArchiveAndRevive(t *testing.T) {
+	db := newTestDatabase(t)
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	cp := newTestParser(db)
+	reloadCards(t, cp, path, "Q1>>A1\nQ2>>A2\n")
+	id := storedCards(t, db, path)["Q1"].ID
+
+	// A card deleted from the file is reported until it is archived
+	reloadCards(t, cp, path, "Q2>>A2\n")
+	removed := cp.GetParseResult().Removed
+	if len(removed) != 1 || removed[0].ID != id {
+		t.Fatalf("removed = %+v, want card %d", removed, id)
+	}
+	if err := cp.ArchiveCards([]int64{id}); err != nil {
+		t.Fatalf("ArchiveCards: %v", err)
+	}
+	if status := storedCards(t, db, path)["Q1"].Status; status != CardStatusArchived {
+		t.Errorf("status after archiving = %q, want %q", status, CardStatusArchived)
+	}
+
+	reloadCards(t, cp, path, "Q2>>A2\n")
+	if removed := cp.GetParseResult().Removed; len(removed) != 0 {
+		t.Errorf("archived card reported as removed again: %+v", removed)
+	}
+
+	// Putting it back in the file revives the archived card
+	reloadCards(t, cp, path, "Q2>>A2\nQ1>>A1\n")
+	dbCard := storedCards(t, db, path)["Q1"]
+	if dbCard.ID != id || dbCard.Status != CardStatusActive || dbCard.SourceLine != 2 {
+		t.Errorf("card after reappearing = %+v, want card %d active on line 2", dbCard, id)
+	}
+	if cards := countRows(t, db, "cards"); cards != 2 {
+		t.Errorf("%d cards stored, want 2", cards)
+	}
+}
+
+func TestReimportNote(t *testing.T) {
+	db := newTestDatabase(t)
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	cp := newTestParser(db)
+	reloadCards(t, cp, path, "{{c1::Go}} was announced in {{c2::2009}}\n")
+	before := storedCards(t, db, path)
+
+	// Editing a note keeps the cards of the deletions that are left
+	reloadCards(t, cp, path, "{{c1::Go}} was announced in 2009\n")
+	after := storedCards(t, db, path)
+	if len(after) != 1 {
+		t.Fatalf("cards after removing a deletion = %d, want 1", len(after))
+	}
+	for question, dbCard := range after {
+		if dbCard.ID != before["[...] was announced in 2009"].ID {
+			t.Errorf("card %q has ID %d, want the ID of the first deletion's card", question, dbCard.ID)
+		}
+	}
+	if updated := cp.GetParseResult().Updated; updated != 1 {
+		t.Errorf("updated = %d, want 1", updated)
+	}
+}
+
+func TestArchiveCardsWithoutDatabase(t *testing.T) {
+	if err := NewCardParser().ArchiveCards([]int64{1}); err == nil {
+		t.Error("ArchiveCards without a database succeeded")
+	}
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/open-spaced-repetition/go-fsrs/v3"
@@ -16,8 +18,36 @@ type CardRepository interface {
 	GetAll() ([]*DBCard, error)
 	Update(card *DBCard) error
 	Delete(id int64) error
-	ImportFromText(question, answer, sourceFile string, sourceLine int) (*DBCard, error)
+	ImportFromText(question, answer, sourceFile string, sourceLine int, sourceContext, promptType, tags string) (*DBCard, error)
 	CardExists(question, answer string) (bool, error)
+	GetByNoteID(noteID int64) ([]*DBCard, error)
+	GetBySourceFile(sourceFile string) ([]*DBCard, error)
+	GetByStatus(status string) ([]*DBCard, error)
+	GetByDeckID(deckID int64) ([]*DBCard, error)
+	GetByTag(name string) ([]*DBCard, error)
+}
+
+type NoteRepository interface {
+	Create(note *DBNote) error
+	GetByID(id int64) (*DBNote, error)
+	Update(note *DBNote) error
+	Delete(id int64) error
+	NoteExists(noteType, content string) (bool, error)
+}
+
+type DeckRepository interface {
+	Create(deck *DBDeck) error
+	GetByID(id int64) (*DBDeck, error)
+	GetByName(name string) (*DBDeck, error)
+	GetAll() ([]*DBDeck, error)
+	Update(deck *DBDeck) error
+}
+
+type TagRepository interface {
+	GetAll() ([]*DBTag, error)
+	GetByName(name string) (*DBTag, error)
+	Update(tag *DBTag) error
+	GetCardCounts() (map[int64]int, error)
 }
 
 type ReviewStateRepository interface {
@@ -28,6 +58,19 @@ type ReviewStateRepository interface {
 	GetDueCards() ([]*DBReviewState, error)
 }
 
+type ReviewLogRepository interface {
+	Create(entry *DBReviewLog) error
+	GetByCardID(cardID int64) ([]*DBReviewLog, error)
+	GetDateRange(start, end time.Time) ([]*DBReviewLog, error)
+}
+
+type MediaRepository interface {
+	Save(media *DBMedia) error
+	GetByCardID(cardID int64) ([]*DBMedia, error)
+	GetAll() ([]*DBMedia, error)
+	Delete(id int64) error
+}
+
 type SessionRepository interface {
 	Create(session *DBSession) error
 	GetByID(id int64) (*DBSession, error)
@@ -46,6 +89,27 @@ type DailyStatsRepository interface {
 }
 
 // SQLite implementations
+
+// Columns selected for every card query, in the order scanCard expects them
+const cardColumns = `id, question, answer, source_file, source_line, source_context, prompt_type, tags,
+			  note_id, ordinal, deck_id, status, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCard(row rowScanner) (*DBCard, error) {
+	card := &DBCard{}
+	err := row.Scan(&card.ID, &card.Question, &card.Answer, &card.SourceFile,
+		&card.SourceLine, &card.SourceContext, &card.PromptType, &card.Tags,
+		&card.NoteID, &card.Ordinal, &card.DeckID, &card.Status, &card.CreatedAt, &card.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
 type SQLiteCardRepository struct {
 	db *Database
 }
@@ -55,8 +119,9 @@ func NewSQLiteCardRepository(db *Database) *SQLiteCardRepository {
 }
 
 func (r *SQLiteCardRepository) Create(card *DBCard) error {
-	query := `INSERT INTO cards (question, answer, source_file, source_line, source_context, prompt_type, tags, created_at, updated_at)
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO cards (question, answer, source_file, source_line, source_context, prompt_type, tags,
+			  note_id, ordinal, deck_id, status, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	now := time.Now()
 	card.CreatedAt = now
@@ -66,9 +131,14 @@ func (r *SQLiteCardRepository) Create(card *DBCard) error {
 	if card.PromptType == "" {
 		card.PromptType = "factual"
 	}
+	if card.Status == "" {
+		card.Status = CardStatusActive
+	}
+	// The tag list matches the tags linked in card_tags
+	card.Tags = cleanTags(card.Tags)
 
-	result, err := r.db.db.Exec(query, card.Question, card.Answer, card.SourceFile, card.SourceLine,
-								card.SourceContext, card.PromptType, card.Tags, now, now)
+	result, err := r.db.conn().Exec(query, card.Question, card.Answer, card.SourceFile, card.SourceLine,
+								card.SourceContext, card.PromptType, card.Tags, card.NoteID, card.Ordinal, card.DeckID, card.Status, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to create card: %w", err)
 	}
@@ -79,19 +149,16 @@ func (r *SQLiteCardRepository) Create(card *DBCard) error {
 	}
 
 	card.ID = id
-	return nil
+	return saveCardTags(r.db.conn(), card.ID, card.Tags)
 }
 
 func (r *SQLiteCardRepository) GetByID(id int64) (*DBCard, error) {
-	query := `SELECT id, question, answer, source_file, source_line, source_context, prompt_type, tags, created_at, updated_at
+	query := `SELECT ` + cardColumns + `
 			  FROM cards WHERE id = ?`
 
-	row := r.db.db.QueryRow(query, id)
+	row := r.db.conn().QueryRow(query, id)
 
-	card := &DBCard{}
-	err := row.Scan(&card.ID, &card.Question, &card.Answer, &card.SourceFile,
-					&card.SourceLine, &card.SourceContext, &card.PromptType, &card.Tags,
-					&card.CreatedAt, &card.UpdatedAt)
+	card, err := scanCard(row)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get card: %w", err)
 	}
@@ -100,10 +167,59 @@ func (r *SQLiteCardRepository) GetByID(id int64) (*DBCard, error) {
 }
 
 func (r *SQLiteCardRepository) GetAll() ([]*DBCard, error) {
-	query := `SELECT id, question, answer, source_file, source_line, source_context, prompt_type, tags, created_at, updated_at
+	query := `SELECT ` + cardColumns + `
 			  FROM cards ORDER BY created_at ASC`
 
-	rows, err := r.db.db.Query(query)
+	return r.queryCards(query)
+}
+
+func (r *SQLiteCardRepository) GetByNoteID(noteID int64) ([]*DBCard, error) {
+	query := `SELECT ` + cardColumns + `
+			  FROM cards WHERE note_id = ? ORDER BY ordinal ASC`
+
+	return r.queryCards(query, noteID)
+}
+
+func (r *SQLiteCardRepository) GetBySourceFile(sourceFile string) ([]*DBCard, error) {
+	query := `SELECT ` + cardColumns + `
+			  FROM cards WHERE source_file = ? ORDER BY source_line ASC, ordinal ASC`
+
+	return r.queryCards(query, sourceFile)
+}
+
+func (r *SQLiteCardRepository) GetByStatus(status string) ([]*DBCard, error) {
+	query := `SELECT ` + cardColumns + `
+			  FROM cards WHERE status = ? ORDER BY created_at ASC`
+
+	return r.queryCards(query, status)
+}
+
+// GetByDeckID returns the cards of a deck and of the decks nested in it
+func (r *SQLiteCardRepository) GetByDeckID(deckID int64) ([]*DBCard, error) {
+	query := `WITH RECURSIVE deck_tree(id) AS (
+				  SELECT ? UNION SELECT decks.id FROM decks JOIN deck_tree ON decks.parent_id = deck_tree.id
+			  )
+			  SELECT ` + cardColumns + `
+			  FROM cards WHERE deck_id IN (SELECT id FROM deck_tree) ORDER BY created_at ASC`
+
+	return r.queryCards(query, deckID)
+}
+
+// GetByTag returns the cards that have a tag or a tag nested in it
+func (r *SQLiteCardRepository) GetByTag(name string) ([]*DBCard, error) {
+	query := `WITH RECURSIVE tag_tree(id) AS (
+				  SELECT id FROM tags WHERE name = ?
+				  UNION SELECT tags.id FROM tags JOIN tag_tree ON tags.parent_id = tag_tree.id
+			  )
+			  SELECT ` + cardColumns + `
+			  FROM cards WHERE id IN (SELECT card_id FROM card_tags WHERE tag_id IN (SELECT id FROM tag_tree))
+			  ORDER BY created_at ASC`
+
+	return r.queryCards(query, name)
+}
+
+func (r *SQLiteCardRepository) queryCards(query string, args ...interface{}) ([]*DBCard, error) {
+	rows, err := r.db.conn().Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query cards: %w", err)
 	}
@@ -111,10 +227,7 @@ func (r *SQLiteCardRepository) GetAll() ([]*DBCard, error) {
 
 	var cards []*DBCard
 	for rows.Next() {
-		card := &DBCard{}
-		err := rows.Scan(&card.ID, &card.Question, &card.Answer, &card.SourceFile,
-						&card.SourceLine, &card.SourceContext, &card.PromptType, &card.Tags,
-						&card.CreatedAt, &card.UpdatedAt)
+		card, err := scanCard(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan card: %w", err)
 		}
@@ -126,40 +239,43 @@ func (r *SQLiteCardRepository) GetAll() ([]*DBCard, error) {
 
 func (r *SQLiteCardRepository) Update(card *DBCard) error {
 	query := `UPDATE cards SET question = ?, answer = ?, source_file = ?,
-			  source_line = ?, source_context = ?, prompt_type = ?, tags = ?, updated_at = ? WHERE id = ?`
+			  source_line = ?, source_context = ?, prompt_type = ?, tags = ?,
+			  note_id = ?, ordinal = ?, deck_id = ?, status = ?, updated_at = ? WHERE id = ?`
 
 	card.UpdatedAt = time.Now()
+	// The tag list matches the tags linked in card_tags
+	card.Tags = cleanTags(card.Tags)
 
-	_, err := r.db.db.Exec(query, card.Question, card.Answer, card.SourceFile,
+	_, err := r.db.conn().Exec(query, card.Question, card.Answer, card.SourceFile,
 						   card.SourceLine, card.SourceContext, card.PromptType, card.Tags,
-						   card.UpdatedAt, card.ID)
+						   card.NoteID, card.Ordinal, card.DeckID, card.Status, card.UpdatedAt, card.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update card: %w", err)
 	}
 
-	return nil
+	return saveCardTags(r.db.conn(), card.ID, card.Tags)
 }
 
 func (r *SQLiteCardRepository) Delete(id int64) error {
 	query := `DELETE FROM cards WHERE id = ?`
 
-	_, err := r.db.db.Exec(query, id)
+	_, err := r.db.conn().Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete card: %w", err)
 	}
 
-	return nil
+	return deleteUnusedTags(r.db.conn())
 }
 
-func (r *SQLiteCardRepository) ImportFromText(question, answer, sourceFile string, sourceLine int) (*DBCard, error) {
+func (r *SQLiteCardRepository) ImportFromText(question, answer, sourceFile string, sourceLine int, sourceContext, promptType, tags string) (*DBCard, error) {
 	card := &DBCard{
 		Question:      question,
 		Answer:        answer,
 		SourceFile:    sourceFile,
 		SourceLine:    sourceLine,
-		SourceContext: sql.NullString{String: "", Valid: false}, // Will be empty for imported text files
-		PromptType:    "factual",
-		Tags:          "",
+		SourceContext: sql.NullString{String: sourceContext, Valid: sourceContext != ""},
+		PromptType:    promptType, // Create falls back to "factual" when empty
+		Tags:          tags,
 	}
 
 	err := r.Create(card)
@@ -170,11 +286,13 @@ func (r *SQLiteCardRepository) ImportFromText(question, answer, sourceFile strin
 	return card, nil
 }
 
+// CardExists reports whether a card with this text exists. Drafts in the
+// inbox do not count.
 func (r *SQLiteCardRepository) CardExists(question, answer string) (bool, error) {
-	query := `SELECT COUNT(*) FROM cards WHERE question = ? AND answer = ?`
+	query := `SELECT COUNT(*) FROM cards WHERE question = ? AND answer = ? AND status IS NOT ?`
 
 	var count int
-	err := r.db.db.QueryRow(query, question, answer).Scan(&count)
+	err := r.db.conn().QueryRow(query, question, answer, CardStatusDraft).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if card exists: %w", err)
 	}
@@ -182,6 +300,432 @@ func (r *SQLiteCardRepository) CardExists(question, answer string) (bool, error)
 	return count > 0, nil
 }
 
+// SQLite Note Repository
+type SQLiteNoteRepository struct {
+	db *Database
+}
+
+func NewSQLiteNoteRepository(db *Database) *SQLiteNoteRepository {
+	return &SQLiteNoteRepository{db: db}
+}
+
+func (r *SQLiteNoteRepository) Create(note *DBNote) error {
+	query := `INSERT INTO notes (note_type, content, created_at, updated_at)
+			  VALUES (?, ?, ?, ?)`
+
+	now := time.Now()
+	note.CreatedAt = now
+	note.UpdatedAt = now
+
+	result, err := r.db.conn().Exec(query, note.NoteType, note.Content, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	note.ID = id
+	return nil
+}
+
+func (r *SQLiteNoteRepository) GetByID(id int64) (*DBNote, error) {
+	query := `SELECT id, note_type, content, created_at, updated_at FROM notes WHERE id = ?`
+
+	note := &DBNote{}
+	err := r.db.conn().QueryRow(query, id).Scan(&note.ID, &note.NoteType, &note.Content,
+		&note.CreatedAt, &note.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	return note, nil
+}
+
+func (r *SQLiteNoteRepository) Update(note *DBNote) error {
+	query := `UPDATE notes SET note_type = ?, content = ?, updated_at = ? WHERE id = ?`
+
+	note.UpdatedAt = time.Now()
+
+	_, err := r.db.conn().Exec(query, note.NoteType, note.Content, note.UpdatedAt, note.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteNoteRepository) Delete(id int64) error {
+	query := `DELETE FROM notes WHERE id = ?`
+
+	_, err := r.db.conn().Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteNoteRepository) NoteExists(noteType, content string) (bool, error) {
+	query := `SELECT COUNT(*) FROM notes WHERE note_type = ? AND content = ?`
+
+	var count int
+	err := r.db.conn().QueryRow(query, noteType, content).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if note exists: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// SQLite Deck Repository
+type SQLiteDeckRepository struct {
+	db *Database
+}
+
+func NewSQLiteDeckRepository(db *Database) *SQLiteDeckRepository {
+	return &SQLiteDeckRepository{db: db}
+}
+
+const deckColumns = `id, name, description, settings, text_format, parent_id, created_at, updated_at`
+
+func scanDeck(row rowScanner) (*DBDeck, error) {
+	deck := &DBDeck{}
+	var settings, textFormat sql.NullString
+	err := row.Scan(&deck.ID, &deck.Name, &deck.Description, &settings, &textFormat,
+		&deck.ParentID, &deck.CreatedAt, &deck.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	deck.Settings = settings.String
+	deck.TextFormat = textFormat.String
+	return deck, nil
+}
+
+func (r *SQLiteDeckRepository) Create(deck *DBDeck) error {
+	query := `INSERT INTO decks (name, description, settings, text_format, parent_id, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	deck.CreatedAt = now
+	deck.UpdatedAt = now
+
+	if deck.TextFormat == "" {
+		deck.TextFormat = TextFormatMarkdown
+	}
+
+	result, err := r.db.conn().Exec(query, deck.Name, deck.Description, deck.Settings, deck.TextFormat, deck.ParentID, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to create deck: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	deck.ID = id
+	return nil
+}
+
+func (r *SQLiteDeckRepository) GetByID(id int64) (*DBDeck, error) {
+	query := `SELECT ` + deckColumns + ` FROM decks WHERE id = ?`
+
+	deck, err := scanDeck(r.db.conn().QueryRow(query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deck: %w", err)
+	}
+
+	return deck, nil
+}
+
+func (r *SQLiteDeckRepository) GetByName(name string) (*DBDeck, error) {
+	query := `SELECT ` + deckColumns + ` FROM decks WHERE name = ?`
+
+	deck, err := scanDeck(r.db.conn().QueryRow(query, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deck %q: %w", name, err)
+	}
+
+	return deck, nil
+}
+
+func (r *SQLiteDeckRepository) GetAll() ([]*DBDeck, error) {
+	query := `SELECT ` + deckColumns + ` FROM decks ORDER BY name ASC`
+
+	rows, err := r.db.conn().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decks: %w", err)
+	}
+	defer rows.Close()
+
+	var decks []*DBDeck
+	for rows.Next() {
+		deck, err := scanDeck(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deck: %w", err)
+		}
+		decks = append(decks, deck)
+	}
+
+	return decks, nil
+}
+
+func (r *SQLiteDeckRepository) Update(deck *DBDeck) error {
+	query := `UPDATE decks SET name = ?, description = ?, settings = ?, text_format = ?, parent_id = ?, updated_at = ? WHERE id = ?`
+
+	deck.UpdatedAt = time.Now()
+
+	_, err := r.db.conn().Exec(query, deck.Name, deck.Description, deck.Settings, deck.TextFormat, deck.ParentID, deck.UpdatedAt, deck.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update deck: %w", err)
+	}
+
+	return nil
+}
+
+// SQLite Media Repository
+type SQLiteMediaRepository struct {
+	db *Database
+}
+
+func NewSQLiteMediaRepository(db *Database) *SQLiteMediaRepository {
+	return &SQLiteMediaRepository{db: db}
+}
+
+// Save stores the file a card's reference points to, replacing the file it
+// pointed to before
+func (r *SQLiteMediaRepository) Save(media *DBMedia) error {
+	query := `INSERT INTO media (card_id, reference, file_name, created_at) VALUES (?, ?, ?, ?)
+			  ON CONFLICT (card_id, reference) DO UPDATE SET file_name = excluded.file_name`
+
+	media.CreatedAt = time.Now()
+
+	_, err := r.db.conn().Exec(query, media.CardID, media.Reference, media.FileName, media.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save media: %w", err)
+	}
+
+	err = r.db.conn().QueryRow(`SELECT id FROM media WHERE card_id = ? AND reference = ?`,
+		media.CardID, media.Reference).Scan(&media.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get media id: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteMediaRepository) GetByCardID(cardID int64) ([]*DBMedia, error) {
+	return r.queryMedia(`SELECT id, card_id, reference, file_name, created_at FROM media WHERE card_id = ?`, cardID)
+}
+
+func (r *SQLiteMediaRepository) GetAll() ([]*DBMedia, error) {
+	return r.queryMedia(`SELECT id, card_id, reference, file_name, created_at FROM media ORDER BY id`)
+}
+
+func (r *SQLiteMediaRepository) queryMedia(query string, args ...interface{}) ([]*DBMedia, error) {
+	rows, err := r.db.conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	defer rows.Close()
+
+	var media []*DBMedia
+	for rows.Next() {
+		m := &DBMedia{}
+		if err := rows.Scan(&m.ID, &m.CardID, &m.Reference, &m.FileName, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan media: %w", err)
+		}
+		media = append(media, m)
+	}
+
+	return media, nil
+}
+
+func (r *SQLiteMediaRepository) Delete(id int64) error {
+	query := `DELETE FROM media WHERE id = ?`
+
+	_, err := r.db.conn().Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete media: %w", err)
+	}
+
+	return nil
+}
+
+// saveCardTags links a card to the tags in its comma-separated tag list,
+// creating the tags that do not exist yet and dropping links to tags the
+// card no longer has
+func saveCardTags(conn dbConn, cardID int64, tags string) error {
+	var tagIDs []interface{}
+	for _, name := range splitTags(tags) {
+		tagID, err := ensureTag(conn, name)
+		if err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tagID)
+	}
+
+	query := `DELETE FROM card_tags WHERE card_id = ?`
+	if len(tagIDs) > 0 {
+		query += ` AND tag_id NOT IN (?` + strings.Repeat(`, ?`, len(tagIDs)-1) + `)`
+	}
+	result, err := conn.Exec(query, append([]interface{}{cardID}, tagIDs...)...)
+	if err != nil {
+		return fmt.Errorf("failed to unlink card tags: %w", err)
+	}
+
+	for _, tagID := range tagIDs {
+		if _, err := conn.Exec(`INSERT OR IGNORE INTO card_tags (card_id, tag_id) VALUES (?, ?)`, cardID, tagID); err != nil {
+			return fmt.Errorf("failed to link card tag: %w", err)
+		}
+	}
+
+	if removed, _ := result.RowsAffected(); removed > 0 {
+		return deleteUnusedTags(conn)
+	}
+	return nil
+}
+
+// ensureTag returns the ID of the named tag, creating it and the tags it is
+// nested in when they do not exist yet
+func ensureTag(conn dbConn, name string) (int64, error) {
+	var tagID int64
+	err := conn.QueryRow(`SELECT id FROM tags WHERE name = ?`, name).Scan(&tagID)
+	if err == nil {
+		return tagID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to get tag %q: %w", name, err)
+	}
+
+	var parentID sql.NullInt64
+	if parent := parentTagName(name); parent != "" {
+		id, err := ensureTag(conn, parent)
+		if err != nil {
+			return 0, err
+		}
+		parentID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	result, err := conn.Exec(`INSERT INTO tags (name, parent_id, created_at) VALUES (?, ?, ?)`, name, parentID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tag %q: %w", name, err)
+	}
+	return result.LastInsertId()
+}
+
+// deleteUnusedTags removes the tags that no card has and no other tag is
+// nested in, until the parents this leaves empty are gone as well
+func deleteUnusedTags(conn dbConn) error {
+	query := `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM card_tags)
+			  AND id NOT IN (SELECT parent_id FROM tags WHERE parent_id IS NOT NULL)`
+
+	for {
+		result, err := conn.Exec(query)
+		if err != nil {
+			return fmt.Errorf("failed to delete unused tags: %w", err)
+		}
+		if removed, _ := result.RowsAffected(); removed == 0 {
+			return nil
+		}
+	}
+}
+
+// SQLite Tag Repository
+type SQLiteTagRepository struct {
+	db *Database
+}
+
+func NewSQLiteTagRepository(db *Database) *SQLiteTagRepository {
+	return &SQLiteTagRepository{db: db}
+}
+
+const tagColumns = `id, name, parent_id, created_at`
+
+func scanTag(row rowScanner) (*DBTag, error) {
+	tag := &DBTag{}
+	if err := row.Scan(&tag.ID, &tag.Name, &tag.ParentID, &tag.CreatedAt); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// GetAll returns all tags ordered by name
+func (r *SQLiteTagRepository) GetAll() ([]*DBTag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags ORDER BY name ASC`
+
+	rows, err := r.db.conn().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*DBTag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+func (r *SQLiteTagRepository) GetByName(name string) (*DBTag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags WHERE name = ?`
+
+	tag, err := scanTag(r.db.conn().QueryRow(query, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag %q: %w", name, err)
+	}
+
+	return tag, nil
+}
+
+func (r *SQLiteTagRepository) Update(tag *DBTag) error {
+	query := `UPDATE tags SET name = ?, parent_id = ? WHERE id = ?`
+
+	_, err := r.db.conn().Exec(query, tag.Name, tag.ParentID, tag.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	return nil
+}
+
+// GetCardCounts returns how many cards have each tag or a tag nested in it,
+// by tag ID
+func (r *SQLiteTagRepository) GetCardCounts() (map[int64]int, error) {
+	query := `WITH RECURSIVE tag_tree(root, id) AS (
+				  SELECT id, id FROM tags
+				  UNION SELECT tag_tree.root, tags.id FROM tags JOIN tag_tree ON tags.parent_id = tag_tree.id
+			  )
+			  SELECT tag_tree.root, COUNT(DISTINCT card_tags.card_id)
+			  FROM tag_tree JOIN card_tags ON card_tags.tag_id = tag_tree.id GROUP BY tag_tree.root`
+
+	rows, err := r.db.conn().Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tagged cards: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var tagID int64
+		var count int
+		if err := rows.Scan(&tagID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag count: %w", err)
+		}
+		counts[tagID] = count
+	}
+
+	return counts, nil
+}
+
 // SQLite Review State Repository
 type SQLiteReviewStateRepository struct {
 	db *Database
@@ -199,7 +743,7 @@ func (r *SQLiteReviewStateRepository) Create(state *DBReviewState) error {
 	state.CreatedAt = now
 	state.UpdatedAt = now
 
-	result, err := r.db.db.Exec(query, state.CardID, state.FSRSCardData, state.LastReview,
+	result, err := r.db.conn().Exec(query, state.CardID, state.FSRSCardData, state.LastReview,
 								state.ReviewCount, state.DueDate, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to create review state: %w", err)
@@ -218,7 +762,7 @@ func (r *SQLiteReviewStateRepository) GetByCardID(cardID int64) (*DBReviewState,
 	query := `SELECT id, card_id, fsrs_card_data, last_review, review_count, due_date, created_at, updated_at
 			  FROM review_states WHERE card_id = ?`
 
-	row := r.db.db.QueryRow(query, cardID)
+	row := r.db.conn().QueryRow(query, cardID)
 
 	state := &DBReviewState{}
 	err := row.Scan(&state.ID, &state.CardID, &state.FSRSCardData, &state.LastReview,
@@ -236,7 +780,7 @@ func (r *SQLiteReviewStateRepository) Update(state *DBReviewState) error {
 
 	state.UpdatedAt = time.Now()
 
-	_, err := r.db.db.Exec(query, state.FSRSCardData, state.LastReview,
+	_, err := r.db.conn().Exec(query, state.FSRSCardData, state.LastReview,
 						   state.ReviewCount, state.DueDate, state.UpdatedAt, state.CardID)
 	if err != nil {
 		return fmt.Errorf("failed to update review state: %w", err)
@@ -248,7 +792,7 @@ func (r *SQLiteReviewStateRepository) Update(state *DBReviewState) error {
 func (r *SQLiteReviewStateRepository) Delete(cardID int64) error {
 	query := `DELETE FROM review_states WHERE card_id = ?`
 
-	_, err := r.db.db.Exec(query, cardID)
+	_, err := r.db.conn().Exec(query, cardID)
 	if err != nil {
 		return fmt.Errorf("failed to delete review state: %w", err)
 	}
@@ -261,7 +805,7 @@ func (r *SQLiteReviewStateRepository) GetDueCards() ([]*DBReviewState, error) {
 			  FROM review_states WHERE due_date <= ? ORDER BY due_date ASC`
 
 	now := time.Now()
-	rows, err := r.db.db.Query(query, now)
+	rows, err := r.db.conn().Query(query, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query due cards: %w", err)
 	}
@@ -281,6 +825,88 @@ func (r *SQLiteReviewStateRepository) GetDueCards() ([]*DBReviewState, error) {
 	return states, nil
 }
 
+// SQLite Review Log Repository
+type SQLiteReviewLogRepository struct {
+	db *Database
+}
+
+func NewSQLiteReviewLogRepository(db *Database) *SQLiteReviewLogRepository {
+	return &SQLiteReviewLogRepository{db: db}
+}
+
+// Columns selected for every review log query, in the order scanReviewLog expects them
+const reviewLogColumns = `id, card_id, reviewed_at, rating, elapsed_ms, state_before, state_after,
+			  stability_before, stability_after, difficulty_before, difficulty_after, scheduled_days, due_date`
+
+func scanReviewLog(row rowScanner) (*DBReviewLog, error) {
+	entry := &DBReviewLog{}
+	err := row.Scan(&entry.ID, &entry.CardID, &entry.ReviewedAt, &entry.Rating, &entry.ElapsedMs,
+					&entry.StateBefore, &entry.StateAfter, &entry.StabilityBefore, &entry.StabilityAfter,
+					&entry.DifficultyBefore, &entry.DifficultyAfter, &entry.ScheduledDays, &entry.DueDate)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *SQLiteReviewLogRepository) Create(entry *DBReviewLog) error {
+	query := `INSERT INTO review_log (card_id, reviewed_at, rating, elapsed_ms, state_before, state_after,
+			  stability_before, stability_after, difficulty_before, difficulty_after, scheduled_days, due_date)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// Stored in UTC, as times are compared as text
+	result, err := r.db.conn().Exec(query, entry.CardID, entry.ReviewedAt.UTC(), entry.Rating, entry.ElapsedMs,
+								entry.StateBefore, entry.StateAfter, entry.StabilityBefore, entry.StabilityAfter,
+								entry.DifficultyBefore, entry.DifficultyAfter, entry.ScheduledDays, entry.DueDate)
+	if err != nil {
+		return fmt.Errorf("failed to create review log entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	entry.ID = id
+	return nil
+}
+
+// GetByCardID returns the reviews of a card, oldest first
+func (r *SQLiteReviewLogRepository) GetByCardID(cardID int64) ([]*DBReviewLog, error) {
+	query := `SELECT ` + reviewLogColumns + `
+			  FROM review_log WHERE card_id = ? ORDER BY reviewed_at ASC`
+
+	return r.queryEntries(query, cardID)
+}
+
+// GetDateRange returns the reviews from start up to but not including end,
+// oldest first
+func (r *SQLiteReviewLogRepository) GetDateRange(start, end time.Time) ([]*DBReviewLog, error) {
+	query := `SELECT ` + reviewLogColumns + `
+			  FROM review_log WHERE reviewed_at >= ? AND reviewed_at < ? ORDER BY reviewed_at ASC`
+
+	return r.queryEntries(query, start.UTC(), end.UTC())
+}
+
+func (r *SQLiteReviewLogRepository) queryEntries(query string, args ...interface{}) ([]*DBReviewLog, error) {
+	rows, err := r.db.conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*DBReviewLog
+	for rows.Next() {
+		entry, err := scanReviewLog(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
 // Utility functions for converting between FSRS cards and JSON
 func FSRSCardToJSON(card fsrs.Card) (string, error) {
 	data, err := json.Marshal(card)
@@ -312,7 +938,7 @@ func (r *SQLiteSessionRepository) Create(session *DBSession) error {
 	query := `INSERT INTO sessions (start_time, end_time, cards_reviewed, new_cards, reviewed_cards)
 			  VALUES (?, ?, ?, ?, ?)`
 
-	result, err := r.db.db.Exec(query, session.StartTime, session.EndTime,
+	result, err := r.db.conn().Exec(query, session.StartTime, session.EndTime,
 								session.CardsReviewed, session.NewCards, session.ReviewedCards)
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
@@ -331,7 +957,7 @@ func (r *SQLiteSessionRepository) GetByID(id int64) (*DBSession, error) {
 	query := `SELECT id, start_time, end_time, cards_reviewed, new_cards, reviewed_cards
 			  FROM sessions WHERE id = ?`
 
-	row := r.db.db.QueryRow(query, id)
+	row := r.db.conn().QueryRow(query, id)
 
 	session := &DBSession{}
 	err := row.Scan(&session.ID, &session.StartTime, &session.EndTime,
@@ -347,7 +973,7 @@ func (r *SQLiteSessionRepository) Update(session *DBSession) error {
 	query := `UPDATE sessions SET start_time = ?, end_time = ?, cards_reviewed = ?,
 			  new_cards = ?, reviewed_cards = ? WHERE id = ?`
 
-	_, err := r.db.db.Exec(query, session.StartTime, session.EndTime,
+	_, err := r.db.conn().Exec(query, session.StartTime, session.EndTime,
 						   session.CardsReviewed, session.NewCards, session.ReviewedCards, session.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update session: %w", err)
@@ -360,7 +986,7 @@ func (r *SQLiteSessionRepository) GetAll() ([]*DBSession, error) {
 	query := `SELECT id, start_time, end_time, cards_reviewed, new_cards, reviewed_cards
 			  FROM sessions ORDER BY start_time DESC`
 
-	rows, err := r.db.db.Query(query)
+	rows, err := r.db.conn().Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sessions: %w", err)
 	}
@@ -383,7 +1009,7 @@ func (r *SQLiteSessionRepository) GetAll() ([]*DBSession, error) {
 func (r *SQLiteSessionRepository) Delete(id int64) error {
 	query := `DELETE FROM sessions WHERE id = ?`
 
-	_, err := r.db.db.Exec(query, id)
+	_, err := r.db.conn().Exec(query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
@@ -395,7 +1021,7 @@ func (r *SQLiteSessionRepository) DeleteOrphanedSessions() (int, error) {
 	// Delete sessions that have no end time and no cards reviewed (orphaned sessions)
 	query := `DELETE FROM sessions WHERE (end_time IS NULL OR end_time = '0001-01-01 00:00:00+00:00') AND cards_reviewed = 0`
 
-	result, err := r.db.db.Exec(query)
+	result, err := r.db.conn().Exec(query)
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete orphaned sessions: %w", err)
 	}
@@ -421,7 +1047,7 @@ func (r *SQLiteDailyStatsRepository) Create(stats *DBDailyStats) error {
 	query := `INSERT INTO daily_stats (date, cards_reviewed, session_time, session_count, new_cards, reviewed_cards)
 			  VALUES (?, ?, ?, ?, ?, ?)`
 
-	result, err := r.db.db.Exec(query, stats.Date, stats.CardsReviewed,
+	result, err := r.db.conn().Exec(query, stats.Date, stats.CardsReviewed,
 								stats.SessionTime, stats.SessionCount, stats.NewCards, stats.ReviewedCards)
 	if err != nil {
 		return fmt.Errorf("failed to create daily stats: %w", err)
@@ -440,7 +1066,7 @@ func (r *SQLiteDailyStatsRepository) GetByDate(date string) (*DBDailyStats, erro
 	query := `SELECT id, date, cards_reviewed, session_time, session_count, new_cards, reviewed_cards
 			  FROM daily_stats WHERE date = ?`
 
-	row := r.db.db.QueryRow(query, date)
+	row := r.db.conn().QueryRow(query, date)
 
 	stats := &DBDailyStats{}
 	err := row.Scan(&stats.ID, &stats.Date, &stats.CardsReviewed,
@@ -456,7 +1082,7 @@ func (r *SQLiteDailyStatsRepository) Update(stats *DBDailyStats) error {
 	query := `UPDATE daily_stats SET cards_reviewed = ?, session_time = ?,
 			  session_count = ?, new_cards = ?, reviewed_cards = ? WHERE date = ?`
 
-	_, err := r.db.db.Exec(query, stats.CardsReviewed, stats.SessionTime,
+	_, err := r.db.conn().Exec(query, stats.CardsReviewed, stats.SessionTime,
 						   stats.SessionCount, stats.NewCards, stats.ReviewedCards, stats.Date)
 	if err != nil {
 		return fmt.Errorf("failed to update daily stats: %w", err)
@@ -469,7 +1095,7 @@ func (r *SQLiteDailyStatsRepository) GetDateRange(startDate, endDate string) ([]
 	query := `SELECT id, date, cards_reviewed, session_time, session_count, new_cards, reviewed_cards
 			  FROM daily_stats WHERE date BETWEEN ? AND ? ORDER BY date DESC`
 
-	rows, err := r.db.db.Query(query, startDate, endDate)
+	rows, err := r.db.conn().Query(query, startDate, endDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query daily stats: %w", err)
 	}
@@ -493,7 +1119,7 @@ func (r *SQLiteDailyStatsRepository) GetAll() ([]*DBDailyStats, error) {
 	query := `SELECT id, date, cards_reviewed, session_time, session_count, new_cards, reviewed_cards
 			  FROM daily_stats ORDER BY date DESC`
 
-	rows, err := r.db.db.Query(query)
+	rows, err := r.db.conn().Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query daily stats: %w", err)
 	}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-spaced-repetition/go-fsrs/v3"
+)
+
+// failingReviewLog is a review log that cannot be written to
+type failingReviewLog struct{ ReviewLogRepository }
+
+func (failingReviewLog) Create(entry *DBReviewLog) error {
+	return errors.New("disk full")
+}
+
+// newLoggedScheduler returns a manager that logs the reviews of the one card
+// it schedules
+func newLoggedScheduler(t *testing.T) (*FSRSManager, *SQLiteReviewLogRepository, Card) {
+	t.Helper()
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	reloadCards(t, cp, filepath.Join(t.TempDir(), "cards.txt"), "What is Go?>>a language\n")
+	fm := NewFSRSManagerWithDatabase(NewSQLiteReviewStateRepository(db), NewSQLiteDeckRepository(db))
+	reviewLog := NewSQLiteReviewLogRepository(db)
+	fm.SetReviewLog(reviewLog)
+	return fm, reviewLog, cp.GetCards()[0]
+}
+
+func TestReviewCardLogsReviews(t *testing.T) {
+	fm, reviewLog, card := newLoggedScheduler(t)
+
+	start := time.Now()
+	if err := fm.ReviewCard(card, fsrs.Good, 3*time.Second); err != nil {
+		t.Fatalf("ReviewCard: %v", err)
+	}
+	if err := fm.ReviewCard(card, fsrs.Again, 1500*time.Millisecond); err != nil {
+		t.Fatalf("ReviewCard: %v", err)
+	}
+
+	entries, err := reviewLog.GetByCardID(card.ID)
+	if err != nil {
+		t.Fatalf("GetByCardID: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d review log entries, want 2", len(entries))
+	}
+
+	first, second := entries[0], entries[1]
+	if first.Rating != int(fsrs.Good) || first.ElapsedMs != 3000 || second.Rating != int(fsrs.Again) || second.ElapsedMs != 1500 {
+		t.Errorf("logged ratings %d and %d taking %dms and %dms, want Good in 3000ms and Again in 1500ms",
+			first.Rating, second.Rating, first.ElapsedMs, second.ElapsedMs)
+	}
+	if first.ReviewedAt.Before(start.Add(-time.Second)) || first.ReviewedAt.After(second.ReviewedAt) {
+		t.Errorf("reviewed at %v and %v, want both after %v in order", first.ReviewedAt, second.ReviewedAt, start)
+	}
+
+	// Each review starts from the state the one before left the card in
+	if first.StateBefore != int(fsrs.New) || first.StabilityBefore != 0 || first.StabilityAfter <= 0 {
+		t.Errorf("first review went from state %d, stability %g to stability %g; want a new card becoming stable",
+			first.StateBefore, first.StabilityBefore, first.StabilityAfter)
+	}
+	if second.StateBefore != first.StateAfter || second.StabilityBefore != first.StabilityAfter || second.DifficultyBefore != first.DifficultyAfter {
+		t.Errorf("second review started from %+v, want where the first ended %+v", second, first)
+	}
+
+	// The entry records the schedule the card was given
+	state := fm.GetCardState(card)
+	if second.StateAfter != int(state.FSRSCard.State) || !second.DueDate.Equal(state.FSRSCard.Due) || second.ScheduledDays != int(state.FSRSCard.ScheduledDays) {
+		t.Errorf("last entry %+v does not match the card's state %+v", second, state.FSRSCard)
+	}
+}
+
+func TestReviewCardWithoutReviewLog(t *testing.T) {
+	fm, reviewLog, card := newLoggedScheduler(t)
+	fm.SetReviewLog(nil)
+
+	if err := fm.ReviewCard(card, fsrs.Good, time.Second); err != nil {
+		t.Fatalf("ReviewCard: %v", err)
+	}
+	if entries, err := reviewLog.GetByCardID(card.ID); err != nil || len(entries) != 0 {
+		t.Errorf("review log = %+v, %v; want nothing logged", entries, err)
+	}
+}
+
+func TestReviewCardWhenLogFails(t *testing.T) {
+	fm, _, card := newLoggedScheduler(t)
+	fm.SetReviewLog(failingReviewLog{})
+
+	if err := fm.ReviewCard(card, fsrs.Good, time.Second); err != nil {
+		t.Fatalf("ReviewCard failed with the review log: %v", err)
+	}
+	if state := fm.GetCardState(card); state.ReviewCount != 1 {
+		t.Errorf("card reviewed %d times, want the review kept", state.ReviewCount)
+	}
+}
+
+func TestReviewLogDateRange(t *testing.T) {
+	db := newTestDatabase(t)
+	cp := newTestParser(db)
+	reloadCards(t, cp, filepath.Join(t.TempDir(), "cards.txt"), "What is Go?>>a language\n")
+	card := cp.GetCards()[0]
+	reviewLog := NewSQLiteReviewLogRepository(db)
+
+	// Reviews are logged in local time, east and west of UTC
+	east := time.FixedZone("UTC+2", 2*60*60)
+	west := time.FixedZone("UTC-5", -5*60*60)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, east)
+	reviews := []time.Time{
+		day.Add(-time.Minute),            // The evening before
+		day,                              // Midnight
+		day.Add(23 * time.Hour).In(west), // Late that day
+		day.Add(24 * time.Hour),          // The next midnight
+	}
+	for i, reviewedAt := range reviews {
+		if err := reviewLog.Create(&DBReviewLog{CardID: card.ID, ReviewedAt: reviewedAt, Rating: i + 1}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	// The day is asked for in another zone than the reviews were logged in
+	entries, err := reviewLog.GetDateRange(day.In(west), day.Add(24*time.Hour).In(time.UTC))
+	if err != nil {
+		t.Fatalf("GetDateRange: %v", err)
+	}
+	var ratings []int
+	for _, entry := range entries {
+		ratings = append(ratings, entry.Rating)
+	}
+	if len(ratings) != 2 || ratings[0] != 2 || ratings[1] != 3 {
+		t.Errorf("reviews in the day have ratings %v, want [2 3]", ratings)
+	}
+	if len(entries) > 0 && !entries[0].ReviewedAt.Equal(day) {
+		t.Errorf("first review of the day at %v, want %v", entries[0].ReviewedAt, day)
+	}
+}
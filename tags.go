@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Separates the levels of a nested tag, "lang::go::generics"
+const tagPathSeparator = "::"
+
+// splitTags returns the tags in a tag list as written on a card or in a card
+// file. Leading "#" is dropped, as are empty levels of nested tags, and a tag
+// listed twice is returned once.
+func splitTags(tags string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(normalizeTags(tags), ",") {
+		var levels []string
+		for _, level := range strings.Split(strings.TrimSpace(tag), tagPathSeparator) {
+			if level = strings.TrimLeft(level, "#"); level != "" {
+				levels = append(levels, level)
+			}
+		}
+		name := strings.Join(levels, tagPathSeparator)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// cleanTags returns a tag list the way it is stored on cards, with each tag
+// listed once
+func cleanTags(tags string) string {
+	return strings.Join(splitTags(tags), ", ")
+}
+
+// containsTag reports whether a tag list contains tag, in any case
+func containsTag(tags, tag string) bool {
+	for _, name := range splitTags(tags) {
+		if strings.EqualFold(name, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// parentTagName returns the tag a nested tag is part of, or "" for a tag at
+// the top level
+func parentTagName(name string) string {
+	idx := strings.LastIndex(name, tagPathSeparator)
+	if idx <= 0 {
+		return ""
+	}
+	return name[:idx]
+}
+
+// tagWithin reports whether tag is name or a tag nested in it
+func tagWithin(tag, name string) bool {
+	if strings.EqualFold(tag, name) {
+		return true
+	}
+	prefix := name + tagPathSeparator
+	return len(tag) > len(prefix) && strings.EqualFold(tag[:len(prefix)], prefix)
+}
+
+// TagSummary is a tag as listed for managing tags
+type TagSummary struct {
+	Name  string
+	Depth int // 0 for tags at the top level
+	Cards int // Cards with the tag or a tag nested in it
+}
+
+// TagManager renames, merges and deletes tags. Tags are changed on the cards
+// in the database, and in the card files they came from when the parser set
+// with SetParser writes back; otherwise a card file that is loaded again
+// brings back the tags written in it.
+type TagManager struct {
+	tagRepo  TagRepository
+	cardRepo CardRepository
+	parser   *CardParser
+}
+
+func NewTagManager(tagRepo TagRepository, cardRepo CardRepository) *TagManager {
+	return &TagManager{tagRepo: tagRepo, cardRepo: cardRepo}
+}
+
+// SetParser makes tag changes go to the card files of the cards changed, when
+// the parser has write-back on
+func (tm *TagManager) SetParser(cp *CardParser) {
+	tm.parser = cp
+}
+
+// WritesBack reports whether tag changes are written to card files
+func (tm *TagManager) WritesBack() bool {
+	return tm.parser != nil && tm.parser.WriteBackEnabled()
+}
+
+// GetTags returns all tags ordered by name
+func (tm *TagManager) GetTags() ([]TagSummary, error) {
+	tags, err := tm.tagRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := tm.tagRepo.GetCardCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]TagSummary, len(tags))
+	for i, tag := range tags {
+		summaries[i] = TagSummary{
+			Name:  tag.Name,
+			Depth: strings.Count(tag.Name, tagPathSeparator),
+			Cards: counts[tag.ID],
+		}
+	}
+	return summaries, nil
+}
+
+// RenameTag gives a tag a new name. The tags nested in it move along, so
+// renaming "lang::go" to "golang" turns "lang::go::generics" into
+// "golang::generics". It returns the number of cards changed.
+func (tm *TagManager) RenameTag(oldName, newName string) (int, error) {
+	tag, err := tm.tagRepo.GetByName(oldName)
+	if err != nil {
+		return 0, err
+	}
+	newName, err = cleanTagName(newName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only the case changes, the tags stay where they are
+	if strings.EqualFold(tag.Name, newName) {
+		return tm.recaseTags(tag.Name, newName)
+	}
+
+	if _, err := tm.tagRepo.GetByName(newName); err == nil {
+		return 0, fmt.Errorf("tag %q already exists, merge the tags instead", newName)
+	}
+	return tm.moveTag(tag.Name, newName)
+}
+
+// MergeTags moves the cards of a tag, and the tags nested in it, into
+// another tag that already exists. It returns the number of cards changed.
+func (tm *TagManager) MergeTags(fromName, intoName string) (int, error) {
+	from, err := tm.tagRepo.GetByName(fromName)
+	if err != nil {
+		return 0, err
+	}
+	into, err := tm.tagRepo.GetByName(intoName)
+	if err != nil {
+		return 0, err
+	}
+	if tagWithin(into.Name, from.Name) {
+		return 0, fmt.Errorf("cannot merge %q into %q, which is part of it", from.Name, into.Name)
+	}
+	return tm.moveTag(from.Name, into.Name)
+}
+
+// DeleteTag removes a tag and the tags nested in it from every card. It
+// returns the number of cards changed.
+func (tm *TagManager) DeleteTag(name string) (int, error) {
+	tag, err := tm.tagRepo.GetByName(name)
+	if err != nil {
+		return 0, err
+	}
+	return tm.retag(tag.Name, func(string) string {
+		return ""
+	})
+}
+
+// moveTag renames a tag and the tags nested in it on every card that has them
+func (tm *TagManager) moveTag(fromName, toName string) (int, error) {
+	return tm.retag(fromName, func(tag string) string {
+		return toName + tag[len(fromName):]
+	})
+}
+
+// retag rewrites the tag lists of the cards that have a tag or a tag nested
+// in it. rename returns what such a tag becomes, or "" to remove it. A card
+// whose file cannot be written keeps its tags, so that the file and the
+// database still agree; these cards are listed in the error returned.
+func (tm *TagManager) retag(name string, rename func(tag string) string) (int, error) {
+	dbCards, err := tm.cardRepo.GetByTag(name)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	var failed []string
+	written := make(map[string]error) // The cards of a note share their file line
+	for _, dbCard := range dbCards {
+		var tags []string
+		for _, tag := range splitTags(dbCard.Tags) {
+			if tagWithin(tag, name) {
+				tag = rename(tag)
+			}
+			if tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		// Joined and split again, so a tag a card ends up with twice is kept once
+		dbCard.Tags = cleanTags(strings.Join(tags, ","))
+
+		// Drafts and archived cards are not in their file
+		if tm.WritesBack() && dbCard.Status == CardStatusActive && tm.parser.canWriteBack(dbCard.SourceFile) {
+			// Rewriting a card further up the file may have moved this one
+			current, err := tm.cardRepo.GetByID(dbCard.ID)
+			if err != nil {
+				return changed, err
+			}
+			dbCard.SourceLine = current.SourceLine
+
+			location := fmt.Sprintf("%s line %d", dbCard.SourceFile, dbCard.SourceLine)
+			err, ok := written[location]
+			if !ok {
+				err = tm.parser.rewriteCardTags(dbCard)
+				written[location] = err
+				if err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %v", location, err))
+				}
+			}
+			if err != nil {
+				continue
+			}
+		}
+
+		if err := tm.cardRepo.Update(dbCard); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+
+	if len(failed) > 10 {
+		failed = append(failed[:10], fmt.Sprintf("... and %d more", len(failed)-10))
+	}
+	if len(failed) > 0 {
+		return changed, fmt.Errorf("cards whose file could not be changed kept their tags:\n%s", strings.Join(failed, "\n"))
+	}
+	return changed, nil
+}
+
+// recaseTags changes the case of a tag's name, and of the tags nested in it,
+// both on the tags and on the cards that have them
+func (tm *TagManager) recaseTags(oldName, newName string) (int, error) {
+	tags, err := tm.tagRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	for _, tag := range tags {
+		if !tagWithin(tag.Name, oldName) {
+			continue
+		}
+		tag.Name = newName + tag.Name[len(oldName):]
+		if err := tm.tagRepo.Update(tag); err != nil {
+			return 0, err
+		}
+	}
+
+	return tm.moveTag(oldName, newName)
+}
+
+// cleanTagName checks that name is a single tag and returns it the way tags
+// are stored
+func cleanTagName(name string) (string, error) {
+	names := splitTags(name)
+	if len(names) == 0 {
+		return "", fmt.Errorf("tag name cannot be empty")
+	}
+	if len(names) > 1 {
+		return "", fmt.Errorf("a tag name cannot contain spaces or commas")
+	}
+	return names[0], nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const taggedCards = "Q1>>A1 #lang::go\n" +
+	"Q2>>A2 #lang::go::generics #golang\n" +
+	"Q3>>A3 #golang\n" +
+	"Q4>>A4 #other\n"
+
+// newTestTags loads tagged cards into a test database and returns a tag
+// manager for them
+func newTestTags(t *testing.T) (*TagManager, *Database, string) {
+	t.Helper()
+	db := newTestDatabase(t)
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	reloadCards(t, newTestParser(db), path, taggedCards)
+	return NewTagManager(NewSQLiteTagRepository(db), NewSQLiteCardRepository(db)), db, path
+}
+
+// tagCounts returns each tag with its number of cards
+func tagCounts(t *testing.T, tm *TagManager) map[string]int {
+	t.Helper()
+	tags, err := tm.GetTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, tag := range tags {
+		counts[tag.Name] = tag.Cards
+	}
+	return counts
+}
+
+// cardTags returns the tags of each card by question
+func cardTags(t *testing.T, db *Database, path string) map[string]string {
+	t.Helper()
+	tags := make(map[string]string)
+	for question, dbCard := range storedCards(t, db, path) {
+		tags[question] = dbCard.Tags
+	}
+	return tags
+}
+
+func TestTagChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(tm *TagManager) (int, error)
+		changed int
+		cards   map[string]string
+		tags    map[string]int
+	}{
+		{
+			name:    "rename moves nested tags along",
+			change:  func(tm *TagManager) (int, error) { return tm.RenameTag("lang::go", "go") },
+			changed: 2,
+			cards:   map[string]string{"Q1": "go", "Q2": "go::generics, golang", "Q3": "golang", "Q4": "other"},
+			tags:    map[string]int{"go": 2, "go::generics": 1, "golang": 2, "other": 1},
+		},
+		{
+			name:    "rename into a nested tag",
+			change:  func(tm *TagManager) (int, error) { return tm.RenameTag("other", "lang::other") },
+			changed: 1,
+			cards:   map[string]string{"Q1": "lang::go", "Q2": "lang::go::generics, golang", "Q3": "golang", "Q4": "lang::other"},
+			tags:    map[string]int{"golang": 2, "lang": 3, "lang::go": 2, "lang::go::generics": 1, "lang::other": 1},
+		},
+		{
+			name:    "rename changing case",
+			change:  func(tm *TagManager) (int, error) { return tm.RenameTag("lang", "Lang") },
+			changed: 2,
+			cards:   map[string]string{"Q1": "Lang::go", "Q2": "Lang::go::generics, golang", "Q3": "golang", "Q4": "other"},
+			tags:    map[string]int{"Lang": 2, "Lang::go": 2, "Lang::go::generics": 1, "golang": 2, "other": 1},
+		},
+		{
+			name:    "merge into an existing tag",
+			change:  func(tm *TagManager) (int, error) { return tm.MergeTags("golang", "lang::go") },
+			changed: 2,
+			cards:   map[string]string{"Q1": "lang::go", "Q2": "lang::go::generics, lang::go", "Q3": "lang::go", "Q4": "other"},
+			tags:    map[string]int{"lang": 3, "lang::go": 3, "lang::go::generics": 1, "other": 1},
+		},
+		{
+			name:    "delete removes nested tags and unused parents",
+			change:  func(tm *TagManager) (int, error) { return tm.DeleteTag("lang::go") },
+			changed: 2,
+			cards:   map[string]string{"Q1": "", "Q2": "golang", "Q3": "golang", "Q4": "other"},
+			tags:    map[string]int{"golang": 2, "other": 1},
+		},
+		{
+			name:    "delete a nested tag keeps its parent",
+			change:  func(tm *TagManager) (int, error) { return tm.DeleteTag("lang::go::generics") },
+			changed: 1,
+			cards:   map[string]string{"Q1": "lang::go", "Q2": "golang", "Q3": "golang", "Q4": "other"},
+			tags:    map[string]int{"golang": 2, "lang": 1, "lang::go": 1, "other": 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tm, db, path := newTestTags(t)
+			changed, err := test.change(tm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != test.changed {
+				t.Errorf("changed %d cards, want %d", changed, test.changed)
+			}
+			if cards := cardTags(t, db, path); !reflect.DeepEqual(cards, test.cards) {
+				t.Errorf("card tags = %q, want %q", cards, test.cards)
+			}
+			if tags := tagCounts(t, tm); !reflect.DeepEqual(tags, test.tags) {
+				t.Errorf("tags = %v, want %v", tags, test.tags)
+			}
+		})
+	}
+}
+
+func TestTagChangeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(tm *TagManager) (int, error)
+	}{
+		{"rename to an existing tag", func(tm *TagManager) (int, error) { return tm.RenameTag("golang", "lang::go") }},
+		{"rename to two tags", func(tm *TagManager) (int, error) { return tm.RenameTag("golang", "go lang") }},
+		{"rename to nothing", func(tm *TagManager) (int, error) { return tm.RenameTag("golang", " # ") }},
+		{"rename a missing tag", func(tm *TagManager) (int, error) { return tm.RenameTag("missing", "found") }},
+		{"merge into a tag nested in it", func(tm *TagManager) (int, error) { return tm.MergeTags("lang", "lang::go") }},
+		{"merge into a missing tag", func(tm *TagManager) (int, error) { return tm.MergeTags("golang", "missing") }},
+		{"delete a missing tag", func(tm *TagManager) (int, error) { return tm.DeleteTag("missing") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tm, db, path := newTestTags(t)
+			before := cardTags(t, db, path)
+			if _, err := test.change(tm); err == nil {
+				t.Error("succeeded, want an error")
+			}
+			if after := cardTags(t, db, path); !reflect.DeepEqual(after, before) {
+				t.Errorf("card tags changed to %q", after)
+			}
+		})
+	}
+}
+
+func TestTagChangesWriteBack(t *testing.T) {
+	tm, db, path := newTestTags(t)
+	cp := loadWriteBack(t, path)
+	tm.SetParser(cp)
+
+	if _, err := tm.RenameTag("lang::go", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.DeleteTag("other"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Q1 >> A1 #go\nQ2 >> A2 #go::generics #golang\nQ3>>A3 #golang\nQ4 >> A4\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("card file =\n%s\nwant\n%s", got, want)
+	}
+
+	// Loading the file again keeps the changed tags
+	reloadCards(t, newTestParser(db), path, readFile(t, path))
+	if cards := cardTags(t, db, path); cards["Q1"] != "go" || cards["Q4"] != "" {
+		t.Errorf("card tags after reloading = %q", cards)
+	}
+}
+
+func TestMigrateTags(t *testing.T) {
+	db := newTestDatabase(t)
+
+	// Cards stored before tags had tables of their own only had the column
+	for i, tags := range []string{"#golang, lang::go", "golang,,#golang", ""} {
+		_, err := db.db.Exec(`INSERT INTO cards (question, answer, source_file, source_line, tags) VALUES (?, ?, 'old.txt', ?, ?)`,
+			"Q"+strings.Repeat("!", i), "A", i+1, tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.migrateTags(); err != nil {
+		t.Fatalf("migrateTags: %v", err)
+	}
+
+	if cards := cardTags(t, db, "old.txt"); !reflect.DeepEqual(cards, map[string]string{"Q": "golang, lang::go", "Q!": "golang", "Q!!": ""}) {
+		t.Errorf("card tags = %q", cards)
+	}
+	tm := NewTagManager(NewSQLiteTagRepository(db), NewSQLiteCardRepository(db))
+	if tags := tagCounts(t, tm); !reflect.DeepEqual(tags, map[string]int{"golang": 2, "lang": 1, "lang::go": 1}) {
+		t.Errorf("tags = %v", tags)
+	}
+
+	// Once cards have linked tags the column is not read again
+	if _, err := db.db.Exec(`UPDATE cards SET tags = 'stale' WHERE question = 'Q'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.migrateTags(); err != nil {
+		t.Fatalf("migrateTags: %v", err)
+	}
+	if tags := tagCounts(t, tm); tags["stale"] != 0 || tags["golang"] != 2 {
+		t.Errorf("tags after migrating again = %v", tags)
+	}
+}
@@ -9,6 +9,14 @@ import (
 
 type SpacedRepetitionTheme struct{}
 
+// Colors of highlighted code in code block cards
+const (
+	colorNameCodeKeyword fyne.ThemeColorName = "codeKeyword"
+	colorNameCodeString  fyne.ThemeColorName = "codeString"
+	colorNameCodeComment fyne.ThemeColorName = "codeComment"
+	colorNameCodeNumber  fyne.ThemeColorName = "codeNumber"
+)
+
 var _ fyne.Theme = (*SpacedRepetitionTheme)(nil)
 
 func (t *SpacedRepetitionTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
@@ -58,6 +66,30 @@ func (t *SpacedRepetitionTheme) Color(name fyne.ThemeColorName, variant fyne.The
 	case theme.ColorNameShadow:
 		return color.NRGBA{0, 0, 0, 20} // Subtle shadow
 
+	case colorNameCodeKeyword:
+		if variant == theme.VariantLight {
+			return color.NRGBA{111, 66, 193, 255} // Purple
+		}
+		return color.NRGBA{198, 146, 255, 255}
+
+	case colorNameCodeString:
+		if variant == theme.VariantLight {
+			return color.NRGBA{3, 102, 60, 255} // Green
+		}
+		return color.NRGBA{126, 214, 150, 255}
+
+	case colorNameCodeComment:
+		if variant == theme.VariantLight {
+			return color.NRGBA{106, 115, 125, 255} // Gray
+		}
+		return color.NRGBA{139, 148, 158, 255}
+
+	case colorNameCodeNumber:
+		if variant == theme.VariantLight {
+			return color.NRGBA{0, 92, 197, 255} // Blue
+		}
+		return color.NRGBA{121, 184, 255, 255}
+
 	default:
 		return theme.DefaultTheme().Color(name, variant)
 	}
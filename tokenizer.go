@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Separators recognised on single-line cards, in order of precedence. When a
+// line contains more than one kind, the first in this list splits the card
+// and the others are part of the text.
+var defaultSeparators = []string{">>", reverseSeparator, "::", "|"}
+
+// separatorMatch is an occurrence of a separator outside quotes and escapes
+type separatorMatch struct {
+	separator string
+	pos       int // Byte offset in the line
+}
+
+// scanSeparators finds the separators in line that are not escaped with a
+// backslash (\>>) or inside a "quoted" or `code` span. A quote that is never
+// closed is taken literally, so a lone 12" does not swallow the rest of the
+// line.
+func scanSeparators(line string, separators []string) []separatorMatch {
+	matches, balanced := scanSeparatorsQuoted(line, separators, true)
+	if !balanced {
+		matches, _ = scanSeparatorsQuoted(line, separators, false)
+	}
+	return matches
+}
+
+func scanSeparatorsQuoted(line string, separators []string, useQuotes bool) ([]separatorMatch, bool) {
+	var matches []separatorMatch
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		if c == '\\' {
+			if n := escapedSeparator(line[i+1:], separators); n > 0 {
+				i += n
+				continue
+			}
+		}
+
+		if useQuotes && (c == '"' || c == '`') {
+			switch quote {
+			case 0:
+				quote = c
+			case c:
+				quote = 0
+			}
+			continue
+		}
+		if quote != 0 {
+			continue
+		}
+
+		for _, sep := range separators {
+			if strings.HasPrefix(line[i:], sep) {
+				matches = append(matches, separatorMatch{separator: sep, pos: i})
+				i += len(sep) - 1
+				break
+			}
+		}
+	}
+
+	return matches, quote == 0
+}
+
+// escapedSeparator returns the length of the separator text starts with, or 0.
+// A backslash is only an escape in front of a whole separator, so backslashes
+// in code such as "\n" or \<word\> are left alone.
+func escapedSeparator(text string, separators []string) int {
+	for _, sep := range separators {
+		if strings.HasPrefix(text, sep) {
+			return len(sep)
+		}
+	}
+	return 0
+}
+
+// splitCardLine splits a single-line card into its question and answer. The
+// separator used is the first of separators that occurs in the line, and it
+// has to occur exactly once. The returned reason explains a failure.
+func splitCardLine(line string, separators []string) (question, answer, separator, reason string) {
+	matches := scanSeparators(line, separators)
+
+	for _, sep := range separators {
+		var found []separatorMatch
+		for _, match := range matches {
+			if match.separator == sep {
+				found = append(found, match)
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		if len(found) > 1 {
+			columns := make([]string, len(found))
+			for i, match := range found {
+				columns[i] = fmt.Sprint(utf8.RuneCountInString(line[:match.pos]) + 1)
+			}
+			reason = fmt.Sprintf(`Separator "%s" appears %d times (columns %s). Escape the ones that belong to the text as \%s or put that text in quotes`,
+				sep, len(found), strings.Join(columns, ", "), sep)
+			return "", "", sep, reason
+		}
+
+		pos := found[0].pos
+		return line[:pos], line[pos+len(sep):], sep, ""
+	}
+
+	if len(separators) == 1 {
+		return "", "", "", fmt.Sprintf(`No "%s" separator found (declared in the front matter)`, separators[0])
+	}
+	return "", "", "", fmt.Sprintf("No valid separator found. Expected one of: %s", strings.Join(separators, ", "))
+}
+
+// unescapeCardText removes the backslashes that escape separators. Quotes
+// stay part of the text.
+func unescapeCardText(text string, separators []string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			if n := escapedSeparator(text[i+1:], separators); n > 0 {
+				b.WriteString(text[i+1 : i+1+n])
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(text[i])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// escapeCardText puts a backslash in front of every separator in text, so
+// that unescapeCardText gives the text back
+func escapeCardText(text string, separators []string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if n := escapedSeparator(text[i:], separators); n > 0 {
+			b.WriteByte('\\')
+			b.WriteString(text[i : i+n])
+			i += n - 1
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitCardLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		question  string
+		answer    string
+		separator string
+		reason    string // Part of the reason a line does not split
+	}{
+		{name: "arrows", line: "What is Go? >> A language", question: "What is Go? ", answer: " A language", separator: ">>"},
+		{name: "double colon", line: "Capital of France :: Paris", question: "Capital of France ", answer: " Paris", separator: "::"},
+		{name: "pipe", line: "2+2|4", question: "2+2", answer: "4", separator: "|"},
+		{name: "first separator in precedence wins", line: "a::b >> c", question: "a::b ", answer: " c", separator: ">>"},
+		{name: "escaped separator", line: `x \>> y >> z`, question: `x \>> y `, answer: " z", separator: ">>"},
+		{name: "quoted separator", line: `"a >> b" >> c`, question: `"a >> b" `, answer: " c", separator: ">>"},
+		{name: "code span", line: "What does `x >> 1` do? >> Halves x", question: "What does `x >> 1` do? ", answer: " Halves x", separator: ">>"},
+		{name: "unclosed quote is literal", line: `A 12" pipe >> 30 cm`, question: `A 12" pipe `, answer: " 30 cm", separator: ">>"},
+		{name: "separator twice", line: "a >> b >> c", separator: ">>", reason: `appears 2 times (columns 3, 8)`},
+		{name: "no separator", line: "just text", reason: "No valid separator found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			question, answer, separator, reason := splitCardLine(test.line, defaultSeparators)
+			if question != test.question || answer != test.answer || separator != test.separator {
+				t.Errorf("splitCardLine(%q) = %q, %q, %q, want %q, %q, %q",
+					test.line, question, answer, separator, test.question, test.answer, test.separator)
+			}
+			if (test.reason == "") != (reason == "") || !strings.Contains(reason, test.reason) {
+				t.Errorf("reason = %q, want one containing %q", reason, test.reason)
+			}
+		})
+	}
+}
+
+func TestEscapeCardText(t *testing.T) {
+	for _, text := range []string{"a >> b", `keep \n alone`, "a::b|c <> d", "plain"} {
+		escaped := escapeCardText(text, defaultSeparators)
+		if got := unescapeCardText(escaped, defaultSeparators); got != text {
+			t.Errorf("unescapeCardText(escapeCardText(%q)) = %q", text, got)
+		}
+		if _, _, _, reason := splitCardLine(escaped+" >> answer", defaultSeparators); reason != "" {
+			t.Errorf("%q does not split after escaping: %s", text, reason)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Editors often save a file in several steps (truncate, write, rename), so a
+// file is only reloaded once it has been quiet for this long
+const watchDebounce = 300 * time.Millisecond
+
+// CardFileWatcher calls onChange when a watched card file, or a card file
+// inside a watched directory, changes on disk. Paths are passed to onChange
+// as they were given to WatchFile or WatchDir, so they match the source_file
+// of cards imported from them.
+type CardFileWatcher struct {
+	watcher  *fsnotify.Watcher
+	onChange func(path string)
+
+	mu     sync.Mutex
+	files  map[string]string // absolute path -> path as loaded
+	dirs   map[string]string // absolute directory -> directory as loaded
+	timers map[string]*time.Timer
+}
+
+func NewCardFileWatcher(onChange func(path string)) (*CardFileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &CardFileWatcher{
+		watcher:  watcher,
+		onChange: onChange,
+		files:    make(map[string]string),
+		dirs:     make(map[string]string),
+		timers:   make(map[string]*time.Timer),
+	}
+	go w.run()
+	return w, nil
+}
+
+// WatchFile watches a single card file. The directory holding it is watched
+// rather than the file itself, because editors that save by replacing the
+// file would otherwise end the watch.
+func (w *CardFileWatcher) WatchFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	if err := w.watcher.Add(filepath.Dir(absPath)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	w.mu.Lock()
+	w.files[absPath] = path
+	w.mu.Unlock()
+	return nil
+}
+
+// WatchDir watches every card file in a directory tree, including files and
+// subdirectories created later
+func (w *CardFileWatcher) WatchDir(dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	if err := w.addTree(absDir); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.dirs[absDir] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// addTree adds a watch for dir and each directory below it
+func (w *CardFileWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && isIgnoredName(entry.Name()) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// Clear stops watching all files and directories
+func (w *CardFileWatcher) Clear() {
+	for _, path := range w.watcher.WatchList() {
+		w.watcher.Remove(path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	w.files = make(map[string]string)
+	w.dirs = make(map[string]string)
+	w.timers = make(map[string]*time.Timer)
+}
+
+func (w *CardFileWatcher) Close() error {
+	return w.watcher.Close()
+}
+
+func (w *CardFileWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("File watcher error: %v", err)
+		}
+	}
+}
+
+func (w *CardFileWatcher) handleEvent(event fsnotify.Event) {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return
+	}
+
+	loaded := w.loadedPath(event.Name)
+	if loaded == "" {
+		// New subdirectories of a watched tree are watched as well
+		if event.Has(fsnotify.Create) && w.inWatchedDir(event.Name) {
+			if err := w.addTree(event.Name); err != nil {
+				log.Printf("File watcher error: %v", err)
+			}
+		}
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if timer, ok := w.timers[event.Name]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	w.timers[event.Name] = time.AfterFunc(watchDebounce, func() {
+		w.mu.Lock()
+		delete(w.timers, event.Name)
+		w.mu.Unlock()
+		w.onChange(loaded)
+	})
+}
+
+// loadedPath returns the path under which a changed file was loaded, or ""
+// when it is not a watched card file. Inside a watched directory the files
+// left out when it was loaded are left out here too.
+func (w *CardFileWatcher) loadedPath(absPath string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if path, ok := w.files[absPath]; ok {
+		return path
+	}
+	if !isCardFile(absPath) {
+		return ""
+	}
+	for absDir, dir := range w.dirs {
+		rel, err := filepath.Rel(absDir, absPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			if isIgnoredName(name) {
+				return ""
+			}
+		}
+		return filepath.Join(dir, rel)
+	}
+	return ""
+}
+
+// inWatchedDir reports whether path lies inside a directory tree passed to
+// WatchDir
+func (w *CardFileWatcher) inWatchedDir(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for absDir := range w.dirs {
+		if rel, err := filepath.Rel(absDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
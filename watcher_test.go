@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCardFileWatcherLoadedPath(t *testing.T) {
+	w := &CardFileWatcher{
+		files: map[string]string{"/notes/single.txt": "notes/single.txt"},
+		dirs:  map[string]string{"/cards": "cards"},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/notes/single.txt", "notes/single.txt"},
+		{"/notes/other.txt", ""},
+		{"/cards/go.txt", "cards/go.txt"},
+		{"/cards/lang/go.TXT", "cards/lang/go.TXT"},
+		{"/cards/go.md", ""},
+		{"/cards/.hidden.txt", ""},
+		{"/cards/.git/go.txt", ""},
+		{"/cards/lang/.#go.txt", ""},
+		{"/cards/#go.txt", ""},
+		{"/cards/~go.txt", ""},
+		{"/cards/go.txt~", ""},
+		{"/cards/.go.txt.swp", ""},
+		{"/elsewhere/go.txt", ""},
+	}
+
+	for _, test := range tests {
+		if got := w.loadedPath(test.path); got != test.want {
+			t.Errorf("loadedPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestCardFileWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cards.txt")
+	if err := os.WriteFile(path, []byte("a>>b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var changes []string
+	w, err := NewCardFileWatcher(func(path string) {
+		mu.Lock()
+		changes = append(changes, path)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.WatchDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	got := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, changes...)
+	}
+
+	// Saving in several steps, and editor files next to it, reload once
+	for _, content := range []string{"", "a>>", "a>>b\nc>>d\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".cards.txt.swp"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(watchDebounce / 4)
+	}
+	time.Sleep(3 * watchDebounce)
+	if want := []string{path}; !reflect.DeepEqual(got(), want) {
+		t.Fatalf("changes after saving = %q, want %q", got(), want)
+	}
+
+	// A save after the file went quiet reloads it again
+	if err := os.WriteFile(path, []byte("e>>f\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * watchDebounce)
+	if want := []string{path, path}; !reflect.DeepEqual(got(), want) {
+		t.Errorf("changes after saving again = %q, want %q", got(), want)
+	}
+}
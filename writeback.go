@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cardText is a card or note as it is written in a card file. For a cloze
+// note first is the note content; for a reverse note first and second are
+// its two sides.
+type cardText struct {
+	noteType string
+	first    string
+	second   string
+	meta     cardMetadata
+}
+
+// noteCardText describes a note's content as card text
+func noteCardText(noteType, content string, meta cardMetadata) (cardText, error) {
+	text := cardText{noteType: noteType, first: content, meta: meta}
+	if noteType == NoteTypeReverse {
+		front, back, ok := SplitReverseNote(content)
+		if !ok {
+			return text, fmt.Errorf("invalid reverse note")
+		}
+		text.first, text.second = front, back
+	}
+	return text, nil
+}
+
+// format writes the entry as a single line when that line parses back to the
+// same entry, and as a Q:/A: block otherwise. separator is the one declared
+// by the file the text goes into, or "" for the default separators.
+func (t cardText) format(separator string) (string, error) {
+	separators := defaultSeparators
+	if separator != "" {
+		separators = []string{separator}
+	}
+	inline := formatInlineMetadata(t.meta)
+
+	var line string
+	switch t.noteType {
+	case "":
+		line = escapeCardText(t.first, separators) + " " + separators[0] + " " + escapeCardText(t.second, separators) + inline
+	case NoteTypeReverse:
+		line = escapeCardText(t.first, separators) + " " + reverseSeparator + " " + escapeCardText(t.second, separators) + inline
+	case NoteTypeCloze:
+		line = t.first + inline
+	default:
+		return "", fmt.Errorf("unknown note type %q", t.noteType)
+	}
+	if t.parsesFrom(line, separator) {
+		return line + "\n", nil
+	}
+
+	if t.noteType == NoteTypeReverse {
+		return "", errors.New("the two sides cannot be written on one line")
+	}
+
+	block := blockQuestionPrefix + " " + t.first + "\n"
+	if t.noteType == "" {
+		block += blockAnswerPrefix + " " + t.second + "\n"
+	}
+	block += blockDelimiter + inline + "\n"
+	if t.parsesFrom(block, separator) {
+		return block, nil
+	}
+	return "", errors.New("cannot be written as card text that reads back the same")
+}
+
+// parsesFrom reports whether text reads back as this entry
+func (t cardText) parsesFrom(text, separator string) bool {
+	entry := parseCardText(text, separator)
+	return entry != nil && t.sameContent(entry) && sameMetadata(entry.meta, t.meta)
+}
+
+// sameContent reports whether a parsed entry holds the same card or note,
+// whatever its metadata
+func (t cardText) sameContent(entry *parsedEntry) bool {
+	switch t.noteType {
+	case "":
+		return entry.noteType == "" && entry.cards[0].Question == t.first && entry.cards[0].Answer == t.second
+	case NoteTypeReverse:
+		return entry.noteType == NoteTypeReverse && entry.content == ReverseNoteContent(t.first, t.second)
+	default:
+		return entry.noteType == t.noteType && entry.content == t.first
+	}
+}
+
+// parseCardText parses text the way a card file is parsed and returns the
+// entry it holds, or nil unless it holds exactly one card or note
+func parseCardText(text, separator string) *parsedEntry {
+	cp := &CardParser{
+		separator: separator,
+		parseResult: &ParseResult{
+			Cards:  make([]Card, 0),
+			Errors: make([]ParseError, 0),
+		},
+	}
+	if err := cp.parse(strings.NewReader(text)); err != nil {
+		return nil
+	}
+	if len(cp.parseResult.Errors) > 0 || len(cp.pending) != 1 {
+		return nil
+	}
+	return cp.pending[0]
+}
+
+// sameMetadata compares metadata read from a card file with a card's fields,
+// treating a missing prompt type as factual
+func sameMetadata(parsed, meta cardMetadata) bool {
+	promptType := func(value string) string {
+		if value == "" {
+			return "factual"
+		}
+		return value
+	}
+	return parsed.SourceContext == meta.SourceContext && parsed.Tags == meta.Tags &&
+		promptType(parsed.PromptType) == promptType(meta.PromptType)
+}
+
+// cardFile is the content of a card file that cards are written back to
+type cardFile struct {
+	path      string
+	lines     []string
+	separator string // Declared in the front matter
+	tags      string // Declared in the front matter for every card
+	bom       bool   // Starts with a UTF-8 byte order mark
+	crlf      bool   // Lines end in "\r\n", as written on Windows
+}
+
+func readCardFile(path string) (*cardFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	file := &cardFile{path: path}
+	switch encodingName, _ := detectEncoding(data); encodingName {
+	case EncodingUTF8:
+	case EncodingUTF8BOM:
+		file.bom = true
+		data = data[len(utf8BOM):]
+	default:
+		return nil, fmt.Errorf("%s is in %s, cards can only be written to UTF-8 files", path, encodingName)
+	}
+
+	// Lines are kept without their "\r", and all end in "\r\n" again when
+	// the file is written
+	content := string(data)
+	file.crlf = strings.Contains(content, "\r\n")
+	if content = strings.TrimSuffix(content, "\n"); content != "" {
+		file.lines = strings.Split(content, "\n")
+		for i, line := range file.lines {
+			file.lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+
+	// The front matter decides which separator new lines have to use
+	if len(file.lines) > 0 && isFrontMatterFence(strings.TrimSpace(file.lines[0])) {
+		fence := strings.TrimSpace(file.lines[0])
+		for i := 1; i < len(file.lines); i++ {
+			if strings.TrimSpace(file.lines[i]) != fence {
+				continue
+			}
+			if fm, err := parseFrontMatter(fence, file.lines[1:i]); err == nil {
+				file.separator = fm.Separator
+				file.tags, _ = fm.tags()
+			}
+			break
+		}
+	}
+
+	return file, nil
+}
+
+// separators returns the separators single-line cards in the file use
+func (f *cardFile) separators() []string {
+	if f.separator != "" {
+		return []string{f.separator}
+	}
+	return defaultSeparators
+}
+
+// endsInBlock reports whether the file ends inside a card block that was
+// never closed, so that appended text would become part of it
+func (f *cardFile) endsInBlock() bool {
+	for i := 0; i < len(f.lines); i++ {
+		if !startsBlock(strings.TrimSpace(f.lines[i]), f.separators()) {
+			continue
+		}
+		end := blockEnd(f.lines, i)
+		if end == len(f.lines) {
+			_, closed := parseBlockDelimiter(strings.TrimSpace(f.lines[end-1]))
+			return !closed
+		}
+		i = end - 1
+	}
+	return false
+}
+
+// entryEnd returns the index after the last line of the card or note that
+// starts at index start, ending a block where the parser ends it
+func (f *cardFile) entryEnd(start int) int {
+	if !startsBlock(strings.TrimSpace(f.lines[start]), f.separators()) {
+		return start + 1
+	}
+	return blockEnd(f.lines, start)
+}
+
+// write replaces the file on disk. The content goes to a temporary file that
+// is renamed over the original, so the file is never left half written.
+func (f *cardFile) write() error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(f.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	newline := "\n"
+	if f.crlf {
+		newline = "\r\n"
+	}
+	content := strings.Join(f.lines, newline) + newline
+	if f.bom {
+		content = string(utf8BOM) + content
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// SetWriteBack turns write-back on or off. With write-back on, cards and
+// notes added in the app are appended to the loaded card file and edits are
+// written to the file a card came from, so the file and the database agree.
+func (cp *CardParser) SetWriteBack(enabled bool) {
+	cp.writeBack = enabled
+}
+
+func (cp *CardParser) WriteBackEnabled() bool {
+	return cp.writeBack
+}
+
+// writeBackFile returns the card file new cards are appended to, or "" when
+// they are only stored in the database
+func (cp *CardParser) writeBackFile() string {
+	if !cp.writeBack || !isCardFile(cp.currentFile) {
+		return ""
+	}
+	return cp.currentFile
+}
+
+// appendCardText adds a new card or note to the end of the loaded card file
+// and returns the line it starts on. The metadata the file's defaults already
+// supply is left out.
+func (cp *CardParser) appendCardText(text cardText) (int, error) {
+	file, err := readCardFile(cp.currentFile)
+	if err != nil {
+		return 0, err
+	}
+
+	text.meta = text.meta.withoutDefaults(cp.defaults)
+	formatted, err := text.format(file.separator)
+	if err != nil {
+		return 0, err
+	}
+
+	if file.endsInBlock() {
+		file.lines = append(file.lines, blockDelimiter)
+	}
+	lineNum := len(file.lines) + 1
+	file.lines = append(file.lines, strings.Split(strings.TrimSuffix(formatted, "\n"), "\n")...)
+
+	if err := file.write(); err != nil {
+		return 0, err
+	}
+	return lineNum, nil
+}
+
+// rewriteCardText replaces the card or note on line lineNum of a card file
+// with its edited version, keeping the metadata written there. The text on
+// that line has to be the entry before the edit, so a file that changed
+// since it was loaded is not overwritten by mistake. Cards further down the
+// file are moved when the number of lines changes.
+func (cp *CardParser) rewriteCardText(filePath string, lineNum int, before, after cardText) error {
+	return cp.rewriteEntry(filePath, lineNum, before, func(file *cardFile, entry *parsedEntry) (cardText, error) {
+		after.meta = entry.meta
+		return after, nil
+	})
+}
+
+// rewriteCardTags writes the tags of a card to the card file it came from.
+// Tags the file's front matter gives every card stay there, so a card
+// cannot lose one of them.
+func (cp *CardParser) rewriteCardTags(card *DBCard) error {
+	text := cardText{first: card.Question, second: card.Answer}
+	if card.NoteID.Valid {
+		if cp.noteRepo == nil {
+			return fmt.Errorf("no database repository available")
+		}
+		note, err := cp.noteRepo.GetByID(card.NoteID.Int64)
+		if err != nil {
+			return fmt.Errorf("failed to get note: %w", err)
+		}
+		if text, err = noteCardText(note.NoteType, note.Content, cardMetadata{}); err != nil {
+			return err
+		}
+	}
+
+	tags := splitTags(card.Tags)
+	return cp.rewriteEntry(card.SourceFile, card.SourceLine, text, func(file *cardFile, entry *parsedEntry) (cardText, error) {
+		var inline []string
+		for _, tag := range tags {
+			if !containsTag(file.tags, tag) {
+				inline = append(inline, tag)
+			}
+		}
+		for _, tag := range splitTags(file.tags) {
+			if !containsTag(card.Tags, tag) {
+				return text, fmt.Errorf("tag %q is given to every card in the front matter of %s", tag, file.path)
+			}
+		}
+
+		text.meta = entry.meta
+		text.meta.Tags = strings.Join(inline, ", ")
+		return text, nil
+	})
+}
+
+// rewriteEntry replaces the card or note on line lineNum of a card file with
+// what edit returns for it, after checking that the line still holds before
+func (cp *CardParser) rewriteEntry(filePath string, lineNum int, before cardText, edit func(file *cardFile, entry *parsedEntry) (cardText, error)) error {
+	file, err := readCardFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if lineNum < 1 || lineNum > len(file.lines) {
+		return fmt.Errorf("%s has no line %d, reload the file before editing", filePath, lineNum)
+	}
+	start := lineNum - 1
+	end := file.entryEnd(start)
+
+	entry := parseCardText(strings.Join(file.lines[start:end], "\n"), file.separator)
+	if entry == nil || !before.sameContent(entry) {
+		return fmt.Errorf("line %d of %s no longer holds this card, reload the file before editing", lineNum, filePath)
+	}
+
+	after, err := edit(file, entry)
+	if err != nil {
+		return err
+	}
+	formatted, err := after.format(file.separator)
+	if err != nil {
+		return err
+	}
+	replacement := strings.Split(strings.TrimSuffix(formatted, "\n"), "\n")
+
+	lines := append([]string{}, file.lines[:start]...)
+	lines = append(lines, replacement...)
+	file.lines = append(lines, file.lines[end:]...)
+	if err := file.write(); err != nil {
+		return err
+	}
+
+	if delta := len(replacement) - (end - start); delta != 0 && cp.cardRepo != nil {
+		return cp.shiftSourceLines(filePath, lineNum, delta)
+	}
+	return nil
+}
+
+// shiftSourceLines moves the cards of a file that start after line by delta
+// lines
+func (cp *CardParser) shiftSourceLines(filePath string, line, delta int) error {
+	dbCards, err := cp.cardRepo.GetBySourceFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get cards of %s: %w", filePath, err)
+	}
+	for _, dbCard := range dbCards {
+		if dbCard.SourceLine <= line {
+			continue
+		}
+		dbCard.SourceLine += delta
+		if err := cp.cardRepo.Update(dbCard); err != nil {
+			return fmt.Errorf("failed to update card line: %w", err)
+		}
+	}
+	return nil
+}
+
+// canWriteBack reports whether edits to a card imported from sourceFile are
+// written back to it
+func (cp *CardParser) canWriteBack(sourceFile string) bool {
+	return cp.writeBack && isCardFile(sourceFile)
+}
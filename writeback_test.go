@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeCardFile writes content to a card file in a temporary directory
+func writeCardFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cards.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadWriteBack loads a card file with write-back on
+func loadWriteBack(t *testing.T, path string) *CardParser {
+	t.Helper()
+	cp := NewCardParser()
+	cp.SetWriteBack(true)
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	return cp
+}
+
+// reparse reads a card file again and returns its cards as question/answer
+// pairs, failing on any parse error
+func reparse(t *testing.T, path string) [][2]string {
+	t.Helper()
+	cp := NewCardParser()
+	if err := cp.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if cp.HasParseErrors() {
+		t.Fatalf("file no longer parses cleanly: %s", cp.GetParseReport())
+	}
+	var cards [][2]string
+	for _, card := range cp.GetCards() {
+		cards = append(cards, [2]string{card.Question, card.Answer})
+	}
+	return cards
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const blankLineEndedBlock = "Q: Block question\nA: Block answer\n\nWhat is Rust? >> another language\n"
+
+func TestRewriteCardTextBlockEndedByBlankLine(t *testing.T) {
+	path := writeCardFile(t, blankLineEndedBlock)
+	cp := loadWriteBack(t, path)
+
+	before := cardText{first: "Block question", second: "Block answer"}
+	after := cardText{first: "Block question", second: "New answer"}
+	if err := cp.rewriteCardText(path, 1, before, after); err != nil {
+		t.Fatalf("rewriteCardText: %v", err)
+	}
+
+	want := [][2]string{{"Block question", "New answer"}, {"What is Rust?", "another language"}}
+	if got := reparse(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("cards after rewrite = %q, want %q\n%s", got, want, readFile(t, path))
+	}
+}
+
+func TestRewriteCardTextSingleLineAfterQ(t *testing.T) {
+	path := writeCardFile(t, "Q: What is 2+2? >> 4\nWhat is Go? >> a language\n")
+	cp := loadWriteBack(t, path)
+
+	before := cardText{first: "Q: What is 2+2?", second: "4"}
+	after := cardText{first: "Q: What is 2+2?", second: "four"}
+	if err := cp.rewriteCardText(path, 1, before, after); err != nil {
+		t.Fatalf("rewriteCardText: %v", err)
+	}
+
+	want := [][2]string{{"Q: What is 2+2?", "four"}, {"What is Go?", "a language"}}
+	if got := reparse(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("cards after rewrite = %q, want %q\n%s", got, want, readFile(t, path))
+	}
+}
+
+func TestAppendCardAfterBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    [][2]string
+	}{
+		{
+			name:    "block ended by a blank line",
+			content: blankLineEndedBlock,
+			want:    [][2]string{{"Block question", "Block answer"}, {"What is Rust?", "another language"}, {"New question", "New answer"}},
+		},
+		{
+			name:    "block at the end of the file",
+			content: "What is Rust? >> another language\nQ: Block question\nA: Block answer\n",
+			want:    [][2]string{{"What is Rust?", "another language"}, {"Block question", "Block answer"}, {"New question", "New answer"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeCardFile(t, test.content)
+			cp := loadWriteBack(t, path)
+
+			if err := cp.AddCardWithMetadata("New question", "New answer", "", "", ""); err != nil {
+				t.Fatalf("AddCardWithMetadata: %v", err)
+			}
+			if got := reparse(t, path); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("cards after append = %q, want %q\n%s", got, test.want, readFile(t, path))
+			}
+		})
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		edit    func(cp *CardParser, path string) error
+		want    string // The file afterwards
+	}{
+		{
+			name:    "append single-line card",
+			content: "What is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddCardWithMetadata("What is Rust?", "another language", "The Book", "", "rust")
+			},
+			want: "What is Go? >> a language\nWhat is Rust? >> another language #rust @\"The Book\"\n",
+		},
+		{
+			name:    "append card with a separator in its text",
+			content: "What is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddCard("What does x >> 1 do?", "halves x")
+			},
+			want: "What is Go? >> a language\nWhat does x \\>> 1 do? >> halves x\n",
+		},
+		{
+			name:    "append multi-line card as a block",
+			content: "What is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddCard("What does this print?\nfmt.Println(1 << 3)", "8")
+			},
+			want: "What is Go? >> a language\nQ: What does this print?\nfmt.Println(1 << 3)\nA: 8\n---\n",
+		},
+		{
+			name:    "append leaves out front matter defaults",
+			content: "---\ntags: [golang]\nseparator: \"=>\"\n---\nWhat is Go? => a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddCardWithMetadata("What is a goroutine?", "a lightweight thread", "", "", "golang, concurrency")
+			},
+			want: "---\ntags: [golang]\nseparator: \"=>\"\n---\nWhat is Go? => a language\nWhat is a goroutine? => a lightweight thread #concurrency\n",
+		},
+		{
+			name:    "append cloze note",
+			content: "What is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddNoteWithMetadata(NoteTypeCloze, "{{c1::Go}} was announced in {{c2::2009}}", "", "", "history")
+			},
+			want: "What is Go? >> a language\n{{c1::Go}} was announced in {{c2::2009}} #history\n",
+		},
+		{
+			name:    "append reverse note",
+			content: "What is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddNoteWithMetadata(NoteTypeReverse, ReverseNoteContent("chat", "cat"), "", "", "")
+			},
+			want: "What is Go? >> a language\nchat <> cat\n",
+		},
+		{
+			name:    "edit single-line card keeps its metadata",
+			content: "What is Go? >> a language #golang\nWhat is Rust? >> another language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.rewriteCardText(path, 1, cardText{first: "What is Go?", second: "a language"}, cardText{first: "What is Go?", second: "a programming language"})
+			},
+			want: "What is Go? >> a programming language #golang\nWhat is Rust? >> another language\n",
+		},
+		{
+			name:    "edit block card keeps the metadata on its delimiter",
+			content: "Q: What does this print?\nfmt.Println(1 << 3)\nA: 8\n--- #golang\nWhat is Rust? >> another language\n",
+			edit: func(cp *CardParser, path string) error {
+				before := cardText{first: "What does this print?\nfmt.Println(1 << 3)", second: "8"}
+				after := cardText{first: "What does this print?\nfmt.Println(1 << 4)", second: "16"}
+				return cp.rewriteCardText(path, 1, before, after)
+			},
+			want: "Q: What does this print?\nfmt.Println(1 << 4)\nA: 16\n--- #golang\nWhat is Rust? >> another language\n",
+		},
+		{
+			name:    "edit cloze note",
+			content: "What is Go? >> a language\nThe {{c1::cat}} sat #pets\n",
+			edit: func(cp *CardParser, path string) error {
+				before, _ := noteCardText(NoteTypeCloze, "The {{c1::cat}} sat", cardMetadata{})
+				after, _ := noteCardText(NoteTypeCloze, "The {{c1::cat}} {{c2::sat}}", cardMetadata{})
+				return cp.rewriteCardText(path, 2, before, after)
+			},
+			want: "What is Go? >> a language\nThe {{c1::cat}} {{c2::sat}} #pets\n",
+		},
+		{
+			name:    "edit below front matter",
+			content: "---\ndeck: Go\n---\nWhat is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.rewriteCardText(path, 4, cardText{first: "What is Go?", second: "a language"}, cardText{first: "What is Go?", second: "a programming language"})
+			},
+			want: "---\ndeck: Go\n---\nWhat is Go? >> a programming language\n",
+		},
+		{
+			name:    "edit in CRLF file",
+			content: "What is Go? >> a language\r\nWhat is Rust? >> another language\r\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.rewriteCardText(path, 2, cardText{first: "What is Rust?", second: "another language"}, cardText{first: "What is Rust?", second: "a systems language"})
+			},
+			want: "What is Go? >> a language\r\nWhat is Rust? >> a systems language\r\n",
+		},
+		{
+			name:    "append block to CRLF file",
+			content: "What is Go? >> a language\r\n",
+			edit: func(cp *CardParser, path string) error {
+				return cp.AddCard("Two lines\nof question", "answer")
+			},
+			want: "What is Go? >> a language\r\nQ: Two lines\r\nof question\r\nA: answer\r\n---\r\n",
+		},
+		{
+			name:    "edit of a card that moved fails",
+			content: "What is Rust? >> another language\nWhat is Go? >> a language\n",
+			edit: func(cp *CardParser, path string) error {
+				if err := cp.rewriteCardText(path, 1, cardText{first: "What is Go?", second: "a language"}, cardText{first: "What is Go?", second: "changed"}); err == nil {
+					t.Error("rewriteCardText of a line holding another card succeeded")
+				}
+				return nil
+			},
+			want: "What is Rust? >> another language\nWhat is Go? >> a language\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeCardFile(t, test.content)
+			cp := loadWriteBack(t, path)
+
+			if err := test.edit(cp, path); err != nil {
+				t.Fatalf("edit: %v", err)
+			}
+			if got := readFile(t, path); got != test.want {
+				t.Errorf("file =\n%q\nwant\n%q", got, test.want)
+			}
+			reparse(t, path)
+		})
+	}
+}